@@ -5,14 +5,23 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/kxrxh/logram/internal/anomaly"
 	"github.com/kxrxh/logram/internal/buffer"
 	"github.com/kxrxh/logram/internal/config"
 	"github.com/kxrxh/logram/internal/database"
+	"github.com/kxrxh/logram/internal/fingerprint"
+	"github.com/kxrxh/logram/internal/heartbeat"
+	"github.com/kxrxh/logram/internal/ingest"
+	"github.com/kxrxh/logram/internal/input"
+	"github.com/kxrxh/logram/internal/multiline"
 	"github.com/kxrxh/logram/internal/parser"
 	"github.com/kxrxh/logram/internal/reader"
+	"github.com/kxrxh/logram/internal/redact"
+	"github.com/kxrxh/logram/internal/syslog"
 	"github.com/kxrxh/logram/internal/telegram"
 )
 
@@ -35,7 +44,22 @@ func main() {
 		}
 	}
 
-	p := parser.NewParser(nil)
+	p := parser.NewParser(nil, parser.WithWorkers(cfg.Get().Parser.Workers))
+	formats, err := parser.NewFormats(toFormatConfigs(cfg.Get().Logs))
+	if err != nil {
+		log.Fatalf("create log formats: %v", err)
+	}
+	p.SetFormats(formats)
+	if err := setPassthroughRules(p, cfg.Get().Parser.Rules); err != nil {
+		log.Fatalf("compile passthrough rules: %v", err)
+	}
+
+	redactor, err := redact.New(toRedactConfigs(cfg.Get()))
+	if err != nil {
+		log.Fatalf("create redactor: %v", err)
+	}
+	// Rejected lines are shown in /rejected, so they are masked as they come in
+	p.DeadLetters().SetRedact(redactor.Line)
 
 	defaultRuleConfigs := toRuleConfig(cfg.Get().Parser.Rules)
 	rm, err := telegram.NewRegexManager(defaultRuleConfigs)
@@ -62,6 +86,16 @@ func main() {
 				}
 			}
 		}
+
+		chats, err := db.GetAllChats()
+		if err != nil {
+			log.Printf("load chat min levels: %v", err)
+		}
+		for _, chat := range chats {
+			if level, ok := parser.LookupLevel(chat.MinLevel); ok {
+				rm.SetChatMinLevel(chat.ChatID, level)
+			}
+		}
 	}
 
 	defer func() {
@@ -72,14 +106,67 @@ func main() {
 		}
 	}()
 
+	var fpStore fingerprint.Store
+	if db != nil {
+		fpStore = fingerprintStore{db: db}
+	}
+	fingerprints, err := fingerprint.NewTracker(fpStore)
+	if err != nil {
+		log.Printf("load fingerprints: %v", err)
+		fingerprints, _ = fingerprint.NewTracker(nil)
+	}
+
 	var bot *telegram.Bot
+	heartbeats := heartbeat.NewMonitor(func(e heartbeat.Event) {
+		if bot != nil {
+			bot.NotifySilence(e)
+			return
+		}
+		log.Printf("heartbeat: %s %q silent=%t", e.Kind, e.Name, e.Silent)
+	})
+	heartbeats.SetSources(toExpectedSources(cfg.Get().Logs))
+	if err := heartbeats.SetRules(defaultRuleConfigs); err != nil {
+		log.Fatalf("compile heartbeat rules: %v", err)
+	}
+
+	notifyAnomaly := func(a anomaly.Alert) {
+		if bot != nil {
+			bot.NotifyAnomaly(a)
+			return
+		}
+		log.Printf("anomaly: rule %q matched %d times, expected %.1f",
+			a.Rule, a.Observed, a.Expected)
+	}
+	var rateStore anomaly.Store
+	if db != nil {
+		rateStore = baselineStore{db: db}
+	}
+	anomalies, err := anomaly.New(toAnomalyConfig(cfg.Get().Anomaly), rateStore, notifyAnomaly)
+	if err != nil {
+		log.Printf("load rate baselines: %v", err)
+		anomalies, _ = anomaly.New(toAnomalyConfig(cfg.Get().Anomaly), nil, notifyAnomaly)
+	}
+	if err := anomalies.SetRules(defaultRuleConfigs); err != nil {
+		log.Fatalf("compile anomaly rules: %v", err)
+	}
+
 	if cfg.Get().Bot.Token != "" {
 		bot, err = telegram.NewBot(cfg.Get().Bot.Token, db, rm)
 		if err != nil {
 			log.Printf("initialize telegram bot: %v", err)
-		} else if err := bot.Start(); err != nil {
-			log.Printf("start telegram bot: %v", err)
-			bot = nil
+		} else {
+			bot.SetRedactor(redactor)
+			bot.SetDedupWindow(cfg.Get().Dedup.Window)
+			bot.SetFingerprints(fingerprints)
+			bot.SetHeartbeats(heartbeats)
+			policies := toEscalationPolicies(cfg.Get().Escalation)
+			if err := bot.SetEscalationPolicies(policies); err != nil {
+				log.Printf("set escalation policies: %v", err)
+			}
+			if err := bot.Start(); err != nil {
+				log.Printf("start telegram bot: %v", err)
+				bot = nil
+			}
 		}
 	}
 
@@ -88,33 +175,124 @@ func main() {
 		if err := rm.SetDefaultRules(toRuleConfig(newCfg.Parser.Rules)); err != nil {
 			log.Printf("Failed to update regex defaults: %v", err)
 		}
+		if formats, err := parser.NewFormats(toFormatConfigs(newCfg.Logs)); err != nil {
+			log.Printf("Failed to update log formats: %v", err)
+		} else {
+			p.SetFormats(formats)
+		}
+		if err := setPassthroughRules(p, newCfg.Parser.Rules); err != nil {
+			log.Printf("Failed to update passthrough rules: %v", err)
+		}
+		anomalies.SetConfig(toAnomalyConfig(newCfg.Anomaly))
+		if err := anomalies.SetRules(toRuleConfig(newCfg.Parser.Rules)); err != nil {
+			log.Printf("Failed to update anomaly rules: %v", err)
+		}
+		heartbeats.SetSources(toExpectedSources(newCfg.Logs))
+		if err := heartbeats.SetRules(toRuleConfig(newCfg.Parser.Rules)); err != nil {
+			log.Printf("Failed to update heartbeat rules: %v", err)
+		}
+		if err := redactor.Update(toRedactConfigs(newCfg)); err != nil {
+			log.Printf("Failed to update redaction: %v", err)
+		}
+		if bot != nil {
+			bot.SetDedupWindow(newCfg.Dedup.Window)
+			policies := toEscalationPolicies(newCfg.Escalation)
+			if err := bot.SetEscalationPolicies(policies); err != nil {
+				log.Printf("Failed to update escalation policies: %v", err)
+			}
+		}
 	})
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	go fingerprints.Run(ctx, fingerprintFlushInterval)
+	go heartbeats.Run(ctx, heartbeatCheckInterval)
+	go anomalies.Run(ctx, anomalyFlushInterval)
 
-	readChan := reader.ReadFileTail(ctx, cfg.Get().Logs.Path)
+	if bot != nil {
+		bot.SetDeadLetters(p.DeadLetters())
+		alert := toDeadLetterAlert(cfg.Get().Parser.DeadLetters)
+		go bot.WatchDeadLetters(ctx, p.DeadLetters(), alert)
+	}
+
+	var readerOpts []reader.Option
+	if db != nil {
+		readerOpts = append(readerOpts, reader.WithCheckpointStore(checkpointStore{db: db}))
+	}
+	readChan := reader.ReadSources(ctx, toSources(cfg.Get().Logs), readerOpts...)
+
+	assembler, err := multiline.NewAssembler(toMultilineRules(cfg.Get().Logs))
+	if err != nil {
+		log.Fatalf("create multiline assembler: %v", err)
+	}
+	eventChan := assembler.Start(ctx, readChan)
+
+	// Lines read before shutdown are already past the saved checkpoints, so
+	// the buffer and the parser outlive ctx and end once their input does.
+	drainCtx := context.WithoutCancel(ctx)
 
 	batchCfg := cfg.Get().Batch
 	buf := buffer.New(
-		ctx,
+		drainCtx,
 		batchCfg.Size,
 		batchCfg.Interval,
 		buffer.WithPolicy(getPolicy(batchCfg.Policy)),
 	)
 	buf.Start()
 
-	go func() {
-		for line := range readChan {
-			buf.Input() <- line
+	// forwarders is done once the reader has saved its last checkpoint and
+	// the syslog and HTTP receivers have closed their connections.
+	var forwarders sync.WaitGroup
+	forward := func(lines <-chan input.Line) {
+		forwarders.Go(func() {
+			for line := range lines {
+				heartbeats.SeenSource(line.Source)
+				buf.Input() <- line
+			}
+		})
+	}
+	forward(eventChan)
+
+	if syslogCfg := cfg.Get().Syslog; syslogCfg.UDP != "" || syslogCfg.TCP != "" {
+		srv, err := syslog.Listen(syslog.Config{
+			Name:        syslogCfg.Name,
+			UDPAddr:     syslogCfg.UDP,
+			TCPAddr:     syslogCfg.TCP,
+			DeadLetters: p.DeadLetters(),
+		})
+		if err != nil {
+			log.Printf("start syslog receiver: %v", err)
+		} else {
+			forward(srv.Start(ctx))
 		}
-	}()
+	}
 
-	parsedChan := p.Start(ctx, buf.Output())
+	if httpCfg := cfg.Get().HTTP; httpCfg.Addr != "" {
+		srv := ingest.New(ingest.Config{
+			Addr:   httpCfg.Addr,
+			Token:  httpCfg.Token,
+			Source: httpCfg.Source,
+		}, heartbeatSink{buf: buf, heartbeats: heartbeats})
+		forwarders.Go(func() {
+			if err := srv.Serve(ctx); err != nil {
+				log.Printf("start http ingest: %v", err)
+			}
+		})
+	}
+
+	parsedChan := p.Start(drainCtx, buf.Output())
+	go func() {
+		forwarders.Wait()
+		buf.Stop()
+	}()
 
 	var sendChan chan parser.LogEntry
+	sent := make(chan struct{})
 	if bot != nil {
 		sendChan = make(chan parser.LogEntry, batchCfg.Size)
 		go func() {
+			defer close(sent)
 			for entry := range sendChan {
 				if err := bot.SendLog(entry); err != nil {
 					log.Printf("send telegram message: %v", err)
@@ -124,9 +302,15 @@ func main() {
 	}
 
 	for entry := range parsedChan {
+		heartbeats.Observe(entry)
+		anomalies.Observe(entry)
 		if bot != nil {
 			sendChan <- entry
 		} else {
+			entry = redactor.Entry(entry)
+			if entry.Level.AtLeast(fingerprint.MinUnroutedLevel) {
+				fingerprints.Observe(entry)
+			}
 			// #nosec G706
 			log.Printf("parsed entry: %q [%s] %q",
 				entry.Timestamp.Format(time.RFC3339),
@@ -135,20 +319,126 @@ func main() {
 		}
 	}
 
+	log.Println("Shutting down...")
 	if bot != nil {
 		close(sendChan)
+		<-sent
+		bot.Stop()
+	}
+	if err := fingerprints.Flush(); err != nil {
+		log.Printf("save fingerprints: %v", err)
 	}
+	if err := anomalies.Flush(); err != nil {
+		log.Printf("save rate baselines: %v", err)
+	}
+}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+// fingerprintFlushInterval is how often fingerprint counts are saved.
+const fingerprintFlushInterval = 30 * time.Second
 
-	log.Println("Shutting down...")
-	cancel()
-	buf.Stop()
-	if bot != nil {
-		bot.Stop()
+// heartbeatCheckInterval is how often silent sources and rules are looked
+// for.
+const heartbeatCheckInterval = 5 * time.Second
+
+// anomalyFlushInterval is how often finished rate buckets are checked and
+// baselines saved.
+const anomalyFlushInterval = 10 * time.Second
+
+// baselineStore keeps rate baselines in the bot database.
+type baselineStore struct {
+	db *database.DB
+}
+
+func (s baselineStore) LoadBaselines() ([]anomaly.Baseline, error) {
+	rows, err := s.db.GetAllRateBaselines()
+	if err != nil {
+		return nil, err
+	}
+	baselines := make([]anomaly.Baseline, len(rows))
+	for i, r := range rows {
+		baselines[i] = anomaly.Baseline(r)
+	}
+	return baselines, nil
+}
+
+func (s baselineStore) SaveBaselines(baselines []anomaly.Baseline) error {
+	rows := make([]database.RateBaseline, len(baselines))
+	for i, b := range baselines {
+		rows[i] = database.RateBaseline(b)
+	}
+	return s.db.SaveRateBaselines(rows)
+}
+
+// heartbeatSink counts the lines received over HTTP for their sources before
+// passing them on.
+type heartbeatSink struct {
+	buf        ingest.Sink
+	heartbeats *heartbeat.Monitor
+}
+
+func (s heartbeatSink) TrySend(line input.Line) bool {
+	s.heartbeats.SeenSource(line.Source)
+	return s.buf.TrySend(line)
+}
+
+// fingerprintStore keeps fingerprint counts in the bot database.
+type fingerprintStore struct {
+	db *database.DB
+}
+
+func (s fingerprintStore) LoadFingerprints() ([]fingerprint.Record, error) {
+	fps, err := s.db.GetAllFingerprints()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]fingerprint.Record, len(fps))
+	for i, fp := range fps {
+		records[i] = fingerprint.Record(fp)
 	}
+	return records, nil
+}
+
+func (s fingerprintStore) SaveFingerprints(records []fingerprint.Record) error {
+	fps := make([]database.Fingerprint, len(records))
+	for i, r := range records {
+		fps[i] = database.Fingerprint(r)
+	}
+	return s.db.SaveFingerprints(fps)
+}
+
+func (s fingerprintStore) DeleteFingerprints(hashes []string) error {
+	return s.db.DeleteFingerprints(hashes)
+}
+
+// checkpointStore keeps reader checkpoints in the bot database.
+type checkpointStore struct {
+	db *database.DB
+}
+
+func (s checkpointStore) LoadCheckpoint(path string) (reader.Checkpoint, bool, error) {
+	cp, found, err := s.db.GetReaderCheckpoint(path)
+	if err != nil || !found {
+		return reader.Checkpoint{}, found, err
+	}
+	return reader.Checkpoint{
+		Path:     cp.Path,
+		Device:   cp.Device,
+		Inode:    cp.Inode,
+		Offset:   cp.Offset,
+		HeadLen:  cp.HeadLen,
+		HeadHash: cp.HeadHash,
+	}, true, nil
+}
+
+func (s checkpointStore) SaveCheckpoint(cp reader.Checkpoint) error {
+	return s.db.SaveReaderCheckpoint(database.ReaderCheckpoint{
+		Path:     cp.Path,
+		Device:   cp.Device,
+		Inode:    cp.Inode,
+		Offset:   cp.Offset,
+		HeadLen:  cp.HeadLen,
+		HeadHash: cp.HeadHash,
+	})
 }
 
 func getPolicy(p string) buffer.BufferPolicy {
@@ -168,9 +458,157 @@ func toRuleConfig(rules []config.Rule) []parser.RuleConfig {
 	result := make([]parser.RuleConfig, len(rules))
 	for i, r := range rules {
 		result[i] = parser.RuleConfig{
-			Name:    r.Name,
-			Pattern: r.Pattern,
+			Name:        r.Name,
+			Pattern:     r.Pattern,
+			MinLevel:    r.MinLevel,
+			Passthrough: r.Passthrough,
+			Threshold: parser.Threshold{
+				Count:  r.Threshold.Count,
+				Window: r.Threshold.Window,
+			},
+			Expect: r.ExpectEvery,
 		}
 	}
 	return result
 }
+
+// setPassthroughRules lets lines matching passthrough rules reach the chats
+// when they can't be parsed; the other rules are applied per chat.
+func setPassthroughRules(p *parser.Parser, rules []config.Rule) error {
+	var passthrough []config.Rule
+	for _, r := range rules {
+		if r.Passthrough {
+			passthrough = append(passthrough, r)
+		}
+	}
+
+	compiled, err := parser.CompileRules(toRuleConfig(passthrough))
+	if err != nil {
+		return err
+	}
+	p.SetPassthroughRules(compiled)
+	return nil
+}
+
+func toDeadLetterAlert(c config.DeadLetterConfig) telegram.DeadLetterAlert {
+	window := c.Window
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	return telegram.DeadLetterAlert{
+		Ratio:    c.AlertRatio,
+		Window:   window,
+		MinLines: c.MinLines,
+	}
+}
+
+// toRedactConfigs returns the top-level redaction config and the sources'
+// own ones.
+func toRedactConfigs(cfg *config.Config) (redact.Config, map[string]redact.Config) {
+	sources := make(map[string]redact.Config)
+	for _, s := range cfg.Logs.Sources {
+		if s.Redact != nil {
+			sources[s.Name] = toRedactConfig(*s.Redact)
+		}
+	}
+	return toRedactConfig(cfg.Redact), sources
+}
+
+func toRedactConfig(c config.RedactConfig) redact.Config {
+	rules := make([]redact.RuleConfig, len(c.Rules))
+	for i, r := range c.Rules {
+		rules[i] = redact.RuleConfig{Pattern: r.Pattern, Replacement: r.Replacement}
+	}
+	return redact.Config{Builtin: c.Builtin, Rules: rules}
+}
+
+func toSources(logs config.LogsConfig) []reader.Source {
+	sources := make([]reader.Source, 0, len(logs.Sources)+1)
+	if logs.Path != "" {
+		sources = append(sources, reader.Source{Glob: logs.Path})
+	}
+	for _, s := range logs.Sources {
+		sources = append(sources, reader.Source{
+			Name:   s.Name,
+			Glob:   s.Glob,
+			Labels: s.Labels,
+		})
+	}
+	return sources
+}
+
+func toAnomalyConfig(c config.AnomalyConfig) anomaly.Config {
+	return anomaly.Config{
+		Bucket:     c.Bucket,
+		Factor:     c.Factor,
+		Alpha:      c.Alpha,
+		MinSamples: c.MinSamples,
+		MinCount:   c.MinCount,
+	}
+}
+
+// toEscalationPolicies returns the configured escalation tiers by chat.
+func toEscalationPolicies(c config.EscalationConfig) map[int64][]telegram.EscalationTier {
+	policies := make(map[int64][]telegram.EscalationTier, len(c.Policies))
+	for _, p := range c.Policies {
+		tiers := make([]telegram.EscalationTier, 0, len(p.Tiers))
+		for _, t := range p.Tiers {
+			tiers = append(tiers, telegram.EscalationTier{After: t.After, Chats: t.Chats})
+		}
+		policies[p.Chat] = tiers
+	}
+	return policies
+}
+
+// toExpectedSources returns how often each source that sets expect_every
+// should log.
+func toExpectedSources(logs config.LogsConfig) map[string]time.Duration {
+	every := make(map[string]time.Duration)
+	for _, s := range logs.Sources {
+		if s.ExpectEvery > 0 {
+			every[s.Name] = s.ExpectEvery
+		}
+	}
+	return every
+}
+
+func toMultilineRules(logs config.LogsConfig) map[string]multiline.RuleConfig {
+	rules := make(map[string]multiline.RuleConfig, len(logs.Sources))
+	for _, s := range logs.Sources {
+		if s.Multiline.StartPattern == "" {
+			continue
+		}
+		rules[s.Name] = multiline.RuleConfig{
+			StartPattern: s.Multiline.StartPattern,
+			Timeout:      s.Multiline.Timeout,
+			MaxLines:     s.Multiline.MaxLines,
+			MaxBytes:     s.Multiline.MaxBytes,
+		}
+	}
+	return rules
+}
+
+func toFormatConfigs(logs config.LogsConfig) map[string]parser.FormatConfig {
+	formats := make(map[string]parser.FormatConfig, len(logs.Sources))
+	for _, s := range logs.Sources {
+		if s.Format == "" && len(s.Time.Layouts) == 0 && s.Time.Timezone == "" &&
+			!s.Time.ReadTimeFallback && !s.Passthrough {
+			continue
+		}
+		formats[s.Name] = parser.FormatConfig{
+			Type:       s.Format,
+			TimeKey:    s.JSON.TimeKey,
+			LevelKey:   s.JSON.LevelKey,
+			MessageKey: s.JSON.MessageKey,
+			Pattern:    s.Regex.Pattern,
+			TimeLayout: s.Regex.TimeLayout,
+			Time: parser.TimeConfig{
+				Layouts:          s.Time.Layouts,
+				Timezone:         s.Time.Timezone,
+				ReadTimeFallback: s.Time.ReadTimeFallback,
+			},
+			Passthrough: s.Passthrough,
+		}
+	}
+	return formats
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"math/rand"
 	"os"
@@ -17,6 +16,7 @@ const (
 var (
 	logLevels = []string{"DEBUG", "INFO", "ERROR"}
 	mu        sync.Mutex
+	written   int
 )
 
 func main() {
@@ -34,15 +34,19 @@ func generateLog() {
 	mu.Lock()
 	defer mu.Unlock()
 
-	lines, err := readLines(logFileName)
-	if err != nil {
-		fmt.Println("Error reading log file:", err)
-		return
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if written >= maxLines {
+		// Start over, like logrotate's copytruncate would
+		flags |= os.O_TRUNC
+		written = 0
 	}
 
-	if len(lines) >= maxLines {
-		lines = lines[len(lines)-maxLines+1:]
+	file, err := os.OpenFile(logFileName, flags, 0o644)
+	if err != nil {
+		fmt.Println("Error opening log file:", err)
+		return
 	}
+	defer file.Close()
 
 	logLine := fmt.Sprintf(
 		"%s [%s] %s\n",
@@ -50,12 +54,11 @@ func generateLog() {
 		logLevels[rand.Intn(len(logLevels))],
 		generateRandomMessage(),
 	)
-	lines = append(lines, logLine)
-
-	err = writeLines(lines, logFileName)
-	if err != nil {
+	if _, err := file.WriteString(logLine); err != nil {
 		fmt.Println("Error writing to log file:", err)
+		return
 	}
+	written++
 }
 
 func generateRandomMessage() string {
@@ -69,34 +72,3 @@ func generateRandomMessage() string {
 	}
 	return messages[rand.Intn(len(messages))]
 }
-
-func readLines(fileName string) ([]string, error) {
-	file, err := os.Open(fileName)
-	if os.IsNotExist(err) {
-		return []string{}, nil
-	} else if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	return lines, scanner.Err()
-}
-
-func writeLines(lines []string, fileName string) error {
-	file, err := os.Create(fileName)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	for _, line := range lines {
-		fmt.Fprintln(writer, line)
-	}
-	return writer.Flush()
-}
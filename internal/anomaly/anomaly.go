@@ -0,0 +1,290 @@
+// Package anomaly learns how often each rule usually matches at each hour of
+// the day and alerts when the current rate is far from it.
+package anomaly
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+// Config sets how rates are measured and compared.
+type Config struct {
+	// Bucket is the period matches are counted over.
+	Bucket time.Duration
+	// Factor is how many times above or below the baseline a count must be
+	// to alert. It must be above 1; 0 turns detection off.
+	Factor float64
+	// Alpha is the weight of a new count in the baseline, between 0 and 1.
+	Alpha float64
+	// MinSamples is how many buckets an hour's baseline needs before it is
+	// trusted.
+	MinSamples int
+	// MinCount keeps small numbers from alerting: a spike needs at least
+	// this many matches and a drop a baseline of at least this many.
+	MinCount float64
+}
+
+const (
+	defaultBucket     = 5 * time.Minute
+	defaultAlpha      = 0.2
+	defaultMinSamples = 3
+	defaultMinCount   = 5
+)
+
+// Enabled reports whether detection is on.
+func (c Config) Enabled() bool {
+	return c.Factor > 1
+}
+
+func (c Config) withDefaults() Config {
+	if c.Bucket <= 0 {
+		c.Bucket = defaultBucket
+	}
+	if c.Alpha <= 0 || c.Alpha > 1 {
+		c.Alpha = defaultAlpha
+	}
+	if c.MinSamples <= 0 {
+		c.MinSamples = defaultMinSamples
+	}
+	if c.MinCount <= 0 {
+		c.MinCount = defaultMinCount
+	}
+	return c
+}
+
+// Baseline is the usual number of matches of a rule per bucket at an hour of
+// the day, as an exponentially weighted moving average.
+type Baseline struct {
+	Rule    string
+	Hour    int
+	Mean    float64
+	Samples int
+}
+
+// Store persists baselines between restarts.
+type Store interface {
+	LoadBaselines() ([]Baseline, error)
+	SaveBaselines(baselines []Baseline) error
+}
+
+// Alert is a bucket whose count is far from the baseline.
+type Alert struct {
+	Rule     string
+	Start    time.Time
+	Bucket   time.Duration
+	Expected float64
+	Observed int
+}
+
+// Spike reports whether the rate is above the baseline rather than below.
+func (a Alert) Spike() bool {
+	return float64(a.Observed) > a.Expected
+}
+
+// Detector counts rule matches per bucket and compares each finished bucket
+// with the baseline of its hour. It is safe for concurrent use.
+type Detector struct {
+	store  Store
+	notify func(Alert)
+
+	mu        sync.Mutex
+	cfg       Config
+	rules     *parser.RuleMatcher
+	baselines map[baselineKey]*Baseline
+	// start is the current bucket; counts from a bucket that started before
+	// the detector did are incomplete and not compared.
+	start    time.Time
+	partial  bool
+	counts   map[string]int
+	firing   map[string]bool
+	alerts   []Alert
+	dirty    map[baselineKey]struct{}
+	now      func() time.Time
+	location *time.Location
+}
+
+type baselineKey struct {
+	rule string
+	hour int
+}
+
+// New loads the baselines from store, which may be nil to keep them in
+// memory only. notify is called from Flush for every alert.
+func New(cfg Config, store Store, notify func(Alert)) (*Detector, error) {
+	d := &Detector{
+		store:     store,
+		notify:    notify,
+		cfg:       cfg.withDefaults(),
+		rules:     parser.NewRuleMatcher(nil),
+		baselines: make(map[baselineKey]*Baseline),
+		partial:   true,
+		counts:    make(map[string]int),
+		firing:    make(map[string]bool),
+		dirty:     make(map[baselineKey]struct{}),
+		now:       time.Now,
+		location:  time.Local,
+	}
+	if store == nil {
+		return d, nil
+	}
+
+	baselines, err := store.LoadBaselines()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range baselines {
+		d.baselines[baselineKey{rule: b.Rule, hour: b.Hour}] = &b
+	}
+	return d, nil
+}
+
+// SetConfig changes the settings. Turning detection on or changing the
+// bucket length starts a new bucket.
+func (d *Detector) SetConfig(cfg Config) {
+	cfg = cfg.withDefaults()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cfg.Bucket != d.cfg.Bucket || cfg.Enabled() != d.cfg.Enabled() {
+		d.start = time.Time{}
+		d.partial = true
+		clear(d.counts)
+	}
+	d.cfg = cfg
+}
+
+// SetRules sets the rules whose rates are watched.
+func (d *Detector) SetRules(configs []parser.RuleConfig) error {
+	rules, err := parser.CompileRules(configs)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.rules = parser.NewRuleMatcher(rules)
+	d.mu.Unlock()
+	return nil
+}
+
+// Observe counts the entry for the rules it matches.
+func (d *Detector) Observe(entry parser.LogEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.cfg.Enabled() {
+		return
+	}
+	d.rotateLocked(d.now())
+	for r := range d.rules.Match(entry) {
+		d.counts[r.Name]++
+	}
+}
+
+// rotateLocked closes the current bucket once now is past it.
+func (d *Detector) rotateLocked(now time.Time) {
+	start := now.Truncate(d.cfg.Bucket)
+	if start.Equal(d.start) {
+		return
+	}
+	if !d.start.IsZero() && !d.partial {
+		d.closeLocked()
+	}
+	d.partial = d.start.IsZero()
+	d.start = start
+	clear(d.counts)
+}
+
+// closeLocked compares the counts of the finished bucket with the baselines
+// of its hour, then adds them to the baselines.
+func (d *Detector) closeLocked() {
+	hour := d.start.In(d.location).Hour()
+	for _, r := range d.rules.Rules() {
+		count := d.counts[r.Name]
+		k := baselineKey{rule: r.Name, hour: hour}
+		b, ok := d.baselines[k]
+		if !ok {
+			b = &Baseline{Rule: r.Name, Hour: hour}
+			d.baselines[k] = b
+		}
+
+		if b.Samples >= d.cfg.MinSamples && d.anomalous(float64(count), b.Mean) {
+			if !d.firing[r.Name] {
+				d.alerts = append(d.alerts, Alert{
+					Rule:     r.Name,
+					Start:    d.start,
+					Bucket:   d.cfg.Bucket,
+					Expected: b.Mean,
+					Observed: count,
+				})
+			}
+			d.firing[r.Name] = true
+		} else {
+			delete(d.firing, r.Name)
+		}
+
+		if b.Samples == 0 {
+			b.Mean = float64(count)
+		} else {
+			b.Mean += d.cfg.Alpha * (float64(count) - b.Mean)
+		}
+		b.Samples++
+		d.dirty[k] = struct{}{}
+	}
+}
+
+func (d *Detector) anomalous(count, mean float64) bool {
+	spike := count >= d.cfg.MinCount && count >= mean*d.cfg.Factor
+	drop := mean >= d.cfg.MinCount && count <= mean/d.cfg.Factor
+	return spike || drop
+}
+
+// Flush closes the current bucket if it is over, sends the pending alerts
+// and saves the changed baselines.
+func (d *Detector) Flush() error {
+	d.mu.Lock()
+	if d.cfg.Enabled() {
+		d.rotateLocked(d.now())
+	}
+	alerts := d.alerts
+	d.alerts = nil
+	changed := make([]Baseline, 0, len(d.dirty))
+	for k := range d.dirty {
+		changed = append(changed, *d.baselines[k])
+	}
+	clear(d.dirty)
+	d.mu.Unlock()
+
+	for _, a := range alerts {
+		d.notify(a)
+	}
+
+	if d.store == nil || len(changed) == 0 {
+		return nil
+	}
+	if err := d.store.SaveBaselines(changed); err != nil {
+		d.mu.Lock()
+		for _, b := range changed {
+			d.dirty[baselineKey{rule: b.Rule, hour: b.Hour}] = struct{}{}
+		}
+		d.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is done.
+func (d *Detector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Flush(); err != nil {
+				log.Printf("save rate baselines: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+type memoryStore struct {
+	baselines map[baselineKey]Baseline
+	saves     int
+}
+
+func (s *memoryStore) LoadBaselines() ([]Baseline, error) {
+	baselines := make([]Baseline, 0, len(s.baselines))
+	for _, b := range s.baselines {
+		baselines = append(baselines, b)
+	}
+	return baselines, nil
+}
+
+func (s *memoryStore) SaveBaselines(baselines []Baseline) error {
+	s.saves++
+	for _, b := range baselines {
+		s.baselines[baselineKey{rule: b.Rule, hour: b.Hour}] = b
+	}
+	return nil
+}
+
+type testDetector struct {
+	*Detector
+	now    time.Time
+	alerts []Alert
+}
+
+func newTestDetector(t *testing.T, store Store) *testDetector {
+	t.Helper()
+	td := &testDetector{now: time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)}
+	cfg := Config{Bucket: time.Minute, Factor: 3, Alpha: 0.5, MinSamples: 2, MinCount: 5}
+	d, err := New(cfg, store, func(a Alert) { td.alerts = append(td.alerts, a) })
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	d.now = func() time.Time { return td.now }
+	d.location = time.UTC
+	if err := d.SetRules([]parser.RuleConfig{{Name: "errors", Pattern: "ERROR"}}); err != nil {
+		t.Fatalf("SetRules failed: %v", err)
+	}
+	td.Detector = d
+	return td
+}
+
+// bucket feeds n matches into the current bucket and moves to the next one.
+func (td *testDetector) bucket(t *testing.T, n int) {
+	t.Helper()
+	for range n {
+		td.Observe(parser.LogEntry{Raw: []byte("ERROR boom")})
+	}
+	td.Observe(parser.LogEntry{Raw: []byte("INFO ok")})
+	td.now = td.now.Add(time.Minute)
+	if err := td.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+}
+
+func TestDetector_AlertsOnSpikeAndDrop(t *testing.T) {
+	td := newTestDetector(t, nil)
+	td.now = td.now.Add(30 * time.Second)
+	td.bucket(t, 100) // started mid-bucket, not counted
+	td.bucket(t, 10)
+	td.bucket(t, 10)
+
+	b := td.baselines[baselineKey{rule: "errors", hour: 3}]
+	if b == nil || b.Samples != 2 || b.Mean != 10 {
+		t.Fatalf("expected a baseline of 10 over 2 samples, got %+v", b)
+	}
+	if len(td.alerts) != 0 {
+		t.Fatalf("expected no alerts while learning, got %+v", td.alerts)
+	}
+
+	td.bucket(t, 40)
+	td.bucket(t, 60)
+	if len(td.alerts) != 1 {
+		t.Fatalf("expected one alert for the spike, got %+v", td.alerts)
+	}
+	a := td.alerts[0]
+	if !a.Spike() || a.Observed != 40 || a.Expected != 10 || a.Rule != "errors" {
+		t.Fatalf("unexpected alert: %+v", a)
+	}
+	if want := time.Date(2026, 1, 2, 3, 3, 0, 0, time.UTC); !a.Start.Equal(want) {
+		t.Fatalf("expected the alert for the bucket at %v, got %v", want, a.Start)
+	}
+
+	td.bucket(t, 30) // within the factor of the baseline
+	td.bucket(t, 2)
+	if len(td.alerts) != 2 || td.alerts[1].Spike() || td.alerts[1].Observed != 2 {
+		t.Fatalf("expected a drop alert, got %+v", td.alerts)
+	}
+}
+
+func TestDetector_SeasonalBaselinesPersist(t *testing.T) {
+	store := &memoryStore{baselines: make(map[baselineKey]Baseline)}
+	td := newTestDetector(t, store)
+	td.bucket(t, 0)
+	td.bucket(t, 8)
+	td.now = time.Date(2026, 1, 2, 4, 0, 0, 0, time.UTC)
+	td.bucket(t, 20)
+
+	// the bucket left at 03:02 had no matches
+	if got := store.baselines[baselineKey{rule: "errors", hour: 3}]; got.Mean != 4 {
+		t.Fatalf("expected hour 3 saved with mean 4, got %+v", got)
+	}
+	got := store.baselines[baselineKey{rule: "errors", hour: 4}]
+	if got.Mean != 20 || got.Samples != 1 {
+		t.Fatalf("expected hour 4 saved separately, got %+v", got)
+	}
+
+	restarted := newTestDetector(t, store)
+	b := restarted.baselines[baselineKey{rule: "errors", hour: 4}]
+	if b == nil || b.Mean != 20 {
+		t.Fatalf("expected baselines loaded from the store, got %+v", b)
+	}
+}
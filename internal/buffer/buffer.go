@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	"github.com/kxrxh/logram/internal/input"
 )
 
 type BufferPolicy int
@@ -16,7 +18,7 @@ const (
 
 var batchPool = sync.Pool{
 	New: func() any {
-		s := make([][]byte, 0)
+		s := make([]input.Line, 0)
 		return &s
 	},
 }
@@ -25,8 +27,8 @@ type Buffer struct {
 	maxSize       int
 	flushInterval time.Duration
 	policy        BufferPolicy
-	input         chan []byte
-	output        chan []byte
+	input         chan input.Line
+	output        chan input.Line
 	ctx           context.Context
 	done          chan struct{}
 	wg            sync.WaitGroup
@@ -35,17 +37,17 @@ type Buffer struct {
 
 type Option func(*Buffer)
 
-func WithPolicy(p BufferPolicy) Option   { return func(b *Buffer) { b.policy = p } }
-func WithInputCh(ch chan []byte) Option  { return func(b *Buffer) { b.input = ch } }
-func WithOutputCh(ch chan []byte) Option { return func(b *Buffer) { b.output = ch } }
+func WithPolicy(p BufferPolicy) Option       { return func(b *Buffer) { b.policy = p } }
+func WithInputCh(ch chan input.Line) Option  { return func(b *Buffer) { b.input = ch } }
+func WithOutputCh(ch chan input.Line) Option { return func(b *Buffer) { b.output = ch } }
 
 func New(ctx context.Context, maxSize int, flushInterval time.Duration, opts ...Option) *Buffer {
 	b := &Buffer{
 		maxSize:       maxSize,
 		flushInterval: flushInterval,
 		policy:        BlockOnFull,
-		input:         make(chan []byte, maxSize),
-		output:        make(chan []byte, maxSize),
+		input:         make(chan input.Line, maxSize),
+		output:        make(chan input.Line, maxSize),
 		ctx:           ctx,
 		done:          make(chan struct{}),
 	}
@@ -68,15 +70,38 @@ func (b *Buffer) Stop() {
 	})
 }
 
-func (b *Buffer) Input() chan<- []byte  { return b.input }
-func (b *Buffer) Output() <-chan []byte { return b.output }
+func (b *Buffer) Input() chan<- input.Line  { return b.input }
+func (b *Buffer) Output() <-chan input.Line { return b.output }
+
+// TrySend adds line to the buffer. Under BlockOnFull it does not wait and
+// reports false when the input is full, which happens once the consumer falls
+// behind; other policies make room themselves.
+func (b *Buffer) TrySend(line input.Line) bool {
+	if b.policy == BlockOnFull {
+		select {
+		case b.input <- line:
+			return true
+		default:
+			return false
+		}
+	}
+
+	select {
+	case b.input <- line:
+		return true
+	case <-b.done:
+		return false
+	case <-b.ctx.Done():
+		return false
+	}
+}
 
 func (b *Buffer) run() {
 	defer b.wg.Done()
 	ticker := time.NewTicker(b.flushInterval)
 	defer ticker.Stop()
 
-	batch := batchPool.Get().(*[][]byte)
+	batch := batchPool.Get().(*[]input.Line)
 	*batch = (*batch)[:0]
 
 	for {
@@ -128,8 +153,7 @@ func (b *Buffer) run() {
 			if len(*batch) >= b.maxSize {
 				switch b.policy {
 				case BlockOnFull:
-					b.emit(*batch, false)
-					*batch = (*batch)[:0]
+					*batch = append((*batch)[:0], b.emit(*batch, false)...)
 					ticker.Reset(b.flushInterval)
 				case DropNew:
 					continue
@@ -143,36 +167,40 @@ func (b *Buffer) run() {
 			*batch = append(*batch, msg)
 
 			if b.policy == BlockOnFull && len(*batch) >= b.maxSize {
-				b.emit(*batch, false)
-				*batch = (*batch)[:0]
+				*batch = append((*batch)[:0], b.emit(*batch, false)...)
 				ticker.Reset(b.flushInterval)
 			}
 
 		case <-ticker.C:
-			b.flushBatch(*batch)
-			*batch = (*batch)[:0]
+			*batch = append((*batch)[:0], b.emit(*batch, false)...)
 		}
 	}
 }
 
-// emit sends batch messages to output channel.
-// With force=true, sends all messages immediately.
-// Without force, sends with timeout (emitTimeout) and returns on timeout.
-func (b *Buffer) emit(batch [][]byte, force bool) {
-	for _, msg := range batch {
-		if force {
+// emit sends batch messages to the output channel. With force, or under
+// BlockOnFull, it waits for the consumer, so that a slow consumer fills the
+// input and holds back producers; otherwise messages that don't fit in the
+// output are dropped. It returns the messages left unsent because the buffer
+// is stopping, for the final forced emit.
+func (b *Buffer) emit(batch []input.Line, force bool) []input.Line {
+	for i, msg := range batch {
+		switch {
+		case force:
 			b.output <- msg
-		} else {
+		case b.policy == BlockOnFull:
+			select {
+			case b.output <- msg:
+			case <-b.done:
+				return batch[i:]
+			case <-b.ctx.Done():
+				return batch[i:]
+			}
+		default:
 			select {
 			case b.output <- msg:
 			default:
 			}
 		}
 	}
-}
-
-func (b *Buffer) flushBatch(batch [][]byte) {
-	if len(batch) > 0 {
-		b.emit(batch, false)
-	}
+	return nil
 }
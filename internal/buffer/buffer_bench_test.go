@@ -7,6 +7,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/kxrxh/logram/internal/input"
 )
 
 func BenchmarkBuffer_Throughput_BlockOnFull(b *testing.B) {
@@ -21,7 +23,7 @@ func BenchmarkBuffer_Throughput_BlockOnFull(b *testing.B) {
 
 	b.ResetTimer()
 	for range b.N {
-		buf.Input() <- []byte("benchmark-message")
+		buf.Input() <- line("benchmark-message")
 	}
 	buf.Stop()
 	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/sec")
@@ -35,7 +37,7 @@ func BenchmarkBuffer_Throughput_DropNew(b *testing.B) {
 
 	b.ResetTimer()
 	for range b.N {
-		buf.Input() <- []byte("benchmark-message")
+		buf.Input() <- line("benchmark-message")
 	}
 	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/sec")
 }
@@ -48,7 +50,7 @@ func BenchmarkBuffer_Throughput_DropOldest(b *testing.B) {
 
 	b.ResetTimer()
 	for range b.N {
-		buf.Input() <- []byte("benchmark-message")
+		buf.Input() <- line("benchmark-message")
 	}
 	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/sec")
 }
@@ -66,7 +68,7 @@ func BenchmarkBuffer_Throughput_FlushInterval(b *testing.B) {
 
 	b.ResetTimer()
 	for range b.N {
-		buf.Input() <- []byte("benchmark-message")
+		buf.Input() <- line("benchmark-message")
 	}
 	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/sec")
 }
@@ -78,7 +80,7 @@ func BenchmarkBuffer_Latency_Single(b *testing.B) {
 	defer buf.Stop()
 
 	for b.Loop() {
-		buf.Input() <- []byte("x")
+		buf.Input() <- line("x")
 		<-buf.Output()
 	}
 }
@@ -91,7 +93,7 @@ func BenchmarkBuffer_Latency_Batched(b *testing.B) {
 
 	b.ResetTimer()
 	for range b.N {
-		buf.Input() <- []byte("x")
+		buf.Input() <- line("x")
 	}
 	for range b.N {
 		<-buf.Output()
@@ -116,7 +118,7 @@ func BenchmarkBuffer_Concurrent_Writers(b *testing.B) {
 	b.ResetTimer()
 	for range numWriters {
 		wg.Go(func() {
-			msg := []byte("concurrent-message")
+			msg := line("concurrent-message")
 			for range b.N / numWriters {
 				buf.Input() <- msg
 			}
@@ -139,7 +141,7 @@ func BenchmarkBuffer_Memory_Allocations(b *testing.B) {
 		runtime.ReadMemStats(&mStatsBefore)
 
 		for range b.N {
-			buf.Input() <- []byte("memory-test")
+			buf.Input() <- line("memory-test")
 			<-buf.Output()
 		}
 
@@ -162,7 +164,7 @@ func BenchmarkBuffer_Memory_Allocations(b *testing.B) {
 		runtime.ReadMemStats(&mStatsBefore)
 
 		for range b.N {
-			buf.Input() <- []byte("memory-test")
+			buf.Input() <- line("memory-test")
 		}
 
 		runtime.ReadMemStats(&mStatsAfter)
@@ -184,7 +186,7 @@ func BenchmarkBuffer_Memory_Allocations(b *testing.B) {
 		runtime.ReadMemStats(&mStatsBefore)
 
 		for range b.N {
-			buf.Input() <- []byte("memory-test")
+			buf.Input() <- line("memory-test")
 		}
 
 		runtime.ReadMemStats(&mStatsAfter)
@@ -212,7 +214,7 @@ func BenchmarkBuffer_Memory_GCPressure(b *testing.B) {
 	runtime.ReadMemStats(&mStatsBefore)
 
 	for b.Loop() {
-		buf.Input() <- make([]byte, 1024)
+		buf.Input() <- input.Line{Data: make([]byte, 1024)}
 	}
 	b.StopTimer()
 
@@ -234,7 +236,7 @@ func BenchmarkBuffer_PayloadSizes(b *testing.B) {
 			buf.Start()
 			defer buf.Stop()
 
-			msg := make([]byte, size)
+			msg := input.Line{Data: make([]byte, size)}
 			b.ResetTimer()
 			for range b.N {
 				buf.Input() <- msg
@@ -262,7 +264,7 @@ func BenchmarkBuffer_BufferSizes(b *testing.B) {
 
 			b.ResetTimer()
 			for range b.N {
-				buf.Input() <- []byte("msg")
+				buf.Input() <- line("msg")
 			}
 			b.ReportMetric(float64(size), "buffer_size")
 		})
@@ -284,7 +286,7 @@ func BenchmarkBuffer_PoolUsage(b *testing.B) {
 	runtime.ReadMemStats(&statsBefore)
 
 	for range b.N {
-		buf.Input() <- []byte("pool-test")
+		buf.Input() <- line("pool-test")
 	}
 	runtime.GC()
 	runtime.ReadMemStats(&statsAfter)
@@ -299,7 +301,7 @@ func BenchmarkBuffer_StopOverhead(b *testing.B) {
 			ctx := context.Background()
 			buf := New(ctx, 1000, time.Hour)
 			buf.Start()
-			buf.Input() <- []byte("cleanup")
+			buf.Input() <- line("cleanup")
 			buf.Stop()
 		}
 	})
@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"github.com/kxrxh/logram/internal/input"
 )
 
 func TestNew_Defaults(t *testing.T) {
@@ -32,16 +34,16 @@ func TestBuffer_Policy_DropNew(t *testing.T) {
 	buf := New(t.Context(), 2, time.Hour, WithPolicy(DropNew))
 	buf.Start()
 
-	buf.Input() <- []byte("1")
-	buf.Input() <- []byte("2")
-	buf.Input() <- []byte("3") // Should be dropped
+	buf.Input() <- line("1")
+	buf.Input() <- line("2")
+	buf.Input() <- line("3") // Should be dropped
 
 	buf.Stop()
 
-	if string(<-buf.Output()) != "1" {
+	if string((<-buf.Output()).Data) != "1" {
 		t.Error("expected 1")
 	}
-	if string(<-buf.Output()) != "2" {
+	if string((<-buf.Output()).Data) != "2" {
 		t.Error("expected 2")
 	}
 
@@ -55,16 +57,16 @@ func TestBuffer_Policy_DropOldest(t *testing.T) {
 	buf := New(t.Context(), 2, time.Hour, WithPolicy(DropOldest))
 	buf.Start()
 
-	buf.Input() <- []byte("1")
-	buf.Input() <- []byte("2")
-	buf.Input() <- []byte("3") // Should drop "1"
+	buf.Input() <- line("1")
+	buf.Input() <- line("2")
+	buf.Input() <- line("3") // Should drop "1"
 
 	buf.Stop()
 
-	if string(<-buf.Output()) != "2" {
+	if string((<-buf.Output()).Data) != "2" {
 		t.Error("expected 2")
 	}
-	if string(<-buf.Output()) != "3" {
+	if string((<-buf.Output()).Data) != "3" {
 		t.Error("expected 3")
 	}
 }
@@ -74,31 +76,71 @@ func TestBuffer_Policy_BlockOnFull(t *testing.T) {
 	buf.Start()
 	defer buf.Stop()
 
-	buf.Input() <- []byte("1")
-	buf.Input() <- []byte("2") // Should trigger automatic flush
+	buf.Input() <- line("1")
+	buf.Input() <- line("2") // Should trigger automatic flush
 
 	select {
 	case m := <-buf.Output():
-		if string(m) != "1" {
-			t.Errorf("expected 1, got %s", m)
+		if string(m.Data) != "1" {
+			t.Errorf("expected 1, got %s", m.Data)
 		}
 	case <-time.After(100 * time.Millisecond):
 		t.Error("BlockOnFull failed to flush when full")
 	}
 }
 
+func TestBuffer_TrySend_BlockOnFull(t *testing.T) {
+	// Not started, so nothing drains the input
+	buf := New(t.Context(), 2, time.Hour)
+
+	if !buf.TrySend(line("1")) || !buf.TrySend(line("2")) {
+		t.Fatal("expected TrySend to accept while the input has room")
+	}
+	if buf.TrySend(line("3")) {
+		t.Error("expected TrySend to report a full input")
+	}
+}
+
+func TestBuffer_TrySend_StalledConsumer(t *testing.T) {
+	buf := New(t.Context(), 2, time.Hour)
+	buf.Start()
+
+	accepted := 0
+	deadline := time.Now().Add(time.Second)
+	for buf.TrySend(line("x")) {
+		accepted++
+		if time.Now().After(deadline) {
+			t.Fatalf("TrySend kept accepting with nobody reading, %d accepted", accepted)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Everything accepted reaches the consumer once it reads again
+	for range accepted {
+		select {
+		case <-buf.Output():
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d messages, lost some", accepted)
+		}
+	}
+	buf.Stop()
+	if _, ok := <-buf.Output(); ok {
+		t.Error("expected no more messages than were accepted")
+	}
+}
+
 func TestBuffer_FlushOnInterval(t *testing.T) {
 	interval := 50 * time.Millisecond
 	buf := New(t.Context(), 100, interval)
 	buf.Start()
 	defer buf.Stop()
 
-	buf.Input() <- []byte("timed-msg")
+	buf.Input() <- line("timed-msg")
 
 	select {
 	case msg := <-buf.Output():
-		if string(msg) != "timed-msg" {
-			t.Errorf("got %s", msg)
+		if string(msg.Data) != "timed-msg" {
+			t.Errorf("got %s", msg.Data)
 		}
 	case <-time.After(interval * 3):
 		t.Error("flush interval exceeded")
@@ -109,13 +151,13 @@ func TestBuffer_StopDrainsRemaining(t *testing.T) {
 	buf := New(t.Context(), 10, time.Hour)
 	buf.Start()
 
-	buf.Input() <- []byte("drain-me")
+	buf.Input() <- line("drain-me")
 	buf.Stop()
 
 	select {
 	case msg := <-buf.Output():
-		if string(msg) != "drain-me" {
-			t.Errorf("expected drain-me, got %q", msg)
+		if string(msg.Data) != "drain-me" {
+			t.Errorf("expected drain-me, got %q", msg.Data)
 		}
 	default:
 		t.Error("message was lost during Stop")
@@ -142,3 +184,7 @@ func TestBuffer_ContextCancellation(t *testing.T) {
 		t.Error("Output channel should be closed")
 	}
 }
+
+func line(s string) input.Line {
+	return input.Line{Data: []byte(s)}
+}
@@ -15,7 +15,14 @@ type Config struct {
 	Parser   ParserConfig   `mapstructure:"parser"`
 	Database DatabaseConfig `mapstructure:"database"`
 	Logs     LogsConfig     `mapstructure:"logs"`
+	Syslog   SyslogConfig   `mapstructure:"syslog"`
+	HTTP     HTTPConfig     `mapstructure:"http"`
+	Redact   RedactConfig   `mapstructure:"redact"`
+	Dedup    DedupConfig    `mapstructure:"dedup"`
+	Anomaly  AnomalyConfig  `mapstructure:"anomaly"`
 	Batch    BatchConfig    `mapstructure:"batch"`
+	// Escalation notifies more chats about incidents nobody acknowledges.
+	Escalation EscalationConfig `mapstructure:"escalation"`
 }
 
 type BotConfig struct {
@@ -25,10 +32,141 @@ type BotConfig struct {
 
 type ParserConfig struct {
 	Rules []Rule `mapstructure:"rules"`
+	// Workers is how many lines are parsed at once; 0 means one.
+	Workers     int              `mapstructure:"workers"`
+	DeadLetters DeadLetterConfig `mapstructure:"dead_letters"`
+}
+
+// DeadLetterConfig sets when to alert about lines that fail to parse.
+type DeadLetterConfig struct {
+	// AlertRatio is the share of rejected lines to alert at; 0 turns the
+	// alert off.
+	AlertRatio float64       `mapstructure:"alert_ratio"`
+	Window     time.Duration `mapstructure:"window"`
+	MinLines   uint64        `mapstructure:"min_lines"`
 }
 
 type LogsConfig struct {
-	Path string `mapstructure:"path"`
+	// Path is a single log file, read as an unnamed source.
+	Path    string         `mapstructure:"path"`
+	Sources []SourceConfig `mapstructure:"sources"`
+}
+
+type SourceConfig struct {
+	Name string `mapstructure:"name"`
+	// Glob matches the files to tail. Files and directories created later
+	// that it matches are picked up too, e.g. with "/var/log/*/app.log".
+	Glob      string            `mapstructure:"glob"`
+	Labels    map[string]string `mapstructure:"labels"`
+	Multiline MultilineConfig   `mapstructure:"multiline"`
+	// Format is "default", "json", "logfmt" or "regex".
+	Format string            `mapstructure:"format"`
+	JSON   JSONFormatConfig  `mapstructure:"json"`
+	Regex  RegexFormatConfig `mapstructure:"regex"`
+	Time   TimeConfig        `mapstructure:"time"`
+	// Passthrough keeps lines that don't fit the format, as raw text.
+	Passthrough bool `mapstructure:"passthrough"`
+	// ExpectEvery alerts when the source logs nothing for this long.
+	ExpectEvery time.Duration `mapstructure:"expect_every"`
+
+	// Redact replaces the top-level redaction settings for this source.
+	Redact *RedactConfig `mapstructure:"redact"`
+}
+
+// TimeConfig controls how a source's timestamps are read.
+type TimeConfig struct {
+	// Layouts are Go time layouts or "unix", "unix_ms", "unix_us", "unix_ns",
+	// tried before the built-in layouts.
+	Layouts []string `mapstructure:"layouts"`
+	// Timezone is the IANA zone of timestamps without an offset.
+	Timezone string `mapstructure:"timezone"`
+	// ReadTimeFallback keeps lines with unparseable timestamps, stamped
+	// with the time they were read.
+	ReadTimeFallback bool `mapstructure:"read_time_fallback"`
+}
+
+// JSONFormatConfig overrides the keys read from JSON lines.
+type JSONFormatConfig struct {
+	TimeKey    string `mapstructure:"time_key"`
+	LevelKey   string `mapstructure:"level_key"`
+	MessageKey string `mapstructure:"message_key"`
+}
+
+// RegexFormatConfig describes lines with a pattern with ts, level and msg
+// named groups.
+type RegexFormatConfig struct {
+	Pattern    string `mapstructure:"pattern"`
+	TimeLayout string `mapstructure:"time_layout"`
+}
+
+type MultilineConfig struct {
+	StartPattern string        `mapstructure:"start_pattern"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	MaxLines     int           `mapstructure:"max_lines"`
+	MaxBytes     int           `mapstructure:"max_bytes"`
+}
+
+// SyslogConfig enables the syslog receiver when UDP or TCP is set.
+type SyslogConfig struct {
+	Name string `mapstructure:"name"`
+	UDP  string `mapstructure:"udp"`
+	TCP  string `mapstructure:"tcp"`
+}
+
+// HTTPConfig enables the HTTP ingest endpoint when Addr is set.
+type HTTPConfig struct {
+	Addr   string `mapstructure:"addr"`
+	Token  string `mapstructure:"token"`
+	Source string `mapstructure:"source"`
+}
+
+// RedactConfig masks secrets in entries before they are sent.
+type RedactConfig struct {
+	// Builtin names detectors to enable: bearer, password, email, card.
+	Builtin []string           `mapstructure:"builtin"`
+	Rules   []RedactRuleConfig `mapstructure:"rules"`
+}
+
+type RedactRuleConfig struct {
+	Pattern     string `mapstructure:"pattern"`
+	Replacement string `mapstructure:"replacement"`
+}
+
+// DedupConfig sets how long repeats of an entry are held back in a chat.
+type DedupConfig struct {
+	// Window is the default for chats without their own; 0 turns it off.
+	Window time.Duration `mapstructure:"window"`
+}
+
+// AnomalyConfig alerts when a rule matches far more or less often than it
+// usually does at that hour of the day.
+type AnomalyConfig struct {
+	// Factor is how far from the baseline a rate must be; 0 turns it off.
+	Factor     float64       `mapstructure:"factor"`
+	Bucket     time.Duration `mapstructure:"bucket"`
+	Alpha      float64       `mapstructure:"alpha"`
+	MinSamples int           `mapstructure:"min_samples"`
+	MinCount   float64       `mapstructure:"min_count"`
+}
+
+type EscalationConfig struct {
+	Policies []EscalationPolicyConfig `mapstructure:"policies"`
+}
+
+// EscalationPolicyConfig escalates the incidents of a chat, tier by tier.
+type EscalationPolicyConfig struct {
+	// Chat is whose incidents are escalated. Chats without a policy don't
+	// escalate, and /escalate may only notify chats some policy notifies.
+	Chat  int64                  `mapstructure:"chat"`
+	Tiers []EscalationTierConfig `mapstructure:"tiers"`
+}
+
+// EscalationTierConfig notifies chats about an incident that is still not
+// acknowledged After it was opened. A user's ID is their private chat with
+// the bot.
+type EscalationTierConfig struct {
+	After time.Duration `mapstructure:"after"`
+	Chats []int64       `mapstructure:"chats"`
 }
 
 type BatchConfig struct {
@@ -40,6 +178,20 @@ type BatchConfig struct {
 type Rule struct {
 	Name    string `mapstructure:"name"`
 	Pattern string `mapstructure:"pattern"`
+	// MinLevel limits the rule to entries at this level or above.
+	MinLevel string `mapstructure:"min_level"`
+	// Passthrough keeps matching lines that can't be parsed, as raw text.
+	Passthrough bool `mapstructure:"passthrough"`
+	// Threshold makes chats get an alert when the rule matches count times
+	// within window, instead of every match.
+	Threshold ThresholdConfig `mapstructure:"threshold"`
+	// ExpectEvery alerts when the rule has no match for this long.
+	ExpectEvery time.Duration `mapstructure:"expect_every"`
+}
+
+type ThresholdConfig struct {
+	Count  int           `mapstructure:"count"`
+	Window time.Duration `mapstructure:"window"`
 }
 
 type DatabaseConfig struct {
@@ -75,6 +227,12 @@ func (c *Config) Watch(onChange func(*Config)) {
 		c.Parser = cfg.Parser
 		c.Database = cfg.Database
 		c.Logs = cfg.Logs
+		c.Syslog = cfg.Syslog
+		c.HTTP = cfg.HTTP
+		c.Redact = cfg.Redact
+		c.Dedup = cfg.Dedup
+		c.Anomaly = cfg.Anomaly
+		c.Escalation = cfg.Escalation
 		c.Batch = cfg.Batch
 		c.mu.Unlock()
 		onChange(&cfg)
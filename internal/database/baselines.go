@@ -0,0 +1,31 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm/clause"
+)
+
+// SaveRateBaselines inserts or replaces rate baselines.
+func (db *DB) SaveRateBaselines(baselines []RateBaseline) error {
+	if len(baselines) == 0 {
+		return nil
+	}
+	result := db.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "rule"}, {Name: "hour"}},
+		DoUpdates: clause.AssignmentColumns([]string{"mean", "samples"}),
+	}).Create(&baselines)
+	if result.Error != nil {
+		return fmt.Errorf("save %d rate baselines: %w", len(baselines), result.Error)
+	}
+	return nil
+}
+
+func (db *DB) GetAllRateBaselines() ([]RateBaseline, error) {
+	var baselines []RateBaseline
+	result := db.db.Find(&baselines)
+	if result.Error != nil {
+		return nil, fmt.Errorf("get all rate baselines: %w", result.Error)
+	}
+	return baselines, nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import "testing"
+
+func TestRateBaselines_SaveAndGet(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	baselines := []RateBaseline{
+		{Rule: "errors", Hour: 3, Mean: 1.5, Samples: 2},
+		{Rule: "errors", Hour: 4, Mean: 7, Samples: 1},
+	}
+	if err := db.SaveRateBaselines(baselines); err != nil {
+		t.Fatalf("SaveRateBaselines failed: %v", err)
+	}
+	updated := []RateBaseline{{Rule: "errors", Hour: 3, Mean: 2.25, Samples: 3}}
+	if err := db.SaveRateBaselines(updated); err != nil {
+		t.Fatalf("SaveRateBaselines update failed: %v", err)
+	}
+
+	got, err := db.GetAllRateBaselines()
+	if err != nil {
+		t.Fatalf("GetAllRateBaselines failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 baselines, got %+v", got)
+	}
+	for _, b := range got {
+		switch b.Hour {
+		case 3:
+			if b.Mean != 2.25 || b.Samples != 3 {
+				t.Errorf("expected hour 3 updated, got %+v", b)
+			}
+		case 4:
+			if b.Mean != 7 || b.Samples != 1 {
+				t.Errorf("expected hour 4 unchanged, got %+v", b)
+			}
+		default:
+			t.Errorf("unexpected baseline: %+v", b)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"time"
 
 	"gorm.io/gorm/clause"
 )
@@ -70,3 +71,25 @@ func (db *DB) DeleteAllChatRegexRules(chatID int64) error {
 	}
 	return nil
 }
+
+// SetChatRegexRuleThreshold sets or, with a zero count, clears the rule's
+// threshold. It reports whether the rule exists.
+func (db *DB) SetChatRegexRuleThreshold(
+	chatID int64,
+	name string,
+	count int,
+	window time.Duration,
+) (bool, error) {
+	result := db.db.Model(&ChatRegexRule{}).
+		Where("chat_id = ? AND name = ?", chatID, name).
+		Updates(map[string]any{"threshold_count": count, "threshold_window": window})
+	if result.Error != nil {
+		return false, fmt.Errorf(
+			"set chat regex rule threshold (chat_id=%d, name=%q): %w",
+			chatID,
+			name,
+			result.Error,
+		)
+	}
+	return result.RowsAffected > 0, nil
+}
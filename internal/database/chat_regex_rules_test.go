@@ -1,6 +1,9 @@
 package database
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestChatRegexRule_UpsertAndGet(t *testing.T) {
 	db := setupTestDB(t)
@@ -125,3 +128,33 @@ func TestChatRegexRule_DeleteAllAndGetAll(t *testing.T) {
 		t.Fatalf("unexpected rules for chatID2: %+v", rules2)
 	}
 }
+
+func TestChatRegexRule_SetThreshold(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	if err := db.UpsertChatRegexRule(1, "timeouts", "timeout"); err != nil {
+		t.Fatalf("UpsertChatRegexRule failed: %v", err)
+	}
+
+	found, err := db.SetChatRegexRuleThreshold(1, "timeouts", 50, time.Minute)
+	if err != nil || !found {
+		t.Fatalf("SetChatRegexRuleThreshold = %v, %v; want true, nil", found, err)
+	}
+	if found, err := db.SetChatRegexRuleThreshold(1, "missing", 5, time.Minute); err != nil || found {
+		t.Fatalf("SetChatRegexRuleThreshold(missing) = %v, %v; want false, nil", found, err)
+	}
+
+	// Changing the pattern keeps the threshold
+	if err := db.UpsertChatRegexRule(1, "timeouts", "(?i)timeout"); err != nil {
+		t.Fatalf("UpsertChatRegexRule failed: %v", err)
+	}
+
+	rules, err := db.GetChatRegexRules(1)
+	if err != nil {
+		t.Fatalf("GetChatRegexRules failed: %v", err)
+	}
+	if len(rules) != 1 || rules[0].ThresholdCount != 50 || rules[0].ThresholdWindow != time.Minute {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+}
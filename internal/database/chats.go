@@ -3,6 +3,7 @@ package database
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -71,3 +72,113 @@ func (db *DB) GetChatBatchEnabled(chatID int64) (bool, error) {
 	}
 	return chat.BatchEnabled, nil
 }
+
+// SetChatMinLevel stores the least severe level the chat receives; empty
+// means every level.
+func (db *DB) SetChatMinLevel(chatID int64, level string) error {
+	result := db.db.Model(&Chat{}).
+		Where("chat_id = ?", chatID).
+		Update("min_level", level)
+	if result.Error != nil {
+		return fmt.Errorf("set chat min_level (chat_id=%d): %w", chatID, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		if err := db.db.FirstOrCreate(
+			&Chat{ChatID: chatID, MinLevel: level},
+		).Error; err != nil {
+			return fmt.Errorf("create chat for min_level (chat_id=%d): %w", chatID, err)
+		}
+	}
+
+	return nil
+}
+
+// SetChatDedupWindow stores the chat's dedup window; zero means the
+// configured one and negative turns dedup off.
+func (db *DB) SetChatDedupWindow(chatID int64, window time.Duration) error {
+	result := db.db.Model(&Chat{}).
+		Where("chat_id = ?", chatID).
+		Update("dedup_window", window)
+	if result.Error != nil {
+		return fmt.Errorf("set chat dedup_window (chat_id=%d): %w", chatID, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		if err := db.db.FirstOrCreate(
+			&Chat{ChatID: chatID, DedupWindow: window},
+		).Error; err != nil {
+			return fmt.Errorf("create chat for dedup_window (chat_id=%d): %w", chatID, err)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) SetChatNewOnly(chatID int64, enabled bool) error {
+	result := db.db.Model(&Chat{}).
+		Where("chat_id = ?", chatID).
+		Update("new_only", enabled)
+	if result.Error != nil {
+		return fmt.Errorf("set chat new_only (chat_id=%d): %w", chatID, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		if err := db.db.FirstOrCreate(
+			&Chat{ChatID: chatID, NewOnly: enabled},
+		).Error; err != nil {
+			return fmt.Errorf("create chat for new_only (chat_id=%d): %w", chatID, err)
+		}
+	}
+
+	return nil
+}
+
+// SetChatDelivery stores how the chat receives entries and, for digests,
+// when. BatchEnabled follows the mode.
+func (db *DB) SetChatDelivery(chatID int64, mode, schedule string) error {
+	result := db.db.Model(&Chat{}).
+		Where("chat_id = ?", chatID).
+		Updates(map[string]any{
+			"delivery_mode":   mode,
+			"digest_schedule": schedule,
+			"batch_enabled":   mode == "batch",
+		})
+	if result.Error != nil {
+		return fmt.Errorf("set chat delivery (chat_id=%d): %w", chatID, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		if err := db.db.FirstOrCreate(&Chat{
+			ChatID:         chatID,
+			DeliveryMode:   mode,
+			DigestSchedule: schedule,
+			BatchEnabled:   mode == "batch",
+		}).Error; err != nil {
+			return fmt.Errorf("create chat for delivery (chat_id=%d): %w", chatID, err)
+		}
+	}
+
+	return nil
+}
+
+// SetChatEscalationPolicy stores the chat's escalation policy; empty means
+// the configured one.
+func (db *DB) SetChatEscalationPolicy(chatID int64, policy string) error {
+	result := db.db.Model(&Chat{}).
+		Where("chat_id = ?", chatID).
+		Update("escalation_policy", policy)
+	if result.Error != nil {
+		return fmt.Errorf("set chat escalation_policy (chat_id=%d): %w", chatID, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		if err := db.db.FirstOrCreate(
+			&Chat{ChatID: chatID, EscalationPolicy: policy},
+		).Error; err != nil {
+			return fmt.Errorf("create chat for escalation_policy (chat_id=%d): %w", chatID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetChatMinLevel(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	// Creates the chat when it doesn't exist yet
+	if err := db.SetChatMinLevel(100, "WARN"); err != nil {
+		t.Fatalf("SetChatMinLevel failed: %v", err)
+	}
+	if err := db.AddChat(200, "other"); err != nil {
+		t.Fatalf("AddChat failed: %v", err)
+	}
+	if err := db.SetChatMinLevel(200, "ERROR"); err != nil {
+		t.Fatalf("SetChatMinLevel failed: %v", err)
+	}
+	if err := db.SetChatMinLevel(200, ""); err != nil {
+		t.Fatalf("SetChatMinLevel failed: %v", err)
+	}
+
+	chats, err := db.GetAllChats()
+	if err != nil {
+		t.Fatalf("GetAllChats failed: %v", err)
+	}
+	levels := make(map[int64]string, len(chats))
+	for _, c := range chats {
+		levels[c.ChatID] = c.MinLevel
+	}
+	if levels[100] != "WARN" || levels[200] != "" || len(levels) != 2 {
+		t.Errorf("unexpected min levels: %v", levels)
+	}
+}
+
+func TestSetChatDedupWindow(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	if err := db.SetChatDedupWindow(100, 10*time.Minute); err != nil {
+		t.Fatalf("SetChatDedupWindow failed: %v", err)
+	}
+	if err := db.SetChatDedupWindow(200, -1); err != nil {
+		t.Fatalf("SetChatDedupWindow failed: %v", err)
+	}
+	if err := db.SetChatDedupWindow(200, 0); err != nil {
+		t.Fatalf("SetChatDedupWindow failed: %v", err)
+	}
+
+	chats, err := db.GetAllChats()
+	if err != nil {
+		t.Fatalf("GetAllChats failed: %v", err)
+	}
+	windows := make(map[int64]time.Duration, len(chats))
+	for _, c := range chats {
+		windows[c.ChatID] = c.DedupWindow
+	}
+	if windows[100] != 10*time.Minute || windows[200] != 0 || len(windows) != 2 {
+		t.Errorf("unexpected dedup windows: %v", windows)
+	}
+}
+
+func TestSetChatDelivery(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	if err := db.SetChatDelivery(100, "batch", ""); err != nil {
+		t.Fatalf("SetChatDelivery failed: %v", err)
+	}
+	if enabled, _ := db.GetChatBatchEnabled(100); !enabled {
+		t.Fatalf("expected batch mode to enable batching")
+	}
+	if err := db.SetChatDelivery(100, "digest", "0 9 * * *"); err != nil {
+		t.Fatalf("SetChatDelivery failed: %v", err)
+	}
+
+	chats, err := db.GetAllChats()
+	if err != nil {
+		t.Fatalf("GetAllChats failed: %v", err)
+	}
+	if len(chats) != 1 {
+		t.Fatalf("expected 1 chat, got %d", len(chats))
+	}
+	c := chats[0]
+	if c.DeliveryMode != "digest" || c.DigestSchedule != "0 9 * * *" || c.BatchEnabled {
+		t.Errorf("unexpected delivery settings: %+v", c)
+	}
+}
+
+func TestSetChatEscalationPolicy(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	if err := db.SetChatEscalationPolicy(100, "10m -200"); err != nil {
+		t.Fatalf("SetChatEscalationPolicy failed: %v", err)
+	}
+	if err := db.SetChatEscalationPolicy(100, "off"); err != nil {
+		t.Fatalf("SetChatEscalationPolicy failed: %v", err)
+	}
+
+	chats, err := db.GetAllChats()
+	if err != nil {
+		t.Fatalf("GetAllChats failed: %v", err)
+	}
+	if len(chats) != 1 || chats[0].EscalationPolicy != "off" {
+		t.Errorf("unexpected chats: %+v", chats)
+	}
+}
@@ -17,7 +17,15 @@ func New(path string) (*DB, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := db.AutoMigrate(&Subscription{}, &Chat{}, &ChatRegexRule{}); err != nil {
+	if err := db.AutoMigrate(
+		&Subscription{},
+		&Chat{},
+		&ChatRegexRule{},
+		&ReaderCheckpoint{},
+		&Fingerprint{},
+		&RateBaseline{},
+		&Incident{},
+	); err != nil {
 		return nil, fmt.Errorf("failed to migrate: %w", err)
 	}
 
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fingerprintBatchSize bounds the rows written or deleted per statement, so
+// that a statement stays under SQLite's limit of bound variables.
+const fingerprintBatchSize = 100
+
+// SaveFingerprints inserts fingerprints or updates their counts and last seen
+// times.
+func (db *DB) SaveFingerprints(fps []Fingerprint) error {
+	if len(fps) == 0 {
+		return nil
+	}
+	result := db.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"count", "last_seen"}),
+	}).CreateInBatches(&fps, fingerprintBatchSize)
+	if result.Error != nil {
+		return fmt.Errorf("save %d fingerprints: %w", len(fps), result.Error)
+	}
+	return nil
+}
+
+// DeleteFingerprints deletes the fingerprints with the given hashes.
+func (db *DB) DeleteFingerprints(hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	err := db.db.Transaction(func(tx *gorm.DB) error {
+		for batch := range slices.Chunk(hashes, fingerprintBatchSize) {
+			if err := tx.Where("hash IN ?", batch).Delete(&Fingerprint{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete %d fingerprints: %w", len(hashes), err)
+	}
+	return nil
+}
+
+func (db *DB) GetAllFingerprints() ([]Fingerprint, error) {
+	var fps []Fingerprint
+	result := db.db.Find(&fps)
+	if result.Error != nil {
+		return nil, fmt.Errorf("get all fingerprints: %w", result.Error)
+	}
+	return fps, nil
+}
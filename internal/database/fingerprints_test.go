@@ -0,0 +1,109 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFingerprints_SaveAndGet(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	fp := Fingerprint{
+		Hash:      "9f86d081884c7d65",
+		Source:    "api",
+		Level:     "ERROR",
+		Template:  "timeout after <num>s",
+		Count:     1,
+		FirstSeen: first,
+		LastSeen:  first,
+	}
+	if err := db.SaveFingerprints([]Fingerprint{fp}); err != nil {
+		t.Fatalf("SaveFingerprints failed: %v", err)
+	}
+
+	fp.Count = 5
+	fp.FirstSeen = first.Add(time.Hour)
+	fp.LastSeen = first.Add(time.Minute)
+	if err := db.SaveFingerprints([]Fingerprint{fp}); err != nil {
+		t.Fatalf("SaveFingerprints update failed: %v", err)
+	}
+
+	fps, err := db.GetAllFingerprints()
+	if err != nil {
+		t.Fatalf("GetAllFingerprints failed: %v", err)
+	}
+	if len(fps) != 1 {
+		t.Fatalf("expected 1 fingerprint, got %d", len(fps))
+	}
+	got := fps[0]
+	if got.Count != 5 || !got.LastSeen.Equal(fp.LastSeen) || !got.FirstSeen.Equal(first) {
+		t.Errorf("expected count and last seen updated, first seen kept, got %+v", got)
+	}
+	if got.Template != "timeout after <num>s" || got.Source != "api" {
+		t.Errorf("unexpected fingerprint: %+v", got)
+	}
+}
+
+func TestFingerprints_Delete(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	seen := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	fps := []Fingerprint{
+		{Hash: "a", Source: "api", Level: "ERROR", Count: 1, FirstSeen: seen, LastSeen: seen},
+		{Hash: "b", Source: "api", Level: "ERROR", Count: 1, FirstSeen: seen, LastSeen: seen},
+		{Hash: "c", Source: "api", Level: "ERROR", Count: 1, FirstSeen: seen, LastSeen: seen},
+	}
+	if err := db.SaveFingerprints(fps); err != nil {
+		t.Fatalf("SaveFingerprints failed: %v", err)
+	}
+	if err := db.DeleteFingerprints([]string{"a", "c"}); err != nil {
+		t.Fatalf("DeleteFingerprints failed: %v", err)
+	}
+
+	got, err := db.GetAllFingerprints()
+	if err != nil {
+		t.Fatalf("GetAllFingerprints failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Hash != "b" {
+		t.Errorf("expected only fingerprint b left, got %+v", got)
+	}
+}
+
+func TestFingerprints_SaveAndDeleteMany(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	seen := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	fps := make([]Fingerprint, 5000)
+	hashes := make([]string, len(fps))
+	for i := range fps {
+		hashes[i] = fmt.Sprintf("%016x", i)
+		fps[i] = Fingerprint{Hash: hashes[i], Source: "api", Level: "ERROR", Count: 1,
+			FirstSeen: seen, LastSeen: seen}
+	}
+	if err := db.SaveFingerprints(fps); err != nil {
+		t.Fatalf("SaveFingerprints failed: %v", err)
+	}
+	// Saving again goes through the conflict update
+	if err := db.SaveFingerprints(fps); err != nil {
+		t.Fatalf("SaveFingerprints update failed: %v", err)
+	}
+	got, err := db.GetAllFingerprints()
+	if err != nil {
+		t.Fatalf("GetAllFingerprints failed: %v", err)
+	}
+	if len(got) != len(fps) {
+		t.Fatalf("expected %d fingerprints, got %d", len(fps), len(got))
+	}
+
+	if err := db.DeleteFingerprints(hashes[:4500]); err != nil {
+		t.Fatalf("DeleteFingerprints failed: %v", err)
+	}
+	if got, _ = db.GetAllFingerprints(); len(got) != 500 {
+		t.Errorf("expected 500 fingerprints left, got %d", len(got))
+	}
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// CreateIncident saves a new incident and sets its ID.
+func (db *DB) CreateIncident(inc *Incident) error {
+	if err := db.db.Create(inc).Error; err != nil {
+		return fmt.Errorf("create incident (chat_id=%d): %w", inc.ChatID, err)
+	}
+	return nil
+}
+
+func (db *DB) SaveIncident(inc *Incident) error {
+	if err := db.db.Save(inc).Error; err != nil {
+		return fmt.Errorf("save incident %d: %w", inc.ID, err)
+	}
+	return nil
+}
+
+// GetActiveIncidents returns the incidents that are not resolved or are
+// muted past now.
+func (db *DB) GetActiveIncidents(now time.Time) ([]Incident, error) {
+	var incidents []Incident
+	result := db.db.
+		Where("state <> ? OR muted_until > ?", IncidentResolved, now).
+		Order("id").
+		Find(&incidents)
+	if result.Error != nil {
+		return nil, fmt.Errorf("get active incidents: %w", result.Error)
+	}
+	return incidents, nil
+}
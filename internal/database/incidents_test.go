@@ -0,0 +1,56 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIncidents_CreateSaveAndGetActive(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	open := Incident{ChatID: 100, Key: "fp:a", Text: "boom", State: IncidentOpen, OpenedAt: now}
+	resolved := Incident{ChatID: 100, Key: "fp:b", State: IncidentResolved, OpenedAt: now}
+	muted := Incident{
+		ChatID:     200,
+		Key:        "fp:c",
+		State:      IncidentResolved,
+		MutedUntil: now.Add(time.Hour),
+	}
+	for _, inc := range []*Incident{&open, &resolved, &muted} {
+		if err := db.CreateIncident(inc); err != nil {
+			t.Fatalf("CreateIncident failed: %v", err)
+		}
+	}
+	if open.ID == 0 || resolved.ID == open.ID {
+		t.Fatalf("expected distinct IDs, got %d and %d", open.ID, resolved.ID)
+	}
+
+	open.State = IncidentAcknowledged
+	open.AckedBy = "@alice"
+	open.MessageID = 42
+	if err := db.SaveIncident(&open); err != nil {
+		t.Fatalf("SaveIncident failed: %v", err)
+	}
+
+	active, err := db.GetActiveIncidents(now)
+	if err != nil {
+		t.Fatalf("GetActiveIncidents failed: %v", err)
+	}
+	if len(active) != 2 || active[0].ID != open.ID || active[1].ID != muted.ID {
+		t.Fatalf("expected the open and the muted incident, got %+v", active)
+	}
+	if got := active[0]; got.State != IncidentAcknowledged || got.AckedBy != "@alice" ||
+		got.MessageID != 42 || got.Text != "boom" {
+		t.Errorf("unexpected incident: %+v", got)
+	}
+
+	active, err = db.GetActiveIncidents(now.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetActiveIncidents failed: %v", err)
+	}
+	if len(active) != 1 {
+		t.Errorf("expected the mute to expire, got %+v", active)
+	}
+}
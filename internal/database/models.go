@@ -8,14 +8,95 @@ type Subscription struct {
 }
 
 type Chat struct {
-	ChatID       int64     `gorm:"primaryKey"`
-	Title        string    `gorm:"default:''"`
-	BatchEnabled bool      `gorm:"default:false"`
-	AddedAt      time.Time `gorm:"autoCreateTime"`
+	ChatID       int64  `gorm:"primaryKey"`
+	Title        string `gorm:"default:''"`
+	BatchEnabled bool   `gorm:"default:false"`
+	MinLevel     string `gorm:"default:''"`
+	// DedupWindow overrides the configured dedup window; zero uses it and
+	// negative turns dedup off.
+	DedupWindow time.Duration `gorm:"default:0"`
+	// NewOnly limits the chat to entries with fingerprints not seen before.
+	NewOnly bool `gorm:"default:false"`
+	// DeliveryMode is "realtime", "batch" or "digest"; empty follows
+	// BatchEnabled, which is kept for chats saved before it.
+	DeliveryMode string `gorm:"default:''"`
+	// DigestSchedule is when digest chats get their report.
+	DigestSchedule string `gorm:"default:''"`
+	// EscalationPolicy overrides the configured escalation of the chat's
+	// incidents; empty uses it and "off" turns it off.
+	EscalationPolicy string    `gorm:"default:''"`
+	AddedAt          time.Time `gorm:"autoCreateTime"`
 }
 
 type ChatRegexRule struct {
 	ChatID  int64  `gorm:"primaryKey;column:chat_id"`
 	Name    string `gorm:"primaryKey;column:name"`
 	Pattern string `gorm:"column:pattern"`
+	// ThresholdCount matches within ThresholdWindow raise an alert; zero
+	// sends every match.
+	ThresholdCount  int           `gorm:"column:threshold_count;default:0"`
+	ThresholdWindow time.Duration `gorm:"column:threshold_window;default:0"`
 }
+
+type ReaderCheckpoint struct {
+	Path      string    `gorm:"primaryKey;column:path"`
+	Device    uint64    `gorm:"column:device"`
+	Inode     uint64    `gorm:"column:inode"`
+	Offset    int64     `gorm:"column:offset"`
+	HeadLen   int64     `gorm:"column:head_len"`
+	HeadHash  string    `gorm:"column:head_hash"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+type Fingerprint struct {
+	Hash      string    `gorm:"primaryKey;column:hash"`
+	Source    string    `gorm:"column:source"`
+	Level     string    `gorm:"column:level"`
+	Template  string    `gorm:"column:template"`
+	Count     int64     `gorm:"column:count"`
+	FirstSeen time.Time `gorm:"column:first_seen"`
+	LastSeen  time.Time `gorm:"column:last_seen"`
+}
+
+// RateBaseline is the usual number of matches of a rule per bucket at an
+// hour of the day.
+type RateBaseline struct {
+	Rule    string  `gorm:"primaryKey;column:rule"`
+	Hour    int     `gorm:"primaryKey;autoIncrement:false;column:hour"`
+	Mean    float64 `gorm:"column:mean"`
+	Samples int     `gorm:"column:samples"`
+}
+
+// Incident is an alert sent to a chat that people can acknowledge, resolve
+// or mute.
+type Incident struct {
+	ID        uint  `gorm:"primaryKey"`
+	ChatID    int64 `gorm:"index;column:chat_id"`
+	MessageID int   `gorm:"column:message_id"`
+	// Key groups the alerts of one incident, such as an entry fingerprint.
+	Key string `gorm:"index;column:key"`
+	// Text is the alert as sent, in Telegram HTML.
+	Text     string    `gorm:"column:text"`
+	State    string    `gorm:"index;column:state"`
+	Count    int       `gorm:"column:count"`
+	OpenedAt time.Time `gorm:"column:opened_at"`
+	// LastSeenAt is when the incident last repeated.
+	LastSeenAt time.Time `gorm:"column:last_seen_at"`
+
+	AckedBy    string    `gorm:"column:acked_by"`
+	AckedAt    time.Time `gorm:"column:acked_at"`
+	ResolvedBy string    `gorm:"column:resolved_by"`
+	ResolvedAt time.Time `gorm:"column:resolved_at"`
+	MutedBy    string    `gorm:"column:muted_by"`
+	MutedUntil time.Time `gorm:"column:muted_until"`
+	// Escalations is how many tiers of the chat's escalation policy were
+	// notified.
+	Escalations int `gorm:"column:escalations;default:0"`
+}
+
+// Incident states.
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+)
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (db *DB) SaveReaderCheckpoint(cp ReaderCheckpoint) error {
+	result := db.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"device",
+			"inode",
+			"offset",
+			"head_len",
+			"head_hash",
+			"updated_at",
+		}),
+	}).Create(&cp)
+	if result.Error != nil {
+		return fmt.Errorf("save reader checkpoint (path=%q): %w", cp.Path, result.Error)
+	}
+	return nil
+}
+
+func (db *DB) GetReaderCheckpoint(path string) (ReaderCheckpoint, bool, error) {
+	var cp ReaderCheckpoint
+	result := db.db.First(&cp, "path = ?", path)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ReaderCheckpoint{}, false, nil
+		}
+		return ReaderCheckpoint{}, false, fmt.Errorf(
+			"get reader checkpoint (path=%q): %w",
+			path,
+			result.Error,
+		)
+	}
+	return cp, true, nil
+}
@@ -0,0 +1,47 @@
+package database
+
+import "testing"
+
+func TestReaderCheckpoint_SaveAndGet(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() { _ = db.Close() }()
+
+	_, found, err := db.GetReaderCheckpoint("/var/log/app.log")
+	if err != nil {
+		t.Fatalf("GetReaderCheckpoint failed: %v", err)
+	}
+	if found {
+		t.Fatal("expected no checkpoint before the first save")
+	}
+
+	cp := ReaderCheckpoint{
+		Path:     "/var/log/app.log",
+		Device:   2049,
+		Inode:    131,
+		Offset:   512,
+		HeadLen:  512,
+		HeadHash: "cbf29ce484222325",
+	}
+	if err := db.SaveReaderCheckpoint(cp); err != nil {
+		t.Fatalf("SaveReaderCheckpoint failed: %v", err)
+	}
+
+	cp.Offset = 1024
+	cp.HeadLen = 1024
+	cp.HeadHash = "af63bd4c8601b7df"
+	if err := db.SaveReaderCheckpoint(cp); err != nil {
+		t.Fatalf("SaveReaderCheckpoint overwrite failed: %v", err)
+	}
+
+	got, found, err := db.GetReaderCheckpoint("/var/log/app.log")
+	if err != nil {
+		t.Fatalf("GetReaderCheckpoint failed: %v", err)
+	}
+	if !found {
+		t.Fatal("expected checkpoint to be found")
+	}
+	if got.Device != cp.Device || got.Inode != cp.Inode || got.Offset != cp.Offset ||
+		got.HeadLen != cp.HeadLen || got.HeadHash != cp.HeadHash {
+		t.Fatalf("unexpected checkpoint: %+v", got)
+	}
+}
@@ -15,7 +15,15 @@ func setupTestDB(t *testing.T) *DB {
 		t.Fatalf("failed to open test database: %v", err)
 	}
 
-	if err := db.AutoMigrate(&Subscription{}, &Chat{}, &ChatRegexRule{}); err != nil {
+	if err := db.AutoMigrate(
+		&Subscription{},
+		&Chat{},
+		&ChatRegexRule{},
+		&ReaderCheckpoint{},
+		&Fingerprint{},
+		&RateBaseline{},
+		&Incident{},
+	); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
@@ -0,0 +1,76 @@
+// Package fingerprint groups log entries that differ only in variable parts,
+// such as IDs, addresses and numbers, and tracks how often each group occurs.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"unicode"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+var (
+	uuidPattern = regexp.MustCompile(
+		`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	ipPattern = regexp.MustCompile(
+		`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b|\b(?:[0-9a-fA-F]{1,4}:){5,7}[0-9a-fA-F]{1,4}\b`)
+	hexPattern = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`)
+	// wordPattern matches words with digits: numbers, request IDs, hashes.
+	wordPattern = regexp.MustCompile(`[\w-]*\d[\w-]*`)
+	digitsRun   = regexp.MustCompile(`\d+`)
+)
+
+// minIDLen is the shortest word with letters and digits taken for an ID
+// rather than a word with a number in it, such as "user42" or "30s".
+const minIDLen = 8
+
+// Template masks the variable parts of a message: UUIDs, IP addresses, hex
+// values, IDs and numbers.
+func Template(msg []byte) string {
+	msg = uuidPattern.ReplaceAll(msg, []byte("<uuid>"))
+	msg = ipPattern.ReplaceAll(msg, []byte("<ip>"))
+	msg = hexPattern.ReplaceAll(msg, []byte("<hex>"))
+	msg = wordPattern.ReplaceAllFunc(msg, func(word []byte) []byte {
+		if len(word) < minIDLen || !hasLetter(word) {
+			return digitsRun.ReplaceAll(word, []byte("<num>"))
+		}
+		if isHex(word) {
+			return []byte("<hex>")
+		}
+		return []byte("<id>")
+	})
+	return string(msg)
+}
+
+// Of returns the entry's fingerprint and template. Entries from different
+// sources or levels get different fingerprints.
+func Of(entry parser.LogEntry) (hash, template string) {
+	msg := entry.Message
+	if len(msg) == 0 {
+		msg = entry.Raw
+	}
+	template = Template(msg)
+
+	sum := sha256.Sum256([]byte(entry.Source + "\x00" + string(entry.Level) + "\x00" + template))
+	return hex.EncodeToString(sum[:8]), template
+}
+
+func hasLetter(b []byte) bool {
+	for _, c := range b {
+		if unicode.IsLetter(rune(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHex(b []byte) bool {
+	for _, c := range b {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
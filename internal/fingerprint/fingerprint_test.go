@@ -0,0 +1,63 @@
+package fingerprint
+
+import (
+	"testing"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{
+			"request 7c9e6679-7425-40de-944b-e07fc1f90ae7 failed",
+			"request <uuid> failed",
+		},
+		{
+			"dial tcp 10.0.3.17:5432: connection refused",
+			"dial tcp <ip>: connection refused",
+		},
+		{
+			"upstream 2001:db8:85a3:0:0:8a2e:370:7334 unreachable",
+			"upstream <ip> unreachable",
+		},
+		{
+			"req=req-8f3a9c2d1b user42 took 350ms, retry 3 of 5",
+			"req=<id> user<num> took <num>ms, retry <num> of <num>",
+		},
+		{
+			"commit deadbeef42 at 0x7ffd5e8c",
+			"commit <hex> at <hex>",
+		},
+		{
+			"nothing variable here",
+			"nothing variable here",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Template([]byte(tt.msg)); got != tt.want {
+			t.Errorf("Template(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestOf(t *testing.T) {
+	entry := func(source string, level parser.LogLevel, msg string) parser.LogEntry {
+		return parser.LogEntry{Source: source, Level: level, Message: []byte(msg)}
+	}
+
+	a, _ := Of(entry("api", parser.LevelError, "timeout for user 17"))
+	b, _ := Of(entry("api", parser.LevelError, "timeout for user 42"))
+	if a != b {
+		t.Errorf("expected messages differing in numbers to share a fingerprint")
+	}
+	if c, _ := Of(entry("worker", parser.LevelError, "timeout for user 17")); c == a {
+		t.Errorf("expected different sources to get different fingerprints")
+	}
+	if d, _ := Of(entry("api", parser.LevelWarn, "timeout for user 17")); d == a {
+		t.Errorf("expected different levels to get different fingerprints")
+	}
+}
@@ -0,0 +1,219 @@
+package fingerprint
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+// Record is what is known about a fingerprint.
+type Record struct {
+	Hash      string
+	Source    string
+	Level     string
+	Template  string
+	Count     int64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Store persists records between restarts.
+type Store interface {
+	LoadFingerprints() ([]Record, error)
+	SaveFingerprints(records []Record) error
+	DeleteFingerprints(hashes []string) error
+}
+
+// defaultMaxRecords bounds the fingerprints a tracker keeps.
+const defaultMaxRecords = 10000
+
+// MinUnroutedLevel is the lowest level of entries that no chat receives and
+// that are still worth tracking, so that chatty debug output isn't.
+const MinUnroutedLevel = parser.LevelWarn
+
+type Option func(*Tracker)
+
+// WithMaxRecords bounds how many fingerprints are kept. Past it, the least
+// recently seen one is forgotten, and is new again if it comes back.
+func WithMaxRecords(n int) Option {
+	return func(t *Tracker) {
+		if n > 0 {
+			t.maxRecords = n
+		}
+	}
+}
+
+// Tracker counts entries by fingerprint. Counts are kept in memory and
+// written to the store by Flush. It is safe for concurrent use.
+type Tracker struct {
+	store      Store
+	maxRecords int
+
+	mu sync.Mutex
+	// records maps hashes to their element in recent, which holds *Record
+	// from the most to the least recently seen.
+	records map[string]*list.Element
+	recent  *list.List
+	dirty   map[string]struct{}
+	evicted map[string]struct{}
+	now     func() time.Time
+}
+
+// NewTracker loads the known fingerprints from store, which may be nil to
+// track them in memory only.
+func NewTracker(store Store, opts ...Option) (*Tracker, error) {
+	t := &Tracker{
+		store:      store,
+		maxRecords: defaultMaxRecords,
+		records:    make(map[string]*list.Element),
+		recent:     list.New(),
+		dirty:      make(map[string]struct{}),
+		evicted:    make(map[string]struct{}),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if store == nil {
+		return t, nil
+	}
+
+	records, err := store.LoadFingerprints()
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(records, func(a, b Record) int {
+		return a.LastSeen.Compare(b.LastSeen)
+	})
+	for _, r := range records {
+		t.records[r.Hash] = t.recent.PushFront(&r)
+	}
+	t.evictLocked()
+	return t, nil
+}
+
+// Observe counts the entry and reports its fingerprint and whether it was
+// seen for the first time.
+func (t *Tracker) Observe(entry parser.LogEntry) (string, bool) {
+	hash, template := Of(entry)
+	seen := entry.Timestamp
+	if seen.IsZero() {
+		seen = t.now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dirty[hash] = struct{}{}
+	if e, ok := t.records[hash]; ok {
+		t.recent.MoveToFront(e)
+		r := e.Value.(*Record)
+		r.Count++
+		if seen.After(r.LastSeen) {
+			r.LastSeen = seen
+		}
+		return hash, false
+	}
+
+	t.records[hash] = t.recent.PushFront(&Record{
+		Hash:      hash,
+		Source:    entry.Source,
+		Level:     string(entry.Level),
+		Template:  template,
+		Count:     1,
+		FirstSeen: seen,
+		LastSeen:  seen,
+	})
+	delete(t.evicted, hash)
+	t.evictLocked()
+	return hash, true
+}
+
+// evictLocked forgets the least recently seen records past the limit.
+func (t *Tracker) evictLocked() {
+	for t.recent.Len() > t.maxRecords {
+		r := t.recent.Remove(t.recent.Back()).(*Record)
+		delete(t.records, r.Hash)
+		delete(t.dirty, r.Hash)
+		t.evicted[r.Hash] = struct{}{}
+	}
+}
+
+// Get returns the record of a fingerprint.
+func (t *Tracker) Get(hash string) (Record, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.records[hash]
+	if !ok {
+		return Record{}, false
+	}
+	return *e.Value.(*Record), true
+}
+
+// Flush writes the records changed since the last flush to the store and
+// deletes the evicted ones from it.
+func (t *Tracker) Flush() error {
+	if t.store == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	changed := make([]Record, 0, len(t.dirty))
+	for hash := range t.dirty {
+		changed = append(changed, *t.records[hash].Value.(*Record))
+	}
+	clear(t.dirty)
+	evicted := slices.Collect(maps.Keys(t.evicted))
+	clear(t.evicted)
+	t.mu.Unlock()
+
+	if len(evicted) > 0 {
+		if err := t.store.DeleteFingerprints(evicted); err != nil {
+			t.mu.Lock()
+			for _, hash := range evicted {
+				if _, ok := t.records[hash]; !ok {
+					t.evicted[hash] = struct{}{}
+				}
+			}
+			t.mu.Unlock()
+			return err
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := t.store.SaveFingerprints(changed); err != nil {
+		// Keep them for the next flush; newer counts win.
+		t.mu.Lock()
+		for _, r := range changed {
+			if _, ok := t.records[r.Hash]; ok {
+				t.dirty[r.Hash] = struct{}{}
+			}
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is done.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				log.Printf("save fingerprints: %v", err)
+			}
+		}
+	}
+}
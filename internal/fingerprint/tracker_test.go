@@ -0,0 +1,153 @@
+package fingerprint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+type memoryStore struct {
+	records map[string]Record
+	saves   int
+}
+
+func (s *memoryStore) LoadFingerprints() ([]Record, error) {
+	records := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+func (s *memoryStore) SaveFingerprints(records []Record) error {
+	s.saves++
+	for _, r := range records {
+		s.records[r.Hash] = r
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteFingerprints(hashes []string) error {
+	for _, hash := range hashes {
+		delete(s.records, hash)
+	}
+	return nil
+}
+
+func TestTracker_ObserveAndFlush(t *testing.T) {
+	store := &memoryStore{records: make(map[string]Record)}
+	tr, err := NewTracker(store)
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := func(msg string, at time.Time) parser.LogEntry {
+		return parser.LogEntry{
+			Timestamp: at,
+			Level:     parser.LevelError,
+			Message:   []byte(msg),
+			Source:    "api",
+		}
+	}
+
+	hash, isNew := tr.Observe(entry("timeout for order 17", first))
+	if !isNew {
+		t.Fatal("expected the first entry to be new")
+	}
+	if _, isNew := tr.Observe(entry("timeout for order 42", first.Add(time.Minute))); isNew {
+		t.Fatal("expected a repeat with another number not to be new")
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	r := store.records[hash]
+	if r.Count != 2 || !r.FirstSeen.Equal(first) || !r.LastSeen.Equal(first.Add(time.Minute)) {
+		t.Errorf("unexpected stored record: %+v", r)
+	}
+	if r.Template != "timeout for order <num>" {
+		t.Errorf("unexpected template: %q", r.Template)
+	}
+
+	// Nothing changed, so there is nothing to write
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if store.saves != 1 {
+		t.Errorf("expected 1 save, got %d", store.saves)
+	}
+
+	// A restarted tracker remembers the fingerprint
+	restarted, err := NewTracker(store)
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	if _, isNew := restarted.Observe(entry("timeout for order 99", first.Add(time.Hour))); isNew {
+		t.Error("expected a known fingerprint not to be new after a restart")
+	}
+	if got, _ := restarted.Get(hash); got.Count != 3 {
+		t.Errorf("expected count 3, got %d", got.Count)
+	}
+}
+
+func TestTracker_EvictsLeastRecentlySeen(t *testing.T) {
+	store := &memoryStore{records: make(map[string]Record)}
+	tr, err := NewTracker(store, WithMaxRecords(2))
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	observe := func(msg string) string {
+		at = at.Add(time.Minute)
+		hash, _ := tr.Observe(parser.LogEntry{
+			Timestamp: at,
+			Level:     parser.LevelError,
+			Message:   []byte(msg),
+			Source:    "api",
+		})
+		return hash
+	}
+
+	timeout := observe("timeout")
+	refused := observe("connection refused")
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	// Seeing the timeout again leaves the refusal least recently seen
+	observe("timeout")
+	full := observe("disk full")
+
+	if _, ok := tr.Get(refused); ok {
+		t.Error("expected the least recently seen fingerprint to be evicted")
+	}
+	for _, hash := range []string{timeout, full} {
+		if _, ok := tr.Get(hash); !ok {
+			t.Errorf("expected fingerprint %s to be kept", hash)
+		}
+	}
+
+	if err := tr.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if _, ok := store.records[refused]; ok {
+		t.Error("expected the evicted fingerprint to be deleted from the store")
+	}
+	if len(store.records) != 2 {
+		t.Errorf("expected 2 stored fingerprints, got %d", len(store.records))
+	}
+
+	// A restart with a smaller limit keeps the most recently seen
+	restarted, err := NewTracker(store, WithMaxRecords(1))
+	if err != nil {
+		t.Fatalf("NewTracker failed: %v", err)
+	}
+	if _, ok := restarted.Get(full); !ok {
+		t.Error("expected the most recently seen fingerprint to be loaded")
+	}
+	if _, ok := restarted.Get(timeout); ok {
+		t.Error("expected the older fingerprint to be evicted on load")
+	}
+}
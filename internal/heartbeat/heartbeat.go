@@ -0,0 +1,241 @@
+// Package heartbeat raises an alert when a source or rule that is expected to
+// log regularly goes silent, and again when it resumes.
+package heartbeat
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+// Kind is what a target watches.
+type Kind string
+
+const (
+	KindSource Kind = "source"
+	KindRule   Kind = "rule"
+)
+
+// Event reports a target going silent or, with Silent unset, resuming.
+type Event struct {
+	Kind  Kind
+	Name  string
+	Every time.Duration
+	// LastSeen is the time of the last entry before the silence.
+	LastSeen time.Time
+	Silent   bool
+	// Silence is how long it lasted, when resuming.
+	Silence time.Duration
+}
+
+// Status is the current state of a target.
+type Status struct {
+	Kind     Kind
+	Name     string
+	Every    time.Duration
+	LastSeen time.Time
+	Silent   bool
+}
+
+// Monitor expects each target to have an entry at least every so often. It
+// is safe for concurrent use.
+type Monitor struct {
+	mu      sync.Mutex
+	targets map[targetKey]*target
+	rules   *parser.RuleMatcher
+	now     func() time.Time
+
+	notify func(Event)
+}
+
+type targetKey struct {
+	kind Kind
+	name string
+}
+
+type target struct {
+	every    time.Duration
+	lastSeen time.Time
+	silent   bool
+}
+
+// NewMonitor returns a monitor without targets; notify is called for every
+// event, outside the monitor's lock.
+func NewMonitor(notify func(Event)) *Monitor {
+	return &Monitor{
+		targets: make(map[targetKey]*target),
+		rules:   parser.NewRuleMatcher(nil),
+		now:     time.Now,
+		notify:  notify,
+	}
+}
+
+// SetSources sets the sources to watch and how often each must log. Targets
+// kept from before keep their state; new ones start their wait now.
+func (m *Monitor) SetSources(every map[string]time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setTargetsLocked(KindSource, every)
+}
+
+// SetRules sets the rules to watch: those with Expect set. A rule matches an
+// entry like a chat rule does, by pattern and minimum level.
+func (m *Monitor) SetRules(configs []parser.RuleConfig) error {
+	var watched []parser.RuleConfig
+	for _, r := range configs {
+		if r.Expect > 0 {
+			watched = append(watched, r)
+		}
+	}
+	rules, err := parser.CompileRules(watched)
+	if err != nil {
+		return err
+	}
+	every := make(map[string]time.Duration, len(watched))
+	for _, r := range watched {
+		every[r.Name] = r.Expect
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = parser.NewRuleMatcher(rules)
+	m.setTargetsLocked(KindRule, every)
+	return nil
+}
+
+func (m *Monitor) setTargetsLocked(kind Kind, every map[string]time.Duration) {
+	now := m.now()
+	for k := range m.targets {
+		if _, ok := every[k.name]; k.kind == kind && !ok {
+			delete(m.targets, k)
+		}
+	}
+	for name, d := range every {
+		k := targetKey{kind: kind, name: name}
+		if t, ok := m.targets[k]; ok {
+			t.every = d
+			continue
+		}
+		m.targets[k] = &target{every: d, lastSeen: now}
+	}
+}
+
+// SeenSource counts a line from the source. It is fed raw lines rather than
+// entries, so that a source whose lines the parser rejects is still alive.
+func (m *Monitor) SeenSource(name string) {
+	now := m.now()
+
+	m.mu.Lock()
+	e, ok := m.seenLocked(targetKey{kind: KindSource, name: name}, now)
+	m.mu.Unlock()
+
+	if ok {
+		m.notify(e)
+	}
+}
+
+// Observe counts the entry for the rules it matches.
+func (m *Monitor) Observe(entry parser.LogEntry) {
+	now := m.now()
+	var events []Event
+
+	m.mu.Lock()
+	for r := range m.rules.Match(entry) {
+		if e, ok := m.seenLocked(targetKey{kind: KindRule, name: r.Name}, now); ok {
+			events = append(events, e)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, e := range events {
+		m.notify(e)
+	}
+}
+
+// seenLocked marks the target seen and returns its resume event, if it was
+// silent.
+func (m *Monitor) seenLocked(k targetKey, now time.Time) (Event, bool) {
+	t, ok := m.targets[k]
+	if !ok {
+		return Event{}, false
+	}
+	last := t.lastSeen
+	t.lastSeen = now
+	if !t.silent {
+		return Event{}, false
+	}
+	t.silent = false
+	return Event{
+		Kind:     k.kind,
+		Name:     k.name,
+		Every:    t.every,
+		LastSeen: last,
+		Silence:  now.Sub(last),
+	}, true
+}
+
+// Check alerts about the targets that have had no entry for longer than
+// they should.
+func (m *Monitor) Check() {
+	now := m.now()
+	var events []Event
+
+	m.mu.Lock()
+	for k, t := range m.targets {
+		if t.silent || now.Sub(t.lastSeen) <= t.every {
+			continue
+		}
+		t.silent = true
+		events = append(events, Event{
+			Kind:     k.kind,
+			Name:     k.name,
+			Every:    t.every,
+			LastSeen: t.lastSeen,
+			Silent:   true,
+		})
+	}
+	m.mu.Unlock()
+
+	for _, e := range events {
+		m.notify(e)
+	}
+}
+
+// Run checks the targets every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check()
+		}
+	}
+}
+
+// Status returns the targets sorted by kind and name.
+func (m *Monitor) Status() []Status {
+	m.mu.Lock()
+	statuses := make([]Status, 0, len(m.targets))
+	for k, t := range m.targets {
+		statuses = append(statuses, Status{
+			Kind:     k.kind,
+			Name:     k.name,
+			Every:    t.every,
+			LastSeen: t.lastSeen,
+			Silent:   t.silent,
+		})
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Name, b.Name))
+	})
+	return statuses
+}
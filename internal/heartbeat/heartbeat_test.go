@@ -0,0 +1,116 @@
+package heartbeat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+func newTestMonitor(t *testing.T) (*Monitor, *[]Event, func(time.Duration)) {
+	t.Helper()
+	var events []Event
+	m := NewMonitor(func(e Event) { events = append(events, e) })
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	return m, &events, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMonitor_SourceSilenceAndRecovery(t *testing.T) {
+	m, events, advance := newTestMonitor(t)
+	m.SetSources(map[string]time.Duration{"api": time.Minute})
+
+	advance(50 * time.Second)
+	m.SeenSource("api")
+	advance(50 * time.Second)
+	m.Check()
+	if len(*events) != 0 {
+		t.Fatalf("expected no events while the source logs, got %v", *events)
+	}
+
+	advance(20 * time.Second)
+	m.Check()
+	m.Check()
+	if len(*events) != 1 || !(*events)[0].Silent || (*events)[0].Name != "api" {
+		t.Fatalf("expected one silence alert for api, got %v", *events)
+	}
+
+	advance(time.Minute)
+	m.SeenSource("worker")
+	if len(*events) != 1 {
+		t.Fatalf("other sources must not resume api, got %v", *events)
+	}
+	m.SeenSource("api")
+	if len(*events) != 2 {
+		t.Fatalf("expected a recovery event, got %v", *events)
+	}
+	if e := (*events)[1]; e.Silent || e.Silence != 2*time.Minute+10*time.Second {
+		t.Fatalf("unexpected recovery event: %+v", e)
+	}
+}
+
+func TestMonitor_EntriesDoNotMarkSources(t *testing.T) {
+	m, events, advance := newTestMonitor(t)
+	m.SetSources(map[string]time.Duration{"api": time.Minute})
+
+	advance(50 * time.Second)
+	m.Observe(parser.LogEntry{Source: "api", Level: parser.LevelInfo, Raw: []byte("ok")})
+	advance(20 * time.Second)
+	m.Check()
+	if len(*events) != 1 || !(*events)[0].Silent {
+		t.Fatalf("sources must be marked by their lines only, got %v", *events)
+	}
+}
+
+func TestMonitor_Rules(t *testing.T) {
+	m, events, advance := newTestMonitor(t)
+	err := m.SetRules([]parser.RuleConfig{
+		{Name: "backups", Pattern: "backup done", Expect: time.Hour},
+		{Name: "errors", Pattern: "ERROR"},
+	})
+	if err != nil {
+		t.Fatalf("SetRules failed: %v", err)
+	}
+
+	advance(30 * time.Minute)
+	m.Observe(parser.LogEntry{Raw: []byte("2026-01-02 backup done in 3s")})
+	advance(45 * time.Minute)
+	m.Check()
+	if len(*events) != 0 {
+		t.Fatalf("expected no events, got %v", *events)
+	}
+
+	advance(20 * time.Minute)
+	m.Check()
+	if len(*events) != 1 || (*events)[0].Kind != KindRule || (*events)[0].Name != "backups" {
+		t.Fatalf("expected a silence alert for backups, got %v", *events)
+	}
+
+	statuses := m.Status()
+	if len(statuses) != 1 || !statuses[0].Silent {
+		t.Fatalf("rules without expect must not be watched, got %+v", statuses)
+	}
+
+	if err := m.SetRules([]parser.RuleConfig{{Name: "x", Pattern: "(", Expect: 1}}); err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+}
+
+func TestMonitor_SetSourcesKeepsState(t *testing.T) {
+	m, _, advance := newTestMonitor(t)
+	m.SetSources(map[string]time.Duration{"api": time.Minute, "db": time.Minute})
+	advance(2 * time.Minute)
+	m.Check()
+
+	m.SetSources(map[string]time.Duration{"api": 5 * time.Minute, "cron": time.Hour})
+	statuses := m.Status()
+	if len(statuses) != 2 {
+		t.Fatalf("expected api and cron, got %+v", statuses)
+	}
+	if s := statuses[0]; s.Name != "api" || !s.Silent || s.Every != 5*time.Minute {
+		t.Fatalf("api should stay silent with the new window, got %+v", s)
+	}
+	if s := statuses[1]; s.Name != "cron" || s.Silent {
+		t.Fatalf("cron should start its wait now, got %+v", s)
+	}
+}
@@ -0,0 +1,269 @@
+// Package ingest receives log events over HTTP.
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kxrxh/logram/internal/input"
+)
+
+const (
+	defaultSourceName = "http"
+	defaultLevel      = "INFO"
+
+	maxBodySize  = 10 << 20
+	maxLineSize  = 1 << 20
+	readTimeout  = 30 * time.Second
+	shutdownWait = 5 * time.Second
+)
+
+var ErrInvalidRecord = errors.New("invalid record")
+
+// Sink takes ingested lines. It reports false when it can't take more.
+type Sink interface {
+	TrySend(line input.Line) bool
+}
+
+type Config struct {
+	Addr string
+	// Token, when set, is required as "Authorization: Bearer <token>".
+	Token string
+	// Source is the default source name; a request can override it with
+	// the "source" query parameter.
+	Source string
+}
+
+// Server accepts POST /ingest with an NDJSON body (application/x-ndjson or
+// application/json) or a plain-text body with one event per line.
+type Server struct {
+	cfg  Config
+	sink Sink
+}
+
+func New(cfg Config, sink Sink) *Server {
+	if cfg.Source == "" {
+		cfg.Source = defaultSourceName
+	}
+	return &Server{cfg: cfg, sink: sink}
+}
+
+// Serve listens on the configured address until ctx is done.
+func (s *Server) Serve(ctx context.Context) error {
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("listen http ingest %s: %w", s.cfg.Addr, err)
+	}
+	return s.serve(ctx, ln)
+}
+
+func (s *Server) serve(ctx context.Context, ln net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/ingest", s)
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: readTimeout,
+		ReadTimeout:       readTimeout,
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownWait)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown http ingest: %v", err)
+		}
+	})
+	defer stop()
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve http ingest: %w", err)
+	}
+	return nil
+}
+
+type response struct {
+	Accepted int    `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeResponse(w, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeResponse(w, http.StatusUnauthorized, response{Error: "unauthorized"})
+		return
+	}
+
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = s.cfg.Source
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+	lines, err := readLines(body, source, isJSON(r.Header.Get("Content-Type")), time.Now())
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		status := http.StatusBadRequest
+		if errors.As(err, &maxErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeResponse(w, status, response{Error: err.Error()})
+		return
+	}
+
+	for i, line := range lines {
+		if !s.sink.TrySend(line) {
+			// Lines before i are already queued; the client retries the rest
+			w.Header().Set("Retry-After", "1")
+			writeResponse(w, http.StatusTooManyRequests, response{
+				Accepted: i,
+				Error:    "buffer is full",
+			})
+			return
+		}
+	}
+	writeResponse(w, http.StatusAccepted, response{Accepted: len(lines)})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.cfg.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) == 1
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		return true
+	default:
+		return false
+	}
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("write http ingest response: %v", err)
+	}
+}
+
+// readLines reads the whole body before anything is queued, so a malformed
+// body is rejected as a whole.
+func readLines(body io.Reader, source string, jsonLines bool, now time.Time) ([]input.Line, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var lines []input.Line
+	for n := 1; scanner.Scan(); n++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		data = bytes.Clone(data)
+
+		rec := &input.Record{Timestamp: now, Level: defaultLevel, Message: data}
+		if jsonLines {
+			var err error
+			if rec, err = decodeRecord(data, now); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		}
+		lines = append(lines, input.Line{Source: source, Data: data, Record: rec})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return lines, nil
+}
+
+var (
+	timeKeys    = []string{"time", "timestamp", "ts"}
+	levelKeys   = []string{"level", "severity"}
+	messageKeys = []string{"msg", "message"}
+)
+
+// decodeRecord decodes a JSON object. Keys other than the time, level and
+// message keys are kept as fields.
+func decodeRecord(data []byte, now time.Time) (*input.Record, error) {
+	var obj map[string]any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+
+	rec := &input.Record{Timestamp: now, Level: defaultLevel}
+	if v, ok := take(obj, timeKeys); ok {
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: time %q is not RFC 3339", ErrInvalidRecord, v)
+		}
+		rec.Timestamp = ts
+	}
+	if v, ok := take(obj, levelKeys); ok {
+		rec.Level = strings.ToUpper(v)
+	}
+	if v, ok := take(obj, messageKeys); ok {
+		rec.Message = []byte(v)
+	}
+
+	if len(obj) > 0 {
+		rec.Fields = make(map[string]string, len(obj))
+		for k, v := range obj {
+			rec.Fields[k] = fieldValue(v)
+		}
+	}
+	return rec, nil
+}
+
+// take removes the first of keys present in obj and returns its value.
+func take(obj map[string]any, keys []string) (string, bool) {
+	for _, k := range keys {
+		if v, ok := obj[k]; ok {
+			delete(obj, k)
+			return fieldValue(v), true
+		}
+	}
+	return "", false
+}
+
+func fieldValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
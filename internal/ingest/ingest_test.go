@@ -0,0 +1,193 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/buffer"
+	"github.com/kxrxh/logram/internal/input"
+)
+
+type memorySink struct {
+	capacity int
+	lines    []input.Line
+}
+
+func (s *memorySink) TrySend(line input.Line) bool {
+	if len(s.lines) >= s.capacity {
+		return false
+	}
+	s.lines = append(s.lines, line)
+	return true
+}
+
+func post(s *Server, target, contentType, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_NDJSON(t *testing.T) {
+	sink := &memorySink{capacity: 10}
+	s := New(Config{}, sink)
+
+	body := `{"time":"2024-01-15T10:30:00Z","level":"error","msg":"deploy failed","job":"build","attempt":2}
+{"message":"no time or level"}
+`
+	rec := post(s, "/ingest?source=ci", "application/x-ndjson", body, nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(sink.lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(sink.lines))
+	}
+
+	first := sink.lines[0]
+	if first.Source != "ci" || first.Record == nil {
+		t.Fatalf("unexpected line: %+v", first)
+	}
+	if want := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC); !first.Record.Timestamp.Equal(want) {
+		t.Errorf("expected timestamp %v, got %v", want, first.Record.Timestamp)
+	}
+	if first.Record.Level != "ERROR" || string(first.Record.Message) != "deploy failed" {
+		t.Errorf("unexpected record: %+v", first.Record)
+	}
+	if first.Record.Fields["job"] != "build" || first.Record.Fields["attempt"] != "2" {
+		t.Errorf("unexpected fields: %v", first.Record.Fields)
+	}
+
+	second := sink.lines[1].Record
+	if second.Level != defaultLevel || string(second.Message) != "no time or level" {
+		t.Errorf("unexpected defaults: %+v", second)
+	}
+}
+
+func TestServer_PlainText(t *testing.T) {
+	sink := &memorySink{capacity: 10}
+	s := New(Config{Source: "cron"}, sink)
+
+	rec := post(s, "/ingest", "text/plain", "backup started\n\nbackup done\n", nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	if len(sink.lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(sink.lines))
+	}
+	if sink.lines[1].Source != "cron" || string(sink.lines[1].Record.Message) != "backup done" {
+		t.Errorf("unexpected line: %+v", sink.lines[1])
+	}
+}
+
+func TestServer_InvalidJSONRejectsBody(t *testing.T) {
+	sink := &memorySink{capacity: 10}
+	s := New(Config{}, sink)
+
+	rec := post(s, "/ingest", "application/x-ndjson", "{\"msg\":\"ok\"}\nnot json\n", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if len(sink.lines) != 0 {
+		t.Errorf("expected nothing queued, got %d lines", len(sink.lines))
+	}
+}
+
+func TestServer_BearerToken(t *testing.T) {
+	sink := &memorySink{capacity: 10}
+	s := New(Config{Token: "secret"}, sink)
+
+	rec := post(s, "/ingest", "text/plain", "hello", nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", rec.Code)
+	}
+
+	rec = post(s, "/ingest", "text/plain", "hello", http.Header{
+		"Authorization": {"Bearer wrong"},
+	})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with wrong token, got %d", rec.Code)
+	}
+
+	rec = post(s, "/ingest", "text/plain", "hello", http.Header{
+		"Authorization": {"Bearer secret"},
+	})
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected 202 with token, got %d", rec.Code)
+	}
+}
+
+func TestServer_FullSink(t *testing.T) {
+	sink := &memorySink{capacity: 1}
+	s := New(Config{}, sink)
+
+	rec := post(s, "/ingest", "text/plain", "one\ntwo\n", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if !strings.Contains(rec.Body.String(), `"accepted":1`) {
+		t.Errorf("expected accepted count in body, got %s", rec.Body)
+	}
+}
+
+func TestServer_StalledConsumer(t *testing.T) {
+	buf := buffer.New(t.Context(), 4, time.Hour)
+	buf.Start()
+	s := New(Config{}, buf)
+
+	body := strings.Repeat("line\n", 100)
+	rec := post(s, "/ingest", "text/plain", body, nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 with nobody reading the buffer, got %d: %s", rec.Code, rec.Body)
+	}
+
+	go func() {
+		for range buf.Output() {
+		}
+	}()
+	buf.Stop()
+}
+
+func TestServer_MethodNotAllowed(t *testing.T) {
+	s := New(Config{}, &memorySink{})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ingest", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
+
+func TestServer_ServeStopsOnCancel(t *testing.T) {
+	var lc net.ListenConfig
+	ln, err := lc.Listen(t.Context(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- New(Config{}, &memorySink{}).serve(ctx, ln) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil && !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not stop after cancel")
+	}
+}
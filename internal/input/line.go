@@ -0,0 +1,25 @@
+package input
+
+import "time"
+
+// Line is a raw log line together with where it was read from.
+type Line struct {
+	// Source is the name of the configured source the line belongs to.
+	Source string
+	// File is the path of the file the line was read from, if any.
+	File   string
+	Labels map[string]string
+	Data   []byte
+
+	// Record is set by inputs that parse their own format, such as syslog.
+	// The parser then takes the entry from it instead of parsing Data.
+	Record *Record
+}
+
+// Record is a log record already parsed by its input.
+type Record struct {
+	Timestamp time.Time
+	Level     string
+	Message   []byte
+	Fields    map[string]string
+}
@@ -0,0 +1,200 @@
+package multiline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kxrxh/logram/internal/input"
+)
+
+const (
+	defaultTimeout  = time.Second
+	defaultMaxLines = 500
+	defaultMaxBytes = 1 << 20
+)
+
+// RuleConfig describes how events of one source span several lines: an
+// event starts with a line matching StartPattern, and every following line
+// that does not match it is a continuation.
+type RuleConfig struct {
+	StartPattern string
+	// Timeout flushes an event when no line arrives for it in this long.
+	Timeout time.Duration
+	// MaxLines flushes an event when it grows this long.
+	MaxLines int
+	// MaxBytes flushes an event before it grows larger than this; a line
+	// that doesn't fit starts the next event.
+	MaxBytes int
+}
+
+type rule struct {
+	start    *regexp.Regexp
+	timeout  time.Duration
+	maxLines int
+	maxBytes int
+}
+
+// Assembler joins continuation lines, such as stack traces, with the line
+// that started their event. Sources without a rule pass through unchanged.
+type Assembler struct {
+	rules map[string]rule
+}
+
+// pending is an event still collecting lines from one file.
+type pending struct {
+	line     input.Line
+	lines    int
+	deadline time.Time
+}
+
+type streamKey struct {
+	source string
+	file   string
+}
+
+// NewAssembler compiles rules keyed by source name.
+func NewAssembler(rules map[string]RuleConfig) (*Assembler, error) {
+	compiled := make(map[string]rule, len(rules))
+	for source, r := range rules {
+		if strings.TrimSpace(r.StartPattern) == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(r.StartPattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile multiline start pattern (source=%q): %w", source, err)
+		}
+
+		compiled[source] = rule{
+			start:    re,
+			timeout:  orDefault(r.Timeout, defaultTimeout),
+			maxLines: orDefault(r.MaxLines, defaultMaxLines),
+			maxBytes: orDefault(r.MaxBytes, defaultMaxBytes),
+		}
+	}
+	return &Assembler{rules: compiled}, nil
+}
+
+// Start assembles events from lines. When ctx is done the pending events are
+// flushed, and lines still arriving are assembled until lines is closed, as
+// their offsets are already checkpointed. The output is closed after lines.
+func (a *Assembler) Start(ctx context.Context, lines <-chan input.Line) <-chan input.Line {
+	output := make(chan input.Line, 100)
+
+	go func() {
+		defer close(output)
+		a.run(ctx, lines, output)
+	}()
+
+	return output
+}
+
+func (a *Assembler) run(ctx context.Context, lines <-chan input.Line, output chan<- input.Line) {
+	events := make(map[streamKey]*pending)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	emit := func(ready []input.Line) {
+		for _, line := range ready {
+			output <- line
+		}
+	}
+
+	done := ctx.Done()
+	for {
+		select {
+		case <-done:
+			done = nil
+			emit(flushAll(events))
+		case line, ok := <-lines:
+			if !ok {
+				emit(flushAll(events))
+				return
+			}
+			emit(a.add(events, line))
+		case <-timer.C:
+			emit(expired(events, time.Now()))
+		}
+		resetTimer(timer, events)
+	}
+}
+
+// add records a line and returns the lines that are complete events now.
+func (a *Assembler) add(events map[streamKey]*pending, line input.Line) []input.Line {
+	r, ok := a.rules[line.Source]
+	if !ok {
+		return []input.Line{line}
+	}
+
+	key := streamKey{source: line.Source, file: line.File}
+	ev := events[key]
+
+	var ready []input.Line
+	if ev != nil &&
+		(r.start.Match(line.Data) || len(ev.line.Data)+1+len(line.Data) > r.maxBytes) {
+		ready = append(ready, ev.line)
+		ev = nil
+	}
+
+	if ev == nil {
+		line.Data = append([]byte(nil), line.Data...)
+		ev = &pending{line: line}
+		events[key] = ev
+	} else {
+		ev.line.Data = append(append(ev.line.Data, '\n'), line.Data...)
+	}
+	ev.lines++
+	ev.deadline = time.Now().Add(r.timeout)
+
+	if ev.lines >= r.maxLines || len(ev.line.Data) >= r.maxBytes {
+		ready = append(ready, ev.line)
+		delete(events, key)
+	}
+	return ready
+}
+
+func flushAll(events map[streamKey]*pending) []input.Line {
+	ready := make([]input.Line, 0, len(events))
+	for key, ev := range events {
+		ready = append(ready, ev.line)
+		delete(events, key)
+	}
+	return ready
+}
+
+func expired(events map[streamKey]*pending, now time.Time) []input.Line {
+	var ready []input.Line
+	for key, ev := range events {
+		if !ev.deadline.After(now) {
+			ready = append(ready, ev.line)
+			delete(events, key)
+		}
+	}
+	return ready
+}
+
+// resetTimer arms the timer for the earliest pending deadline.
+func resetTimer(timer *time.Timer, events map[streamKey]*pending) {
+	timer.Stop()
+
+	var next time.Time
+	for _, ev := range events {
+		if next.IsZero() || ev.deadline.Before(next) {
+			next = ev.deadline
+		}
+	}
+	if !next.IsZero() {
+		timer.Reset(time.Until(next))
+	}
+}
+
+func orDefault[T time.Duration | int](value, fallback T) T {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,202 @@
+package multiline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/input"
+)
+
+const timestampStart = `^\d{4}-\d{2}-\d{2}`
+
+func collect(t *testing.T, ch <-chan input.Line, count int) []string {
+	t.Helper()
+
+	var got []string
+	timeout := time.After(time.Second)
+	for len(got) < count {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, string(line.Data))
+		case <-timeout:
+			return got
+		}
+	}
+	return got
+}
+
+func line(source, data string) input.Line {
+	return input.Line{Source: source, File: source + ".log", Data: []byte(data)}
+}
+
+func TestAssembler_JoinsStackTrace(t *testing.T) {
+	a, err := NewAssembler(map[string]RuleConfig{
+		"api": {StartPattern: timestampStart, Timeout: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("NewAssembler failed: %v", err)
+	}
+
+	in := make(chan input.Line, 10)
+	out := a.Start(t.Context(), in)
+
+	in <- line("api", "2024-01-15T10:30:00Z [ERROR] request failed")
+	in <- line("api", "java.lang.NullPointerException: boom")
+	in <- line("api", "\tat com.example.Handler.handle(Handler.java:42)")
+	in <- line("api", "2024-01-15T10:30:01Z [INFO] next request")
+	close(in)
+
+	got := collect(t, out, 2)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events, got %q", got)
+	}
+
+	wantTrace := strings.Join([]string{
+		"2024-01-15T10:30:00Z [ERROR] request failed",
+		"java.lang.NullPointerException: boom",
+		"\tat com.example.Handler.handle(Handler.java:42)",
+	}, "\n")
+	if got[0] != wantTrace {
+		t.Errorf("unexpected first event:\n%s", got[0])
+	}
+	if got[1] != "2024-01-15T10:30:01Z [INFO] next request" {
+		t.Errorf("unexpected second event: %q", got[1])
+	}
+}
+
+func TestAssembler_FlushesOnTimeout(t *testing.T) {
+	a, err := NewAssembler(map[string]RuleConfig{
+		"api": {StartPattern: timestampStart, Timeout: 50 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewAssembler failed: %v", err)
+	}
+
+	in := make(chan input.Line, 10)
+	out := a.Start(t.Context(), in)
+
+	in <- line("api", "2024-01-15T10:30:00Z [ERROR] panic")
+	in <- line("api", "goroutine 1 [running]:")
+
+	got := collect(t, out, 1)
+	if len(got) != 1 || got[0] != "2024-01-15T10:30:00Z [ERROR] panic\ngoroutine 1 [running]:" {
+		t.Fatalf("expected the event to be flushed by timeout, got %q", got)
+	}
+}
+
+func TestAssembler_MaxLines(t *testing.T) {
+	a, err := NewAssembler(map[string]RuleConfig{
+		"api": {StartPattern: timestampStart, Timeout: time.Hour, MaxLines: 2},
+	})
+	if err != nil {
+		t.Fatalf("NewAssembler failed: %v", err)
+	}
+
+	in := make(chan input.Line, 10)
+	out := a.Start(t.Context(), in)
+
+	in <- line("api", "2024-01-15T10:30:00Z [ERROR] start")
+	in <- line("api", "cont 1")
+	in <- line("api", "cont 2")
+
+	got := collect(t, out, 1)
+	if len(got) != 1 || got[0] != "2024-01-15T10:30:00Z [ERROR] start\ncont 1" {
+		t.Fatalf("expected the event to be cut at 2 lines, got %q", got)
+	}
+}
+
+func TestAssembler_MaxBytes(t *testing.T) {
+	a, err := NewAssembler(map[string]RuleConfig{
+		"api": {StartPattern: timestampStart, Timeout: time.Hour, MaxBytes: 40},
+	})
+	if err != nil {
+		t.Fatalf("NewAssembler failed: %v", err)
+	}
+
+	in := make(chan input.Line, 10)
+	out := a.Start(t.Context(), in)
+
+	in <- line("api", "2024-01-15T10:30:00Z [ERROR] start")
+	in <- line("api", "continuation that does not fit")
+	close(in)
+
+	got := collect(t, out, 2)
+	want := []string{"2024-01-15T10:30:00Z [ERROR] start", "continuation that does not fit"}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected the event to be cut at 40 bytes, got %q", got)
+	}
+}
+
+func TestAssembler_FlushesOnCancel(t *testing.T) {
+	a, err := NewAssembler(map[string]RuleConfig{
+		"api": {StartPattern: timestampStart, Timeout: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("NewAssembler failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	in := make(chan input.Line, 10)
+	out := a.Start(ctx, in)
+
+	in <- line("api", "2024-01-15T10:30:00Z [ERROR] request failed")
+	in <- line("api", "java.lang.NullPointerException: boom")
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	got := collect(t, out, 1)
+	if len(got) != 1 || got[0] != "2024-01-15T10:30:00Z [ERROR] request failed\n"+
+		"java.lang.NullPointerException: boom" {
+		t.Fatalf("expected the pending event on cancel, got %q", got)
+	}
+
+	// Lines read before the reader stopped still get through
+	in <- line("api", "2024-01-15T10:30:01Z [INFO] late")
+	close(in)
+	got = collect(t, out, 2)
+	if len(got) != 1 || got[0] != "2024-01-15T10:30:01Z [INFO] late" {
+		t.Fatalf("expected the late line after cancel, got %q", got)
+	}
+}
+
+func TestAssembler_SeparatesSourcesAndPassesThrough(t *testing.T) {
+	a, err := NewAssembler(map[string]RuleConfig{
+		"api": {StartPattern: timestampStart, Timeout: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("NewAssembler failed: %v", err)
+	}
+
+	in := make(chan input.Line, 10)
+	out := a.Start(t.Context(), in)
+
+	in <- line("api", "2024-01-15T10:30:00Z [ERROR] api event")
+	in <- line("nginx", "GET / 200")
+	in <- line("api", "api continuation")
+	in <- line("nginx", "GET /favicon.ico 404")
+
+	got := collect(t, out, 2)
+	if len(got) != 2 || got[0] != "GET / 200" || got[1] != "GET /favicon.ico 404" {
+		t.Fatalf("expected nginx lines to pass through, got %q", got)
+	}
+
+	close(in)
+	got = collect(t, out, 1)
+	if len(got) != 1 || got[0] != "2024-01-15T10:30:00Z [ERROR] api event\napi continuation" {
+		t.Fatalf("expected api event on close, got %q", got)
+	}
+}
+
+func TestNewAssembler_InvalidPattern(t *testing.T) {
+	_, err := NewAssembler(map[string]RuleConfig{
+		"api": {StartPattern: `(?P<bad`},
+	})
+	if err == nil {
+		t.Fatal("expected error for invalid start pattern")
+	}
+}
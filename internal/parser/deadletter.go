@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Reasons rejected lines are grouped by.
+const (
+	ReasonInvalidFormat    = "invalid_format"
+	ReasonInvalidTimestamp = "invalid_timestamp"
+	ReasonEmptyLine        = "empty_line"
+	ReasonOther            = "other"
+)
+
+const (
+	defaultDeadLetterSamples = 10
+	// maxDeadLetterLine caps the bytes kept of a sampled line.
+	maxDeadLetterLine = 300
+)
+
+// DeadLetter is a line the parser rejected.
+type DeadLetter struct {
+	Time   time.Time
+	Source string
+	Reason string
+	Line   string
+}
+
+// DeadLetterStats is a snapshot of DeadLetters.
+type DeadLetterStats struct {
+	Accepted uint64
+	Rejected uint64
+	ByReason map[string]uint64
+	// Samples are the most recent rejected lines, oldest first.
+	Samples []DeadLetter
+}
+
+// RejectedRatio is the share of rejected lines among all parsed ones.
+func (s DeadLetterStats) RejectedRatio() float64 {
+	total := s.Accepted + s.Rejected
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Rejected) / float64(total)
+}
+
+// DeadLetters counts lines the parser accepted and rejected, and keeps a
+// sample of the latest rejected ones.
+type DeadLetters struct {
+	mu       sync.Mutex
+	accepted uint64
+	rejected uint64
+	byReason map[string]uint64
+
+	samples []DeadLetter
+	next    int
+	now     func() time.Time
+	redact  func(source string, line []byte) []byte
+}
+
+// NewDeadLetters keeps up to samples recent rejected lines.
+func NewDeadLetters(samples int) *DeadLetters {
+	if samples <= 0 {
+		samples = defaultDeadLetterSamples
+	}
+	return &DeadLetters{
+		byReason: make(map[string]uint64),
+		samples:  make([]DeadLetter, 0, samples),
+		now:      time.Now,
+	}
+}
+
+// SetRedact sets how sampled lines are masked. It runs on the whole line,
+// before it is cut to size, so that a secret across the cut is still found.
+func (d *DeadLetters) SetRedact(redact func(source string, line []byte) []byte) {
+	d.mu.Lock()
+	d.redact = redact
+	d.mu.Unlock()
+}
+
+// Accept counts a parsed line.
+func (d *DeadLetters) Accept() {
+	d.mu.Lock()
+	d.accepted++
+	d.mu.Unlock()
+}
+
+// Reject counts a line from source that failed to parse and samples it.
+func (d *DeadLetters) Reject(source string, err *ParseError) {
+	d.mu.Lock()
+	redact := d.redact
+	d.mu.Unlock()
+
+	line := err.Line
+	if redact != nil {
+		line = string(redact(source, []byte(line)))
+	}
+	letter := DeadLetter{
+		Source: source,
+		Reason: DeadLetterReason(err),
+		Line:   truncateLine(line, maxDeadLetterLine),
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	letter.Time = d.now()
+	d.rejected++
+	d.byReason[letter.Reason]++
+	if len(d.samples) < cap(d.samples) {
+		d.samples = append(d.samples, letter)
+		return
+	}
+	d.samples[d.next] = letter
+	d.next = (d.next + 1) % len(d.samples)
+}
+
+func (d *DeadLetters) Stats() DeadLetterStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := DeadLetterStats{
+		Accepted: d.accepted,
+		Rejected: d.rejected,
+		ByReason: make(map[string]uint64, len(d.byReason)),
+		Samples:  make([]DeadLetter, 0, len(d.samples)),
+	}
+	for reason, n := range d.byReason {
+		stats.ByReason[reason] = n
+	}
+	stats.Samples = append(stats.Samples, d.samples[d.next:]...)
+	stats.Samples = append(stats.Samples, d.samples[:d.next]...)
+	return stats
+}
+
+// DeadLetterReason groups a parse error into one of the Reason constants.
+func DeadLetterReason(err error) string {
+	switch {
+	case errors.Is(err, ErrEmptyLine):
+		return ReasonEmptyLine
+	case errors.Is(err, ErrInvalidTimestamp):
+		return ReasonInvalidTimestamp
+	case errors.Is(err, ErrInvalidFormat):
+		return ReasonInvalidFormat
+	default:
+		return ReasonOther
+	}
+}
+
+func truncateLine(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/kxrxh/logram/internal/input"
+)
+
+func TestDeadLetterReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&ParseError{Reason: ErrEmptyLine}, ReasonEmptyLine},
+		{&ParseError{Reason: ErrInvalidFormat}, ReasonInvalidFormat},
+		{&ParseError{Reason: ErrInvalidTimestamp}, ReasonInvalidTimestamp},
+		{&ParseError{Reason: ErrUnknownFormat}, ReasonOther},
+	}
+
+	for _, tt := range tests {
+		if got := DeadLetterReason(tt.err); got != tt.want {
+			t.Errorf("DeadLetterReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDeadLetters_KeepsLatestSamples(t *testing.T) {
+	d := NewDeadLetters(3)
+	for i := range 5 {
+		d.Reject("app", &ParseError{Line: fmt.Sprintf("line %d", i), Reason: ErrInvalidFormat})
+	}
+	d.Accept()
+
+	stats := d.Stats()
+	if stats.Rejected != 5 || stats.Accepted != 1 || stats.ByReason[ReasonInvalidFormat] != 5 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if len(stats.Samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(stats.Samples))
+	}
+	for i, want := range []string{"line 2", "line 3", "line 4"} {
+		if stats.Samples[i].Line != want || stats.Samples[i].Source != "app" {
+			t.Errorf("sample %d: expected %q from app, got %+v", i, want, stats.Samples[i])
+		}
+	}
+	if ratio := stats.RejectedRatio(); ratio != 5.0/6 {
+		t.Errorf("expected ratio 5/6, got %v", ratio)
+	}
+}
+
+func TestDeadLetters_TruncatesLongLines(t *testing.T) {
+	d := NewDeadLetters(1)
+	d.Reject("app", &ParseError{Line: strings.Repeat("я", maxDeadLetterLine), Reason: ErrInvalidFormat})
+
+	line := d.Stats().Samples[0].Line
+	if len(line) > maxDeadLetterLine+len("…") || !strings.HasSuffix(line, "…") {
+		t.Errorf("expected a truncated line, got %d bytes", len(line))
+	}
+}
+
+func TestDeadLetters_RedactsBeforeTruncating(t *testing.T) {
+	d := NewDeadLetters(1)
+	secret := regexp.MustCompile(`password=\S+`)
+	d.SetRedact(func(source string, line []byte) []byte {
+		if source != "app" {
+			t.Errorf("expected source app, got %q", source)
+		}
+		return secret.ReplaceAll(line, []byte("password=***"))
+	})
+
+	// The cut falls inside the secret
+	line := strings.Repeat("x", maxDeadLetterLine-12) + " password=hunter2hunter2"
+	d.Reject("app", &ParseError{Line: line, Reason: ErrInvalidFormat})
+
+	if got := d.Stats().Samples[0].Line; strings.Contains(got, "hunt") {
+		t.Errorf("expected the secret masked, got %q", got)
+	}
+}
+
+func TestParser_Start_CountsRejectedLines(t *testing.T) {
+	p := NewParser(nil)
+	lines := make(chan input.Line, 4)
+	lines <- input.Line{Source: "app", Data: []byte("2024-01-15T10:30:00Z [INFO] ok")}
+	lines <- input.Line{Source: "app", Data: []byte("garbage")}
+	lines <- input.Line{Source: "app", Data: []byte("yesterday [INFO] late")}
+	lines <- input.Line{Source: "app", Data: []byte("")}
+	close(lines)
+
+	for range p.Start(context.Background(), lines) {
+	}
+
+	stats := p.DeadLetters().Stats()
+	if stats.Accepted != 1 || stats.Rejected != 3 {
+		t.Errorf("expected 1 accepted and 3 rejected lines, got %+v", stats)
+	}
+	for _, reason := range []string{ReasonInvalidFormat, ReasonInvalidTimestamp, ReasonEmptyLine} {
+		if stats.ByReason[reason] != 1 {
+			t.Errorf("expected one %s line, got %v", reason, stats.ByReason)
+		}
+	}
+}
@@ -1,12 +1,21 @@
 package parser
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrInvalidFormat = errors.New("invalid log format")
 	ErrInvalidRegex  = errors.New("invalid regex pattern")
 	ErrEmptyLine     = errors.New("empty log line")
 	ErrNoMatchRules  = errors.New("no matching rules")
+	ErrUnknownFormat = errors.New("unknown log format")
+	ErrUnknownLevel  = errors.New("unknown log level")
+
+	// ErrInvalidTimestamp is an ErrInvalidFormat for a line whose timestamp
+	// is missing or fits no layout.
+	ErrInvalidTimestamp = fmt.Errorf("%w: invalid timestamp", ErrInvalidFormat)
 )
 
 type ParseError struct {
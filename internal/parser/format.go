@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"fmt"
+)
+
+const (
+	FormatDefault = "default"
+	FormatJSON    = "json"
+	FormatLogfmt  = "logfmt"
+	FormatRegex   = "regex"
+)
+
+// Format decodes a line into an entry. Rule matching, source and labels are
+// handled by the Parser.
+type Format interface {
+	Decode(line []byte) (LogEntry, *ParseError)
+}
+
+// FormatConfig selects a format and its settings.
+type FormatConfig struct {
+	// Type is one of the Format* constants; empty means FormatDefault.
+	Type string
+
+	// JSON keys. Empty keys fall back to the common names used by zap,
+	// slog and zerolog.
+	TimeKey    string
+	LevelKey   string
+	MessageKey string
+
+	// Pattern is the regex format's pattern with (?P<ts>), (?P<level>) and
+	// (?P<msg>) groups; other named groups become fields. TimeLayout is the
+	// time.Parse layout of the ts group, or empty for the default layouts.
+	Pattern    string
+	TimeLayout string
+
+	Time TimeConfig
+
+	// Passthrough keeps lines the format can't decode as raw entries.
+	// Time.ReadTimeFallback implies it.
+	Passthrough bool
+}
+
+type defaultFormat struct {
+	tp *timeParser
+}
+
+func (f defaultFormat) Decode(line []byte) (LogEntry, *ParseError) {
+	return decodeDefault(line, f.tp)
+}
+
+func NewFormat(cfg FormatConfig) (Format, error) {
+	tp, err := newTimeParser(cfg.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	var f Format
+	switch cfg.Type {
+	case "", FormatDefault:
+		f = defaultFormat{tp: tp}
+	case FormatJSON:
+		f = newJSONFormat(cfg, tp)
+	case FormatLogfmt:
+		f = logfmtFormat{tp: tp}
+	case FormatRegex:
+		rf, err := newRegexFormat(cfg, tp)
+		if err != nil {
+			return nil, err
+		}
+		f = rf
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, cfg.Type)
+	}
+
+	if cfg.Passthrough || cfg.Time.ReadTimeFallback {
+		f = passthroughFormat{format: f, tp: tp}
+	}
+	return f, nil
+}
+
+// passthroughFormat keeps lines its format can't decode as raw entries.
+type passthroughFormat struct {
+	format Format
+	tp     *timeParser
+}
+
+func (f passthroughFormat) Decode(line []byte) (LogEntry, *ParseError) {
+	entry, err := f.format.Decode(line)
+	if err == nil {
+		return entry, nil
+	}
+	return rawEntry(line, f.tp.now(), ""), nil
+}
+
+// NewFormats builds formats keyed by source name.
+func NewFormats(configs map[string]FormatConfig) (map[string]Format, error) {
+	formats := make(map[string]Format, len(configs))
+	for source, cfg := range configs {
+		f, err := NewFormat(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("source %q: %w", source, err)
+		}
+		formats[source] = f
+	}
+	return formats, nil
+}
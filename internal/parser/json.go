@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+var (
+	jsonTimeKeys    = []string{"time", "ts", "timestamp"}
+	jsonLevelKeys   = []string{"level", "lvl", "severity"}
+	jsonMessageKeys = []string{"msg", "message"}
+)
+
+// jsonFormat decodes one JSON object per line. The time, level and message
+// keys fill the entry; all other keys become fields.
+type jsonFormat struct {
+	tp          *timeParser
+	timeKeys    []string
+	levelKeys   []string
+	messageKeys []string
+}
+
+func newJSONFormat(cfg FormatConfig, tp *timeParser) *jsonFormat {
+	return &jsonFormat{
+		tp:          tp,
+		timeKeys:    keysOrDefault(cfg.TimeKey, jsonTimeKeys),
+		levelKeys:   keysOrDefault(cfg.LevelKey, jsonLevelKeys),
+		messageKeys: keysOrDefault(cfg.MessageKey, jsonMessageKeys),
+	}
+}
+
+func keysOrDefault(key string, defaults []string) []string {
+	if key == "" {
+		return defaults
+	}
+	return []string{key}
+}
+
+func (f *jsonFormat) Decode(line []byte) (LogEntry, *ParseError) {
+	if len(line) == 0 || line[0] != '{' {
+		return LogEntry{}, &ParseError{Line: string(line), Reason: ErrInvalidFormat}
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(line, &obj); err != nil {
+		return LogEntry{}, &ParseError{Line: string(line), Reason: ErrInvalidFormat}
+	}
+
+	rawTime, _ := takeJSON(obj, f.timeKeys)
+	timestamp, err := f.parseTime(rawTime)
+	if err != nil {
+		return LogEntry{}, &ParseError{Line: string(line), Reason: err}
+	}
+
+	entry := LogEntry{
+		Timestamp: timestamp,
+		Level:     LevelInfo,
+		Raw:       line,
+	}
+	if raw, ok := takeJSON(obj, f.levelKeys); ok {
+		entry.Level = ParseLevel(jsonString(raw))
+	}
+	if raw, ok := takeJSON(obj, f.messageKeys); ok {
+		entry.Message = []byte(jsonString(raw))
+	}
+
+	if len(obj) > 0 {
+		entry.Fields = make(map[string]string, len(obj))
+		for k, v := range obj {
+			entry.Fields[k] = jsonString(v)
+		}
+	}
+	return entry, nil
+}
+
+// takeJSON removes the first of keys present in obj and returns its value.
+func takeJSON(obj map[string]json.RawMessage, keys []string) (json.RawMessage, bool) {
+	for _, k := range keys {
+		if v, ok := obj[k]; ok {
+			delete(obj, k)
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// jsonString returns strings unquoted, null as empty and any other value as
+// its JSON text.
+func jsonString(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) > 0 && raw[0] == '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+	case string(raw) == "null":
+		return ""
+	}
+	return string(raw)
+}
+
+// parseTime accepts a timestamp string or an epoch number, read as Unix
+// seconds (as zap writes by default) unless an epoch layout is configured.
+func (f *jsonFormat) parseTime(raw json.RawMessage) (time.Time, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return f.tp.unparsed()
+	}
+	if raw[0] == '"' {
+		return f.tp.parse(jsonString(raw))
+	}
+
+	for _, layout := range f.tp.layouts {
+		if ts, err := parseLayout(layout, string(raw), f.tp.loc); err == nil {
+			return ts, nil
+		}
+	}
+	if ts, err := parseEpoch(string(raw), time.Second); err == nil {
+		return ts, nil
+	}
+	return f.tp.unparsed()
+}
@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/input"
+)
+
+func TestJSONFormat_Decode(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       FormatConfig
+		line      string
+		wantTime  time.Time
+		wantLevel LogLevel
+		wantMsg   string
+		wantField map[string]string
+	}{
+		{
+			name:      "slog",
+			line:      `{"time":"2024-01-15T10:30:00.5Z","level":"ERROR","msg":"db down","attempt":3}`,
+			wantTime:  time.Date(2024, 1, 15, 10, 30, 0, 5e8, time.UTC),
+			wantLevel: LevelError,
+			wantMsg:   "db down",
+			wantField: map[string]string{"attempt": "3"},
+		},
+		{
+			name:      "zap epoch seconds",
+			line:      `{"level":"debug","ts":1705314600.25,"caller":"main.go:12","msg":"tick"}`,
+			wantTime:  time.Date(2024, 1, 15, 10, 30, 0, 25e7, time.UTC),
+			wantLevel: LevelDebug,
+			wantMsg:   "tick",
+			wantField: map[string]string{"caller": "main.go:12"},
+		},
+		{
+			name:      "zerolog",
+			line:      `{"level":"info","time":"2024-01-15T10:30:00Z","message":"ok","req":{"id":7},"x":null}`,
+			wantTime:  time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+			wantLevel: LevelInfo,
+			wantMsg:   "ok",
+			wantField: map[string]string{"req": `{"id":7}`, "x": ""},
+		},
+		{
+			name:      "configured keys",
+			cfg:       FormatConfig{TimeKey: "@timestamp", LevelKey: "log.level", MessageKey: "text"},
+			line:      `{"@timestamp":"2024-01-15 10:30:00","log.level":"error","text":"custom","msg":"kept"}`,
+			wantTime:  time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+			wantLevel: LevelError,
+			wantMsg:   "custom",
+			wantField: map[string]string{"msg": "kept"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Type = FormatJSON
+			f, err := NewFormat(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewFormat() unexpected error: %v", err)
+			}
+
+			got, perr := f.Decode([]byte(tt.line))
+			if perr != nil {
+				t.Fatalf("Decode() unexpected error: %v", perr)
+			}
+			if !got.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Decode() timestamp = %v, want %v", got.Timestamp, tt.wantTime)
+			}
+			if got.Level != tt.wantLevel {
+				t.Errorf("Decode() level = %v, want %v", got.Level, tt.wantLevel)
+			}
+			if string(got.Message) != tt.wantMsg {
+				t.Errorf("Decode() message = %q, want %q", got.Message, tt.wantMsg)
+			}
+			if len(got.Fields) != len(tt.wantField) {
+				t.Errorf("Decode() fields = %v, want %v", got.Fields, tt.wantField)
+			}
+			for k, v := range tt.wantField {
+				if got.Fields[k] != v {
+					t.Errorf("Decode() field %q = %q, want %q", k, got.Fields[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONFormat_Invalid(t *testing.T) {
+	f, err := NewFormat(FormatConfig{Type: FormatJSON})
+	if err != nil {
+		t.Fatalf("NewFormat() unexpected error: %v", err)
+	}
+
+	for _, line := range []string{
+		`2024-01-15T10:30:00Z [INFO] not json`,
+		`{"msg":"no time"}`,
+		`{"time":"yesterday","msg":"bad time"}`,
+		`{"time":"2024-01-15T10:30:00Z",`,
+	} {
+		if _, perr := f.Decode([]byte(line)); perr == nil {
+			t.Errorf("Decode(%q) expected error", line)
+		}
+	}
+}
+
+func TestNewFormat_Unknown(t *testing.T) {
+	_, err := NewFormat(FormatConfig{Type: "xml"})
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("NewFormat() error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestParser_SourceFormats(t *testing.T) {
+	p := NewParser(nil)
+	formats, err := NewFormats(map[string]FormatConfig{"api": {Type: FormatJSON}})
+	if err != nil {
+		t.Fatalf("NewFormats() unexpected error: %v", err)
+	}
+	p.SetFormats(formats)
+
+	jsonLine := []byte(`{"time":"2024-01-15T10:30:00Z","level":"error","msg":"from api"}`)
+	got, perr := p.parseInput(input.Line{Source: "api", Data: jsonLine})
+	if perr != nil {
+		t.Fatalf("parseInput() unexpected error: %v", perr)
+	}
+	if string(got.Message) != "from api" || got.Level != LevelError {
+		t.Errorf("parseInput() = %+v", got)
+	}
+
+	if _, perr := p.parseInput(input.Line{Source: "other", Data: jsonLine}); perr == nil {
+		t.Error("parseInput() expected other sources to use the default format")
+	}
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type LogLevel string
+
+// Levels from least to most severe.
+const (
+	LevelTrace    LogLevel = "TRACE"
+	LevelDebug    LogLevel = "DEBUG"
+	LevelInfo     LogLevel = "INFO"
+	LevelWarn     LogLevel = "WARN"
+	LevelError    LogLevel = "ERROR"
+	LevelCritical LogLevel = "CRITICAL"
+	LevelFatal    LogLevel = "FATAL"
+
+	// LevelUnknown marks raw entries whose level couldn't be read. It ranks
+	// as INFO.
+	LevelUnknown LogLevel = "UNKNOWN"
+)
+
+var levelSeverity = map[LogLevel]int{
+	LevelTrace:    0,
+	LevelDebug:    1,
+	LevelInfo:     2,
+	LevelWarn:     3,
+	LevelError:    4,
+	LevelCritical: 5,
+	LevelFatal:    6,
+}
+
+// levelNames maps lowercase names and aliases to levels.
+var levelNames = map[string]LogLevel{
+	"trace":         LevelTrace,
+	"debug":         LevelDebug,
+	"dbg":           LevelDebug,
+	"info":          LevelInfo,
+	"information":   LevelInfo,
+	"informational": LevelInfo,
+	"notice":        LevelInfo,
+	"warn":          LevelWarn,
+	"warning":       LevelWarn,
+	"error":         LevelError,
+	"err":           LevelError,
+	"critical":      LevelCritical,
+	"crit":          LevelCritical,
+	"alert":         LevelCritical,
+	"fatal":         LevelFatal,
+	"panic":         LevelFatal,
+	"emerg":         LevelFatal,
+	"emergency":     LevelFatal,
+}
+
+// syslogLevels maps syslog severities 0 (emergency) to 7 (debug).
+var syslogLevels = [8]LogLevel{
+	LevelFatal,
+	LevelCritical,
+	LevelCritical,
+	LevelError,
+	LevelWarn,
+	LevelInfo,
+	LevelInfo,
+	LevelDebug,
+}
+
+// Severity orders levels; unknown levels rank as INFO.
+func (l LogLevel) Severity() int {
+	if s, ok := levelSeverity[l]; ok {
+		return s
+	}
+	return levelSeverity[LevelInfo]
+}
+
+// AtLeast reports whether l is as severe as minLevel. An empty minLevel
+// allows every level.
+func (l LogLevel) AtLeast(minLevel LogLevel) bool {
+	return minLevel == "" || l.Severity() >= minLevel.Severity()
+}
+
+// SyslogLevel maps a syslog severity (0-7) onto a level.
+func SyslogLevel(severity int) LogLevel {
+	if severity < 0 || severity >= len(syslogLevels) {
+		return LevelInfo
+	}
+	return syslogLevels[severity]
+}
+
+// LookupLevel finds a level by name or alias, in any case, or by syslog
+// severity number.
+func LookupLevel(s string) (LogLevel, bool) {
+	return lookupLevelBytes([]byte(s))
+}
+
+// ParseLevel is LookupLevel with unknown levels read as INFO.
+func ParseLevel(s string) LogLevel {
+	return parseLevelBytes([]byte(s))
+}
+
+// ParseMinLevel parses a level used as a threshold. Empty means no threshold.
+func ParseMinLevel(s string) (LogLevel, error) {
+	if s == "" {
+		return "", nil
+	}
+	level, ok := LookupLevel(s)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownLevel, s)
+	}
+	return level, nil
+}
+
+func parseLevelBytes(s []byte) LogLevel {
+	if level, ok := lookupLevelBytes(s); ok {
+		return level
+	}
+	return LevelInfo
+}
+
+func lookupLevelBytes(s []byte) (LogLevel, bool) {
+	if len(s) == 1 && s[0] >= '0' && s[0] <= '7' {
+		severity, _ := strconv.Atoi(string(s))
+		return SyslogLevel(severity), true
+	}
+
+	// Lowercase on the stack; no level name is longer than the buffer
+	var buf [16]byte
+	if len(s) > len(buf) {
+		return "", false
+	}
+	for i, c := range s {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		buf[i] = c
+	}
+	level, ok := levelNames[string(buf[:len(s)])]
+	return level, ok
+}
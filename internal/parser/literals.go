@@ -0,0 +1,226 @@
+package parser
+
+import (
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+const (
+	// maxRequiredLiterals caps the alternatives kept for one pattern; a
+	// pattern with more runs on every line.
+	maxRequiredLiterals = 16
+	// minRequiredLiteral is the shortest literal worth prefiltering on.
+	minRequiredLiteral = 2
+)
+
+// requiredLiterals returns strings one of which is in every text the pattern
+// matches, or nil when there are none to rely on. The literals are lowercase
+// ASCII-wise, to be looked up with literalSet.
+func requiredLiterals(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	lits := literalsOf(re.Simplify())
+	for _, lit := range lits {
+		if len(lit) < minRequiredLiteral {
+			return nil
+		}
+	}
+	return lits
+}
+
+func literalsOf(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if lit := literalString(re); lit != "" {
+			return []string{lit}
+		}
+	case syntax.OpCapture, syntax.OpPlus:
+		return literalsOf(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return literalsOf(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var best []string
+		for _, sub := range re.Sub {
+			if lits := literalsOf(sub); lits != nil && betterLiterals(lits, best) {
+				best = lits
+			}
+		}
+		return best
+	case syntax.OpAlternate:
+		var all []string
+		for _, sub := range re.Sub {
+			lits := literalsOf(sub)
+			if lits == nil {
+				return nil
+			}
+			all = append(all, lits...)
+		}
+		if len(all) <= maxRequiredLiterals {
+			return all
+		}
+	}
+	return nil
+}
+
+// betterLiterals prefers the set whose shortest literal is longer, then the
+// smaller set.
+func betterLiterals(a, b []string) bool {
+	if b == nil {
+		return true
+	}
+	la, lb := shortest(a), shortest(b)
+	return la > lb || (la == lb && len(a) < len(b))
+}
+
+func shortest(lits []string) int {
+	n := -1
+	for _, lit := range lits {
+		if n < 0 || len(lit) < n {
+			n = len(lit)
+		}
+	}
+	return n
+}
+
+// literalString lowercases a literal node. A case-insensitive literal is cut
+// to its longest run of ASCII letters that fold only to ASCII; 'k' and 's'
+// also match the Kelvin sign and long s, so they end a run.
+func literalString(re *syntax.Regexp) string {
+	if re.Flags&syntax.FoldCase == 0 {
+		buf := make([]byte, 0, len(re.Rune))
+		for _, r := range re.Rune {
+			buf = utf8.AppendRune(buf, r)
+		}
+		return string(lowerASCII(buf))
+	}
+
+	var best, cur []byte
+	for _, r := range re.Rune {
+		c := byte(lowerRune(r))
+		if r >= utf8.RuneSelf || c == 'k' || c == 's' {
+			cur = cur[:0]
+			continue
+		}
+		cur = append(cur, c)
+		if len(cur) > len(best) {
+			best = append(best[:0], cur...)
+		}
+	}
+	return string(best)
+}
+
+func lowerRune(r rune) rune {
+	if r >= 'A' && r <= 'Z' {
+		return r + 'a' - 'A'
+	}
+	return r
+}
+
+func lowerASCII(b []byte) []byte {
+	for i, c := range b {
+		b[i] = lowerByte(c)
+	}
+	return b
+}
+
+func lowerByte(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// literalSet is an Aho-Corasick automaton over literals, matching ASCII
+// letters in any case. Bytes that appear in no literal share one class to
+// keep the transition table small.
+type literalSet struct {
+	classes [256]int32
+	nclass  int32
+	// delta is the full transition table, state*nclass + class.
+	delta []int32
+	// out lists the ids of the literals ending in each state.
+	out [][]int
+}
+
+// newLiteralSet builds the automaton; lits[i] is reported as ids[i].
+func newLiteralSet(lits []string, ids []int) *literalSet {
+	s := &literalSet{nclass: 1}
+	for _, lit := range lits {
+		for i := 0; i < len(lit); i++ {
+			c := lowerByte(lit[i])
+			if s.classes[c] == 0 {
+				s.classes[c] = s.nclass
+				s.nclass++
+			}
+		}
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		s.classes[c] = s.classes[c+'a'-'A']
+	}
+
+	s.addState()
+	for i, lit := range lits {
+		state := int32(0)
+		for j := 0; j < len(lit); j++ {
+			idx := state*s.nclass + s.classes[lit[j]]
+			if s.delta[idx] < 0 {
+				s.delta[idx] = s.addState()
+			}
+			state = s.delta[idx]
+		}
+		s.out[state] = append(s.out[state], ids[i])
+	}
+
+	// Fill in the missing transitions breadth-first, following the failure
+	// links of states one byte shorter.
+	fail := make([]int32, len(s.out))
+	queue := make([]int32, 0, len(s.out))
+	for c := range s.nclass {
+		if t := s.delta[c]; t < 0 {
+			s.delta[c] = 0
+		} else {
+			queue = append(queue, t)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		s.out[state] = append(s.out[state], s.out[fail[state]]...)
+		for c := range s.nclass {
+			idx := state*s.nclass + c
+			failNext := s.delta[fail[state]*s.nclass+c]
+			if t := s.delta[idx]; t < 0 {
+				s.delta[idx] = failNext
+			} else {
+				fail[t] = failNext
+				queue = append(queue, t)
+			}
+		}
+	}
+	return s
+}
+
+func (s *literalSet) addState() int32 {
+	id := int32(len(s.out))
+	for range s.nclass {
+		s.delta = append(s.delta, -1)
+	}
+	s.out = append(s.out, nil)
+	return id
+}
+
+// scan calls found with the id of every literal in text, possibly more than
+// once.
+func (s *literalSet) scan(text []byte, found func(id int)) {
+	state := int32(0)
+	for _, c := range text {
+		state = s.delta[state*s.nclass+s.classes[c]]
+		for _, id := range s.out[state] {
+			found(id)
+		}
+	}
+}
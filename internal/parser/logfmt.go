@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+)
+
+var (
+	logfmtTimeKeys    = []string{"ts", "time"}
+	logfmtLevelKeys   = []string{"level", "lvl"}
+	logfmtMessageKeys = []string{"msg", "message"}
+)
+
+// logfmtFormat decodes `key=value key="quoted value"` lines. The time, level
+// and message keys fill the entry; all other keys become fields.
+type logfmtFormat struct {
+	tp *timeParser
+}
+
+func (f logfmtFormat) Decode(line []byte) (LogEntry, *ParseError) {
+	pairs, ok := parseLogfmt(line)
+	if !ok {
+		return LogEntry{}, &ParseError{Line: string(line), Reason: ErrInvalidFormat}
+	}
+
+	var timestamp time.Time
+	var err error
+	if tsStr, ok := takeLogfmt(pairs, logfmtTimeKeys); ok {
+		timestamp, err = f.tp.parse(tsStr)
+	} else {
+		timestamp, err = f.tp.unparsed()
+	}
+	if err != nil {
+		return LogEntry{}, &ParseError{Line: string(line), Reason: err}
+	}
+
+	entry := LogEntry{
+		Timestamp: timestamp,
+		Level:     LevelInfo,
+		Raw:       line,
+	}
+	if level, ok := takeLogfmt(pairs, logfmtLevelKeys); ok {
+		entry.Level = ParseLevel(level)
+	}
+	if msg, ok := takeLogfmt(pairs, logfmtMessageKeys); ok {
+		entry.Message = []byte(msg)
+	}
+
+	if len(pairs) > 0 {
+		entry.Fields = pairs
+	}
+	return entry, nil
+}
+
+func takeLogfmt(pairs map[string]string, keys []string) (string, bool) {
+	for _, k := range keys {
+		if v, ok := pairs[k]; ok {
+			delete(pairs, k)
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// parseLogfmt splits a line into key/value pairs. A key without "=" gets an
+// empty value; quoted values are unquoted with Go escapes. It reports false
+// for lines that aren't logfmt, such as unterminated quotes or prose without
+// a single key=value pair.
+func parseLogfmt(line []byte) (map[string]string, bool) {
+	pairs := make(map[string]string, 8)
+	hasValue := false
+	for {
+		line = bytes.TrimLeft(line, " \t")
+		if len(line) == 0 {
+			return pairs, hasValue
+		}
+
+		end := bytes.IndexAny(line, "= \t")
+		if end == -1 {
+			end = len(line)
+		}
+		if end == 0 || bytes.IndexByte(line[:end], '"') != -1 {
+			return nil, false
+		}
+		key := string(line[:end])
+		line = line[end:]
+
+		if len(line) == 0 || line[0] != '=' {
+			pairs[key] = ""
+			continue
+		}
+		line = line[1:]
+		hasValue = true
+
+		if len(line) > 0 && line[0] == '"' {
+			n := quotedLen(line)
+			if n == -1 {
+				return nil, false
+			}
+			value, err := strconv.Unquote(string(line[:n]))
+			if err != nil {
+				return nil, false
+			}
+			pairs[key] = value
+			line = line[n:]
+			continue
+		}
+
+		end = bytes.IndexAny(line, " \t")
+		if end == -1 {
+			end = len(line)
+		}
+		pairs[key] = string(line[:end])
+		line = line[end:]
+	}
+}
+
+// quotedLen returns the length of the quoted string at the start of data,
+// including both quotes, or -1 if it is not terminated.
+func quotedLen(data []byte) int {
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLogfmtFormat_Decode(t *testing.T) {
+	line := `ts=2024-01-15T10:30:00Z level=error msg="user \"bob\" not found\ttwice" ` +
+		`user=42 path=/login empty= flag`
+
+	got, perr := logfmtFormat{tp: defaultTimeParser}.Decode([]byte(line))
+	if perr != nil {
+		t.Fatalf("Decode() unexpected error: %v", perr)
+	}
+
+	if want := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC); !got.Timestamp.Equal(want) {
+		t.Errorf("Decode() timestamp = %v, want %v", got.Timestamp, want)
+	}
+	if got.Level != LevelError {
+		t.Errorf("Decode() level = %v, want %v", got.Level, LevelError)
+	}
+	if want := "user \"bob\" not found\ttwice"; string(got.Message) != want {
+		t.Errorf("Decode() message = %q, want %q", got.Message, want)
+	}
+
+	wantFields := map[string]string{"user": "42", "path": "/login", "empty": "", "flag": ""}
+	if len(got.Fields) != len(wantFields) {
+		t.Errorf("Decode() fields = %v, want %v", got.Fields, wantFields)
+	}
+	for k, v := range wantFields {
+		if got.Fields[k] != v {
+			t.Errorf("Decode() field %q = %q, want %q", k, got.Fields[k], v)
+		}
+	}
+}
+
+func TestLogfmtFormat_AlternativeKeys(t *testing.T) {
+	got, perr := logfmtFormat{tp: defaultTimeParser}.Decode(
+		[]byte(`time="2024-01-15 10:30:00" lvl=debug message=started`),
+	)
+	if perr != nil {
+		t.Fatalf("Decode() unexpected error: %v", perr)
+	}
+	if got.Level != LevelDebug || string(got.Message) != "started" || got.Fields != nil {
+		t.Errorf("Decode() = %+v", got)
+	}
+}
+
+func TestLogfmtFormat_ProseIsInvalidFormat(t *testing.T) {
+	f := logfmtFormat{tp: defaultTimeParser}
+	for _, line := range []string{`connection reset by peer`, `panic`} {
+		_, perr := f.Decode([]byte(line))
+		if perr == nil || !errors.Is(perr.Reason, ErrInvalidFormat) {
+			t.Errorf("Decode(%q) = %v, expected ErrInvalidFormat", line, perr)
+		}
+	}
+}
+
+func TestLogfmtFormat_Invalid(t *testing.T) {
+	f := logfmtFormat{tp: defaultTimeParser}
+	for _, line := range []string{
+		`2024-01-15T10:30:00Z [INFO] not logfmt`,
+		`level=info msg=no-time`,
+		`ts=2024-01-15T10:30:00Z msg="unterminated`,
+		`ts=2024-01-15T10:30:00Z =value`,
+		`ts=yesterday msg=bad`,
+	} {
+		if _, perr := f.Decode([]byte(line)); perr == nil {
+			t.Errorf("Decode(%q) expected error", line)
+		}
+	}
+}
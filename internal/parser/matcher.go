@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"iter"
+	"regexp"
+)
+
+// Matcher matches a line against many patterns at once. Identical patterns
+// run once per line, and a pattern with required literals runs only when one
+// of them is in the line, which a single pass over the line finds for all
+// patterns.
+type Matcher struct {
+	// regexes are the distinct patterns; index maps each pattern given to
+	// NewMatcher to its regex.
+	regexes []*regexp.Regexp
+	index   []int
+
+	// always lists the regexes without literals to prefilter on.
+	always   []int
+	literals *literalSet
+}
+
+func NewMatcher(patterns []*regexp.Regexp) *Matcher {
+	m := &Matcher{index: make([]int, len(patterns))}
+
+	byPattern := make(map[string]int, len(patterns))
+	var (
+		lits []string
+		ids  []int
+	)
+	for i, re := range patterns {
+		key := re.String()
+		id, ok := byPattern[key]
+		if !ok {
+			id = len(m.regexes)
+			byPattern[key] = id
+			m.regexes = append(m.regexes, re)
+
+			if required := requiredLiterals(key); required != nil {
+				for _, lit := range required {
+					lits = append(lits, lit)
+					ids = append(ids, id)
+				}
+			} else {
+				m.always = append(m.always, id)
+			}
+		}
+		m.index[i] = id
+	}
+	if len(lits) > 0 {
+		m.literals = newLiteralSet(lits, ids)
+	}
+	return m
+}
+
+// Matches holds which patterns matched one line. Patterns are run lazily,
+// on the first Match call for them. It is not safe for concurrent use.
+type Matches struct {
+	m     *Matcher
+	line  []byte
+	state []matchState
+}
+
+type matchState uint8
+
+const (
+	matchSkipped matchState = iota
+	matchCandidate
+	matchYes
+	matchNo
+)
+
+// Scan finds the patterns that may match line.
+func (m *Matcher) Scan(line []byte) *Matches {
+	ms := &Matches{m: m, line: line, state: make([]matchState, len(m.regexes))}
+	for _, id := range m.always {
+		ms.state[id] = matchCandidate
+	}
+	if m.literals != nil {
+		m.literals.scan(line, func(id int) {
+			ms.state[id] = matchCandidate
+		})
+	}
+	return ms
+}
+
+// Match reports whether the i-th pattern given to NewMatcher matches.
+func (ms *Matches) Match(i int) bool {
+	id := ms.m.index[i]
+	switch ms.state[id] {
+	case matchCandidate:
+		if ms.m.regexes[id].Match(ms.line) {
+			ms.state[id] = matchYes
+		} else {
+			ms.state[id] = matchNo
+		}
+		return ms.state[id] == matchYes
+	case matchYes:
+		return true
+	default:
+		return false
+	}
+}
+
+// RuleMatcher matches entries against rules by raw line and minimum level,
+// with a Matcher over their patterns.
+type RuleMatcher struct {
+	rules   []Rule
+	matcher *Matcher
+}
+
+func NewRuleMatcher(rules []Rule) *RuleMatcher {
+	return &RuleMatcher{rules: rules, matcher: newRulesMatcher(rules)}
+}
+
+// Rules returns the rules given to NewRuleMatcher.
+func (m *RuleMatcher) Rules() []Rule {
+	return m.rules
+}
+
+// Match yields the rules that match the entry, in order. The line is only
+// scanned if a rule takes the entry's level.
+func (m *RuleMatcher) Match(entry LogEntry) iter.Seq[Rule] {
+	return func(yield func(Rule) bool) {
+		var matches *Matches
+		for i, r := range m.rules {
+			if !entry.Level.AtLeast(r.MinLevel) {
+				continue
+			}
+			if matches == nil {
+				matches = m.matcher.Scan(entry.Raw)
+			}
+			if matches.Match(i) && !yield(r) {
+				return
+			}
+		}
+	}
+}
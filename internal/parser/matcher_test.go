@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`postgres`, []string{"postgres"}},
+		{`.*ERROR.*`, []string{"error"}},
+		{`^(\d{4}-\d{2}-\d{2}) \[(?P<level>\w+)\] timeout`, []string{"] timeout"}},
+		{`timeout|refused`, []string{"timeout", "refused"}},
+		{`(?i)panic: (runtime|fatal)`, []string{"panic: "}},
+		{`(?i)disk`, []string{"di"}},
+		{`(?i)sk`, nil},
+		{`(foo)?bar`, []string{"bar"}},
+		{`(foo)*`, nil},
+		{`\d+`, nil},
+		{`a|\d`, nil},
+		{``, nil},
+	}
+
+	for _, tt := range tests {
+		if got := requiredLiterals(tt.pattern); !slices.Equal(got, tt.want) {
+			t.Errorf("requiredLiterals(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestLiteralSet_Scan(t *testing.T) {
+	s := newLiteralSet([]string{"he", "she", "his", "hers"}, []int{0, 1, 2, 3})
+
+	var found []int
+	s.scan([]byte("USHERS"), func(id int) {
+		if !slices.Contains(found, id) {
+			found = append(found, id)
+		}
+	})
+	slices.Sort(found)
+	if !slices.Equal(found, []int{0, 1, 3}) {
+		t.Errorf("expected literals 0, 1 and 3, got %v", found)
+	}
+}
+
+func TestMatcher_AgreesWithRegexp(t *testing.T) {
+	patterns := []string{
+		`postgres`,
+		`.*ERROR.*`,
+		`timeout|refused`,
+		`(?i)panic: (runtime|fatal)`,
+		`(?i)kelvin`,
+		`\d{3} ms$`,
+		`postgres`,
+		``,
+	}
+	lines := []string{
+		"2024-01-15T10:30:00Z [ERROR] postgres down",
+		"connection REFUSED",
+		"connection refused by peer",
+		"PANIC: Runtime error",
+		"kelvin scale",
+		"\u212Aelvin scale",
+		"took 250 ms",
+		"nothing here",
+		"",
+	}
+
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		res[i] = regexp.MustCompile(p)
+	}
+	m := NewMatcher(res)
+	if len(m.regexes) != len(patterns)-1 {
+		t.Errorf("expected identical patterns to share a regex, got %d regexes", len(m.regexes))
+	}
+
+	for _, line := range lines {
+		matches := m.Scan([]byte(line))
+		for i, re := range res {
+			if got, want := matches.Match(i), re.MatchString(line); got != want {
+				t.Errorf("pattern %q on %q: got %v, want %v", patterns[i], line, got, want)
+			}
+		}
+	}
+}
+
+func TestRuleMatcher_Match(t *testing.T) {
+	m := NewRuleMatcher([]Rule{
+		{Name: "timeout", Regex: regexp.MustCompile(`timeout`)},
+		{Name: "db errors", Regex: regexp.MustCompile(`db`), MinLevel: LevelError},
+		{Name: "db", Regex: regexp.MustCompile(`db`)},
+	})
+
+	var names []string
+	for r := range m.Match(LogEntry{Level: LevelWarn, Raw: []byte("db timeout")}) {
+		names = append(names, r.Name)
+	}
+	if want := []string{"timeout", "db"}; !slices.Equal(names, want) {
+		t.Errorf("Match() = %q, want %q", names, want)
+	}
+
+	names = nil
+	for r := range m.Match(LogEntry{Level: LevelError, Raw: []byte("db down")}) {
+		names = append(names, r.Name)
+	}
+	if want := []string{"db errors", "db"}; !slices.Equal(names, want) {
+		t.Errorf("Match() = %q, want %q", names, want)
+	}
+}
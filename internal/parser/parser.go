@@ -9,6 +9,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/kxrxh/logram/internal/input"
 )
 
 var (
@@ -22,47 +24,112 @@ var (
 	ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*m`)
 )
 
-type LogLevel string
-
-const (
-	LevelDebug LogLevel = "DEBUG"
-	LevelInfo  LogLevel = "INFO"
-	LevelError LogLevel = "ERROR"
-)
-
 type LogEntry struct {
 	Timestamp time.Time
 	Level     LogLevel
 	Message   []byte
 	RuleName  string
 	Raw       []byte
+
+	// Where the line came from, see input.Line.
+	Source string
+	File   string
+	Labels map[string]string
+
+	// Fields holds named values from formats that carry more than a
+	// timestamp, level and message, such as syslog's hostname.
+	Fields map[string]string
 }
 
 type Rule struct {
 	Name  string
 	Regex *regexp.Regexp
+	// MinLevel, when set, limits the rule to entries at least this severe.
+	MinLevel LogLevel
+	// Passthrough keeps matching lines that the format can't decode, as
+	// raw entries.
+	Passthrough bool
 }
 
 type RuleConfig struct {
 	Name    string
 	Pattern string
+	// MinLevel is a level name such as "WARN"; a rule with a level and no
+	// pattern matches every line at that level or above.
+	MinLevel    string
+	Passthrough bool
+	// Threshold turns a chat's rule into an alert on the rate of matches.
+	Threshold Threshold
+	// Expect is how often the rule should match at least; 0 means any.
+	Expect time.Duration
+}
+
+// Threshold is a number of matches within a window.
+type Threshold struct {
+	Count  int
+	Window time.Duration
+}
+
+// Enabled reports whether the threshold is set.
+func (t Threshold) Enabled() bool {
+	return t.Count > 0 && t.Window > 0
 }
 
 type Parser struct {
 	mu    sync.RWMutex
 	rules []Rule
+	// matcher runs the rules' patterns, in the same order.
+	matcher *Matcher
+
+	// formats by source name; other sources use the default format.
+	formats map[string]Format
+	// passthrough rules keep undecodable lines when there are no rules to
+	// filter by.
+	passthrough []Rule
+
+	deadLetters *DeadLetters
+	workers     int
+}
+
+type Option func(*Parser)
+
+// WithWorkers sets how many goroutines Start parses lines on.
+func WithWorkers(n int) Option {
+	return func(p *Parser) {
+		if n > 0 {
+			p.workers = n
+		}
+	}
+}
+
+func NewParser(rules []Rule, opts ...Option) *Parser {
+	p := &Parser{
+		rules:       rules,
+		matcher:     newRulesMatcher(rules),
+		deadLetters: NewDeadLetters(defaultDeadLetterSamples),
+		workers:     1,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
-func NewParser(rules []Rule) *Parser {
-	return &Parser{
-		rules: rules,
+func NewParserFromConfig(rules []RuleConfig, opts ...Option) (*Parser, error) {
+	parsedRules, err := CompileRules(rules)
+	if err != nil {
+		return nil, err
 	}
+	return NewParser(parsedRules, opts...), nil
 }
 
-func NewParserFromConfig(rules []RuleConfig) (*Parser, error) {
+// CompileRules compiles rule configs, skipping rules without a name or
+// anything to match.
+func CompileRules(rules []RuleConfig) ([]Rule, error) {
 	var parsedRules []Rule
 	for _, r := range rules {
-		if strings.TrimSpace(r.Name) == "" || strings.TrimSpace(r.Pattern) == "" {
+		if strings.TrimSpace(r.Name) == "" ||
+			(strings.TrimSpace(r.Pattern) == "" && r.MinLevel == "") {
 			log.Printf("skip default log rule: name=%q pattern empty", r.Name)
 			continue
 		}
@@ -74,18 +141,52 @@ func NewParserFromConfig(rules []RuleConfig) (*Parser, error) {
 				Reason: err,
 			}
 		}
+		minLevel, err := ParseMinLevel(r.MinLevel)
+		if err != nil {
+			return nil, &RuleError{
+				Rule:   r.Name,
+				Reason: err,
+			}
+		}
 		parsedRules = append(parsedRules, Rule{
-			Name:  r.Name,
-			Regex: re,
+			Name:        r.Name,
+			Regex:       re,
+			MinLevel:    minLevel,
+			Passthrough: r.Passthrough,
 		})
 	}
-	return NewParser(parsedRules), nil
+	return parsedRules, nil
 }
 
 func (p *Parser) UpdateRules(rules []Rule) {
+	matcher := newRulesMatcher(rules)
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.rules = rules
+	p.matcher = matcher
+}
+
+func newRulesMatcher(rules []Rule) *Matcher {
+	patterns := make([]*regexp.Regexp, len(rules))
+	for i, r := range rules {
+		patterns[i] = r.Regex
+	}
+	return NewMatcher(patterns)
+}
+
+// SetFormats sets the formats of sources' lines, keyed by source name.
+func (p *Parser) SetFormats(formats map[string]Format) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.formats = formats
+}
+
+// SetPassthroughRules sets the rules whose matching lines pass through raw
+// when they can't be decoded, for a parser without filtering rules.
+func (p *Parser) SetPassthroughRules(rules []Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.passthrough = rules
 }
 
 func (p *Parser) Rules() []Rule {
@@ -94,27 +195,39 @@ func (p *Parser) Rules() []Rule {
 	return p.rules
 }
 
-func (p *Parser) Start(ctx context.Context, input <-chan []byte) <-chan LogEntry {
+// DeadLetters reports the lines Start couldn't parse.
+func (p *Parser) DeadLetters() *DeadLetters {
+	return p.deadLetters
+}
+
+// Start parses lines into entries. With several workers, lines are parsed
+// concurrently but entries come out in the order the lines came in.
+func (p *Parser) Start(ctx context.Context, lines <-chan input.Line) <-chan LogEntry {
 	output := make(chan LogEntry, 100)
 
+	if p.workers > 1 {
+		go p.runPool(ctx, lines, output)
+		return output
+	}
+
 	go func() {
 		defer close(output)
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case line, ok := <-input:
+			case line, ok := <-lines:
 				if !ok {
 					return
 				}
-				entry, err := p.ParseLine(line)
-				if err != nil {
+				entry, err := p.parseInput(line)
+				if !p.record(line, err) {
 					continue
 				}
 				select {
 				case <-ctx.Done():
 					return
-				case output <- entry:
+				case output <- withOrigin(entry, line):
 				}
 			}
 		}
@@ -123,6 +236,95 @@ func (p *Parser) Start(ctx context.Context, input <-chan []byte) <-chan LogEntry
 	return output
 }
 
+// parseJob is a line handed to a pool worker; done is closed once it's
+// parsed.
+type parseJob struct {
+	line  input.Line
+	entry LogEntry
+	err   *ParseError
+	done  chan struct{}
+}
+
+// runPool parses lines on p.workers goroutines. Jobs are queued in input
+// order in pending, and entries are sent in that order as each job is done.
+func (p *Parser) runPool(ctx context.Context, lines <-chan input.Line, output chan<- LogEntry) {
+	defer close(output)
+
+	jobs := make(chan *parseJob, p.workers)
+	pending := make(chan *parseJob, p.workers*4)
+
+	for range p.workers {
+		go func() {
+			for job := range jobs {
+				job.entry, job.err = p.parseInput(job.line)
+				close(job.done)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case line, ok := <-lines:
+				if !ok {
+					return
+				}
+				job := &parseJob{line: line, done: make(chan struct{})}
+				select {
+				case <-ctx.Done():
+					return
+				case pending <- job:
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case jobs <- job:
+				}
+			}
+		}
+	}()
+
+	for job := range pending {
+		select {
+		case <-ctx.Done():
+			return
+		case <-job.done:
+		}
+		if !p.record(job.line, job.err) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case output <- withOrigin(job.entry, job.line):
+		}
+	}
+}
+
+// record counts the line as accepted or rejected and reports whether it
+// parsed into an entry.
+func (p *Parser) record(line input.Line, err *ParseError) bool {
+	if err == nil {
+		p.deadLetters.Accept()
+		return true
+	}
+	if !errors.Is(err.Reason, ErrNoMatchRules) {
+		p.deadLetters.Reject(line.Source, err)
+	}
+	return false
+}
+
+func withOrigin(entry LogEntry, line input.Line) LogEntry {
+	entry.Source = line.Source
+	entry.File = line.File
+	entry.Labels = line.Labels
+	return entry
+}
+
 func (p *Parser) ParseLogStream(ch <-chan []byte) ([]LogEntry, []ParseError) {
 	entries := make([]LogEntry, 0, 100)
 	var errs []ParseError
@@ -143,6 +345,39 @@ func (p *Parser) ParseLogStream(ch <-chan []byte) ([]LogEntry, []ParseError) {
 }
 
 func (p *Parser) ParseLine(line []byte) (LogEntry, *ParseError) {
+	return p.parse(line, parseDefault)
+}
+
+// parseInput parses a line from an input with its source's format, or takes
+// the entry from its record when the input has already parsed it.
+func (p *Parser) parseInput(line input.Line) (LogEntry, *ParseError) {
+	if line.Record == nil {
+		p.mu.RLock()
+		format, ok := p.formats[line.Source]
+		p.mu.RUnlock()
+		if !ok {
+			return p.ParseLine(line.Data)
+		}
+		return p.parse(line.Data, format.Decode)
+	}
+
+	rec := line.Record
+	return p.parse(line.Data, func(raw []byte) (LogEntry, *ParseError) {
+		return LogEntry{
+			Timestamp: rec.Timestamp,
+			Level:     ParseLevel(rec.Level),
+			Message:   rec.Message,
+			Raw:       raw,
+			Fields:    rec.Fields,
+		}, nil
+	})
+}
+
+// parse matches the line against the rules and decodes it into an entry.
+func (p *Parser) parse(
+	line []byte,
+	decode func([]byte) (LogEntry, *ParseError),
+) (LogEntry, *ParseError) {
 	line = cleanLine(line)
 
 	if len(line) == 0 {
@@ -151,26 +386,67 @@ func (p *Parser) ParseLine(line []byte) (LogEntry, *ParseError) {
 
 	p.mu.RLock()
 	rules := p.rules
+	matcher := p.matcher
+	passthrough := p.passthrough
 	p.mu.RUnlock()
 
 	if len(rules) == 0 {
-		return parseDefault(line)
+		entry, err := decode(line)
+		if err == nil {
+			return entry, nil
+		}
+		for _, rule := range passthrough {
+			if rule.Regex.Match(line) {
+				return rawEntry(line, time.Now(), rule.Name), nil
+			}
+		}
+		return LogEntry{}, err
 	}
 
-	for _, rule := range rules {
-		if rule.Regex.Match(line) {
-			entry, err := parseDefault(line)
-			if err != nil {
-				return LogEntry{}, &ParseError{Line: string(line), Reason: err.Reason}
+	var (
+		entry     LogEntry
+		decodeErr *ParseError
+		decoded   bool
+	)
+	matches := matcher.Scan(line)
+	for i, rule := range rules {
+		if !matches.Match(i) {
+			continue
+		}
+		if !decoded {
+			entry, decodeErr = decode(line)
+			decoded = true
+		}
+		if decodeErr != nil {
+			if rule.Passthrough {
+				return rawEntry(line, time.Now(), rule.Name), nil
 			}
+			continue
+		}
+		if entry.Level.AtLeast(rule.MinLevel) {
 			entry.RuleName = rule.Name
 			return entry, nil
 		}
 	}
 
+	if decodeErr != nil {
+		return LogEntry{}, &ParseError{Line: string(line), Reason: decodeErr.Reason}
+	}
 	return LogEntry{}, &ParseError{Line: string(line), Reason: ErrNoMatchRules}
 }
 
+// rawEntry keeps a line that can't be decoded: the whole line is the
+// message, the level is unknown and the time is when it was read.
+func rawEntry(line []byte, readAt time.Time, ruleName string) LogEntry {
+	return LogEntry{
+		Timestamp: readAt,
+		Level:     LevelUnknown,
+		Message:   line,
+		RuleName:  ruleName,
+		Raw:       line,
+	}
+}
+
 func cleanLine(s []byte) []byte {
 	if !bytes.Contains(s, []byte("\x1b")) {
 		return s
@@ -178,23 +454,20 @@ func cleanLine(s []byte) []byte {
 	return ansiRegex.ReplaceAll(s, []byte(""))
 }
 
-func parseLevelBytes(s []byte) LogLevel {
-	if bytes.Equal(s, []byte("DEBUG")) {
-		return LevelDebug
-	}
-	if bytes.Equal(s, []byte("ERROR")) {
-		return LevelError
-	}
-	return LevelInfo
+func parseDefault(line []byte) (LogEntry, *ParseError) {
+	return decodeDefault(line, defaultTimeParser)
 }
 
-func parseDefault(line []byte) (LogEntry, *ParseError) {
-	tsStr, rest, found := bytes.Cut(line, []byte(" "))
-	if !found {
+func decodeDefault(line []byte, tp *timeParser) (LogEntry, *ParseError) {
+	// The timestamp is everything before the level bracket, as a layout may
+	// contain spaces.
+	tsStr, rest, found := bytes.Cut(line, []byte(" ["))
+	if !found || len(tsStr) == 0 {
 		return LogEntry{}, &ParseError{Line: string(line), Reason: ErrInvalidFormat}
 	}
+	rest = line[len(tsStr)+1:]
 
-	if len(rest) < 3 || rest[0] != '[' {
+	if len(rest) < 3 {
 		return LogEntry{}, &ParseError{Line: string(line), Reason: ErrInvalidFormat}
 	}
 
@@ -209,7 +482,7 @@ func parseDefault(line []byte) (LogEntry, *ParseError) {
 		msg = rest[closeBracket+2:]
 	}
 
-	timestamp, err := parseTimestamp(string(tsStr))
+	timestamp, err := tp.parse(string(tsStr))
 	if err != nil {
 		return LogEntry{}, &ParseError{Line: string(line), Reason: err}
 	}
@@ -221,34 +494,3 @@ func parseDefault(line []byte) (LogEntry, *ParseError) {
 		Raw:       line,
 	}, nil
 }
-
-func parseTimestamp(value string) (time.Time, error) {
-	switch len(value) {
-	case 10:
-		return time.Parse("2006-01-02", value)
-	case 19:
-		return time.Parse("2006-01-02 15:04:05", value)
-	case 20, 24, 25:
-		if ts, err := time.Parse(time.RFC3339, value); err == nil {
-			return ts, nil
-		}
-	}
-
-	for _, format := range timestampFormats {
-		if ts, err := time.Parse(format, value); err == nil {
-			return ts, nil
-		}
-	}
-	return time.Time{}, ErrInvalidFormat
-}
-
-func parseLevel(s string) LogLevel {
-	switch s {
-	case "DEBUG":
-		return LevelDebug
-	case "ERROR":
-		return LevelError
-	default:
-		return LevelInfo
-	}
-}
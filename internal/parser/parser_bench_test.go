@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"testing"
+
+	"github.com/kxrxh/logram/internal/input"
 )
 
 func BenchmarkParseTimestamp(b *testing.B) {
@@ -159,3 +163,123 @@ func BenchmarkParseLogStream_WithRules(b *testing.B) {
 		_, _ = p.ParseLogStream(ch)
 	}
 }
+
+func BenchmarkJSONFormat_Decode(b *testing.B) {
+	f := newJSONFormat(FormatConfig{}, defaultTimeParser)
+	line := []byte(`{"time":"2024-01-15T10:30:00Z","level":"info","msg":"request served",` +
+		`"method":"GET","path":"/api/users","status":200,"duration_ms":12.5}`)
+
+	for b.Loop() {
+		_, _ = f.Decode(line)
+	}
+}
+
+func BenchmarkLogfmtFormat_Decode(b *testing.B) {
+	cases := []struct {
+		name string
+		line string
+	}{
+		{"Plain", `ts=2024-01-15T10:30:00Z level=info msg=served method=GET path=/api/users status=200`},
+		{"Quoted", `ts=2024-01-15T10:30:00Z level=warn msg="slow query \"users\"" duration=1.2s`},
+	}
+
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			f := logfmtFormat{tp: defaultTimeParser}
+			line := []byte(tc.line)
+			for b.Loop() {
+				_, _ = f.Decode(line)
+			}
+		})
+	}
+}
+
+func BenchmarkParseLine_Formats(b *testing.B) {
+	cases := []struct {
+		format string
+		line   string
+	}{
+		{FormatDefault, "2024-01-15T10:30:00Z [INFO] request served"},
+		{FormatJSON, `{"time":"2024-01-15T10:30:00Z","level":"info","msg":"request served"}`},
+		{FormatLogfmt, `ts=2024-01-15T10:30:00Z level=info msg="request served"`},
+	}
+
+	p := NewParser(nil)
+	for _, tc := range cases {
+		f, err := NewFormat(FormatConfig{Type: tc.format})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(tc.format, func(b *testing.B) {
+			line := []byte(tc.line)
+			for b.Loop() {
+				_, _ = p.parse(line, f.Decode)
+			}
+		})
+	}
+}
+
+func BenchmarkParserStart_Workers(b *testing.B) {
+	rules := make([]RuleConfig, 0, 20)
+	for i := range 19 {
+		rules = append(rules, RuleConfig{
+			Name:    fmt.Sprintf("miss%d", i),
+			Pattern: fmt.Sprintf(`(?i)(timeout|refused|denied)\s+\w+\s+code=%d\d+`, i),
+		})
+	}
+	rules = append(rules, RuleConfig{Name: "match", Pattern: `(?i)user=\w+\s+.*(ok|done)$`})
+
+	const numLines = 1000
+	lines := make([]input.Line, numLines)
+	for i := range lines {
+		lines[i] = input.Line{
+			Source: fmt.Sprintf("src%d", i%4),
+			Data:   fmt.Appendf(nil, "2024-01-15T10:30:00Z [INFO] req %d user=alice done", i),
+		}
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			p, err := NewParserFromConfig(rules, WithWorkers(workers))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(lines[0].Data)) * numLines)
+			for b.Loop() {
+				in := make(chan input.Line, numLines)
+				for _, l := range lines {
+					in <- l
+				}
+				close(in)
+				for range p.Start(context.Background(), in) {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	patterns := make([]*regexp.Regexp, 200)
+	for i := range patterns {
+		patterns[i] = regexp.MustCompile(fmt.Sprintf(`(?i)service-%d\s+(timeout|refused|failed)`, i))
+	}
+	line := []byte("2024-01-15T10:30:00Z [ERROR] service-42 timeout after 30s")
+
+	b.Run("regexp", func(b *testing.B) {
+		for b.Loop() {
+			for _, re := range patterns {
+				_ = re.Match(line)
+			}
+		}
+	})
+
+	b.Run("matcher", func(b *testing.B) {
+		m := NewMatcher(patterns)
+		for b.Loop() {
+			matches := m.Scan(line)
+			for i := range patterns {
+				_ = matches.Match(i)
+			}
+		}
+	})
+}
@@ -2,10 +2,15 @@ package parser
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
 	"testing"
 	"time"
+
+	"github.com/kxrxh/logram/internal/input"
 )
 
 func TestParseLine_Default(t *testing.T) {
@@ -232,6 +237,128 @@ func TestParseLine_DefaultParser(t *testing.T) {
 	}
 }
 
+func TestParseLine_MultilineEntry(t *testing.T) {
+	p, cfgErr := NewParserFromConfig([]RuleConfig{
+		{Name: "npe", Pattern: `NullPointerException`},
+	})
+	if cfgErr != nil {
+		t.Fatalf("NewParserFromConfig() unexpected error: %v", cfgErr)
+	}
+
+	line := "2024-01-15T10:30:00Z [ERROR] request failed\n" +
+		"java.lang.NullPointerException: boom\n" +
+		"\tat com.example.Handler.handle(Handler.java:42)"
+
+	got, err := p.ParseLine([]byte(line))
+	if err != nil {
+		t.Fatalf("ParseLine() unexpected error: %v", err)
+	}
+	if got.RuleName != "npe" {
+		t.Errorf("ParseLine() rule = %q, want %q", got.RuleName, "npe")
+	}
+	wantMsg := "request failed\njava.lang.NullPointerException: boom\n" +
+		"\tat com.example.Handler.handle(Handler.java:42)"
+	if string(got.Message) != wantMsg {
+		t.Errorf("ParseLine() message = %q, want %q", got.Message, wantMsg)
+	}
+}
+
+func TestParseLine_PassthroughRule(t *testing.T) {
+	p, cfgErr := NewParserFromConfig([]RuleConfig{
+		{Name: "strict", Pattern: `runtime`},
+		{Name: "panics", Pattern: `panic`, Passthrough: true},
+	})
+	if cfgErr != nil {
+		t.Fatalf("NewParserFromConfig() unexpected error: %v", cfgErr)
+	}
+
+	before := time.Now()
+	got, err := p.ParseLine([]byte("panic: runtime error: index out of range"))
+	if err != nil {
+		t.Fatalf("ParseLine() unexpected error: %v", err)
+	}
+	if got.RuleName != "panics" || got.Level != LevelUnknown {
+		t.Errorf("ParseLine() rule = %q level = %v, want panics UNKNOWN", got.RuleName, got.Level)
+	}
+	if string(got.Message) != "panic: runtime error: index out of range" {
+		t.Errorf("ParseLine() message = %q, want the raw line", got.Message)
+	}
+	if got.Timestamp.Before(before) {
+		t.Errorf("ParseLine() timestamp = %v, want the read time", got.Timestamp)
+	}
+
+	// Without a passthrough rule the format error is kept
+	_, err = p.ParseLine([]byte("runtime: out of memory"))
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("ParseLine() error = %v, want %v", err, ErrInvalidFormat)
+	}
+}
+
+func TestParser_SetPassthroughRules(t *testing.T) {
+	p := NewParser(nil)
+	rules, err := CompileRules([]RuleConfig{{Name: "panics", Pattern: `^panic:`, Passthrough: true}})
+	if err != nil {
+		t.Fatalf("CompileRules() unexpected error: %v", err)
+	}
+	p.SetPassthroughRules(rules)
+
+	got, perr := p.ParseLine([]byte("panic: boom"))
+	if perr != nil {
+		t.Fatalf("ParseLine() unexpected error: %v", perr)
+	}
+	if got.RuleName != "panics" || got.Level != LevelUnknown {
+		t.Errorf("ParseLine() = %+v", got)
+	}
+
+	if _, perr := p.ParseLine([]byte("goroutine 1 [running]:")); perr == nil {
+		t.Error("ParseLine() expected lines matching no passthrough rule to fail")
+	}
+}
+
+func TestFormat_Passthrough(t *testing.T) {
+	f, err := NewFormat(FormatConfig{Type: FormatJSON, Passthrough: true})
+	if err != nil {
+		t.Fatalf("NewFormat() unexpected error: %v", err)
+	}
+
+	got, perr := f.Decode([]byte("Starting server on :8080"))
+	if perr != nil {
+		t.Fatalf("Decode() unexpected error: %v", perr)
+	}
+	if got.Level != LevelUnknown || string(got.Message) != "Starting server on :8080" {
+		t.Errorf("Decode() = %+v", got)
+	}
+}
+
+func TestParseInput_Record(t *testing.T) {
+	p, cfgErr := NewParserFromConfig([]RuleConfig{
+		{Name: "ssh", Pattern: `sshd`},
+	})
+	if cfgErr != nil {
+		t.Fatalf("NewParserFromConfig() unexpected error: %v", cfgErr)
+	}
+
+	ts := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	got, err := p.parseInput(input.Line{
+		Data: []byte("<34>Jan 15 10:30:00 web01 sshd[1234]: Failed password"),
+		Record: &input.Record{
+			Timestamp: ts,
+			Level:     "ERROR",
+			Message:   []byte("Failed password"),
+			Fields:    map[string]string{"hostname": "web01"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("parseInput() unexpected error: %v", err)
+	}
+	if got.RuleName != "ssh" || got.Level != LevelError || !got.Timestamp.Equal(ts) {
+		t.Errorf("parseInput() = %+v", got)
+	}
+	if string(got.Message) != "Failed password" || got.Fields["hostname"] != "web01" {
+		t.Errorf("parseInput() message = %q, fields = %v", got.Message, got.Fields)
+	}
+}
+
 func TestParseLogStream(t *testing.T) {
 	p := NewParser(nil)
 	ch := make(chan []byte, 3)
@@ -369,23 +496,94 @@ func TestParseLevel(t *testing.T) {
 		{"DEBUG", LevelDebug},
 		{"INFO", LevelInfo},
 		{"ERROR", LevelError},
-		{"debug", LevelInfo},
+		{"debug", LevelDebug},
 		{"info", LevelInfo},
-		{"error", LevelInfo},
+		{"error", LevelError},
+		{"trace", LevelTrace},
+		{"Warn", LevelWarn},
+		{"WARNING", LevelWarn},
+		{"ERR", LevelError},
+		{"CRIT", LevelCritical},
+		{"critical", LevelCritical},
+		{"FATAL", LevelFatal},
+		{"panic", LevelFatal},
+		{"notice", LevelInfo},
+		{"0", LevelFatal},
+		{"2", LevelCritical},
+		{"4", LevelWarn},
+		{"7", LevelDebug},
+		{"8", LevelInfo},
 		{"", LevelInfo},
 		{"UNKNOWN", LevelInfo},
+		{"A_VERY_LONG_LEVEL_NAME", LevelInfo},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
-			got := parseLevel(tt.input)
+			got := ParseLevel(tt.input)
 			if got != tt.expected {
-				t.Errorf("parseLevel(%q) = %v, want %v", tt.input, got, tt.expected)
+				t.Errorf("ParseLevel(%q) = %v, want %v", tt.input, got, tt.expected)
 			}
 		})
 	}
 }
 
+func TestLogLevel_AtLeast(t *testing.T) {
+	order := []LogLevel{
+		LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError, LevelCritical, LevelFatal,
+	}
+	for i, level := range order {
+		for j, minLevel := range order {
+			if got := level.AtLeast(minLevel); got != (i >= j) {
+				t.Errorf("%s.AtLeast(%s) = %v, want %v", level, minLevel, got, i >= j)
+			}
+		}
+		if !level.AtLeast("") {
+			t.Errorf("%s.AtLeast(\"\") = false, want true", level)
+		}
+	}
+}
+
+func TestParseLine_RuleMinLevel(t *testing.T) {
+	p, cfgErr := NewParserFromConfig([]RuleConfig{
+		{Name: "db", Pattern: `postgres`, MinLevel: "error"},
+		{Name: "warnings", MinLevel: "WARN"},
+	})
+	if cfgErr != nil {
+		t.Fatalf("NewParserFromConfig() unexpected error: %v", cfgErr)
+	}
+
+	tests := []struct {
+		line     string
+		wantRule string
+	}{
+		{"2024-01-15T10:30:00Z [ERROR] postgres down", "db"},
+		{"2024-01-15T10:30:00Z [WARN] postgres slow", "warnings"},
+		{"2024-01-15T10:30:00Z [FATAL] out of memory", "warnings"},
+		{"2024-01-15T10:30:00Z [INFO] postgres connected", ""},
+	}
+	for _, tt := range tests {
+		got, err := p.ParseLine([]byte(tt.line))
+		if tt.wantRule == "" {
+			if !errors.Is(err, ErrNoMatchRules) {
+				t.Errorf("ParseLine(%q) error = %v, want %v", tt.line, err, ErrNoMatchRules)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ParseLine(%q) unexpected error: %v", tt.line, err)
+		}
+		if got.RuleName != tt.wantRule {
+			t.Errorf("ParseLine(%q) rule = %q, want %q", tt.line, got.RuleName, tt.wantRule)
+		}
+	}
+
+	_, err := NewParserFromConfig([]RuleConfig{{Name: "bad", MinLevel: "LOUD"}})
+	if !errors.Is(err, ErrUnknownLevel) {
+		t.Errorf("NewParserFromConfig() error = %v, want %v", err, ErrUnknownLevel)
+	}
+}
+
 func TestParseTimestamp(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -486,3 +684,46 @@ func errorsIs(err, target error) bool {
 	}
 	return err.Error() == target.Error()
 }
+
+func TestParser_Start_WorkersKeepOrder(t *testing.T) {
+	p := NewParser(nil, WithWorkers(4))
+
+	const n = 1000
+	lines := make(chan input.Line)
+	sourceOf := func(i int) string {
+		if i%3 == 0 {
+			return "b"
+		}
+		return "a"
+	}
+	go func() {
+		defer close(lines)
+		for i := range n {
+			data := fmt.Appendf(nil, "2024-01-15T10:30:00Z [INFO] %d", i)
+			if i%10 == 0 {
+				data = []byte("garbage")
+			}
+			lines <- input.Line{Source: sourceOf(i), Data: data}
+		}
+	}()
+
+	next := 0
+	for entry := range p.Start(context.Background(), lines) {
+		for next%10 == 0 {
+			next++
+		}
+		if want := strconv.Itoa(next); string(entry.Message) != want {
+			t.Fatalf("expected message %s, got %s", want, entry.Message)
+		}
+		if entry.Source != sourceOf(next) {
+			t.Fatalf("message %d: expected source %s, got %s", next, sourceOf(next), entry.Source)
+		}
+		next++
+	}
+	if next != n {
+		t.Errorf("expected all %d lines, stopped at %d", n, next)
+	}
+	if stats := p.DeadLetters().Stats(); stats.Rejected != n/10 {
+		t.Errorf("expected %d rejected lines, got %d", n/10, stats.Rejected)
+	}
+}
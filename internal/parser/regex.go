@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	groupTime    = "ts"
+	groupLevel   = "level"
+	groupMessage = "msg"
+)
+
+// regexFormat decodes lines with a user pattern. The ts, level and msg
+// groups fill the entry; other named groups become fields.
+type regexFormat struct {
+	re *regexp.Regexp
+	tp *timeParser
+
+	timeIdx  int
+	levelIdx int
+	msgIdx   int
+}
+
+func newRegexFormat(cfg FormatConfig, tp *timeParser) (*regexFormat, error) {
+	re, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRegex, err)
+	}
+
+	if cfg.TimeLayout != "" {
+		withLayout := *tp
+		withLayout.layouts = append([]string{cfg.TimeLayout}, tp.layouts...)
+		tp = &withLayout
+	}
+
+	f := &regexFormat{
+		re:       re,
+		tp:       tp,
+		timeIdx:  re.SubexpIndex(groupTime),
+		levelIdx: re.SubexpIndex(groupLevel),
+		msgIdx:   re.SubexpIndex(groupMessage),
+	}
+	if f.timeIdx == -1 {
+		return nil, fmt.Errorf("%w: pattern has no (?P<%s>...) group", ErrInvalidRegex, groupTime)
+	}
+	return f, nil
+}
+
+func (f *regexFormat) Decode(line []byte) (LogEntry, *ParseError) {
+	m := f.re.FindSubmatchIndex(line)
+	if m == nil {
+		return LogEntry{}, &ParseError{Line: string(line), Reason: ErrInvalidFormat}
+	}
+	group := func(i int) []byte {
+		if i < 0 || m[2*i] < 0 {
+			return nil
+		}
+		return line[m[2*i]:m[2*i+1]]
+	}
+
+	timestamp, err := f.tp.parse(string(group(f.timeIdx)))
+	if err != nil {
+		return LogEntry{}, &ParseError{Line: string(line), Reason: err}
+	}
+
+	entry := LogEntry{
+		Timestamp: timestamp,
+		Level:     LevelInfo,
+		Message:   group(f.msgIdx),
+		Raw:       line,
+	}
+	if level := group(f.levelIdx); level != nil {
+		entry.Level = parseLevelBytes(level)
+	}
+
+	for i, name := range f.re.SubexpNames() {
+		if name == "" || i == f.timeIdx || i == f.levelIdx || i == f.msgIdx {
+			continue
+		}
+		if entry.Fields == nil {
+			entry.Fields = make(map[string]string)
+		}
+		entry.Fields[name] = string(group(i))
+	}
+	return entry, nil
+}
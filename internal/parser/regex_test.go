@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegexFormat_Decode(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       FormatConfig
+		line      string
+		wantTime  time.Time
+		wantLevel LogLevel
+		wantMsg   string
+		wantField map[string]string
+	}{
+		{
+			name: "nginx access",
+			cfg: FormatConfig{
+				Pattern: `^(?P<remote>\S+) - \S+ \[(?P<ts>[^\]]+)\] ` +
+					`"(?P<msg>[^"]*)" (?P<status>\d{3})`,
+				TimeLayout: "02/Jan/2006:15:04:05 -0700",
+			},
+			line:      `10.0.0.1 - - [15/Jan/2024:10:30:00 +0000] "GET /health HTTP/1.1" 200 2`,
+			wantTime:  time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+			wantLevel: LevelInfo,
+			wantMsg:   "GET /health HTTP/1.1",
+			wantField: map[string]string{"remote": "10.0.0.1", "status": "200"},
+		},
+		{
+			name: "postgres",
+			cfg: FormatConfig{
+				Pattern:    `^(?P<ts>\S+ \S+) UTC \[(?P<pid>\d+)\] (?P<level>\w+):\s+(?P<msg>.*)$`,
+				TimeLayout: "2006-01-02 15:04:05.000",
+			},
+			line:      `2024-01-15 10:30:00.123 UTC [4711] ERROR:  relation "users" does not exist`,
+			wantTime:  time.Date(2024, 1, 15, 10, 30, 0, 123e6, time.UTC),
+			wantLevel: LevelError,
+			wantMsg:   `relation "users" does not exist`,
+			wantField: map[string]string{"pid": "4711"},
+		},
+		{
+			name:      "default time layouts",
+			cfg:       FormatConfig{Pattern: `^(?P<ts>\S+) (?P<level>\w+) (?P<msg>.*)$`},
+			line:      "2024-01-15T10:30:00Z debug legacy java line",
+			wantTime:  time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+			wantLevel: LevelDebug,
+			wantMsg:   "legacy java line",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Type = FormatRegex
+			f, err := NewFormat(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewFormat() unexpected error: %v", err)
+			}
+
+			got, perr := f.Decode([]byte(tt.line))
+			if perr != nil {
+				t.Fatalf("Decode() unexpected error: %v", perr)
+			}
+			if !got.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Decode() timestamp = %v, want %v", got.Timestamp, tt.wantTime)
+			}
+			if got.Level != tt.wantLevel {
+				t.Errorf("Decode() level = %v, want %v", got.Level, tt.wantLevel)
+			}
+			if string(got.Message) != tt.wantMsg {
+				t.Errorf("Decode() message = %q, want %q", got.Message, tt.wantMsg)
+			}
+			if len(got.Fields) != len(tt.wantField) {
+				t.Errorf("Decode() fields = %v, want %v", got.Fields, tt.wantField)
+			}
+			for k, v := range tt.wantField {
+				if got.Fields[k] != v {
+					t.Errorf("Decode() field %q = %q, want %q", k, got.Fields[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestRegexFormat_NoMatch(t *testing.T) {
+	f, err := NewFormat(FormatConfig{
+		Type:       FormatRegex,
+		Pattern:    `^\[(?P<ts>[^\]]+)\] (?P<msg>.*)$`,
+		TimeLayout: time.DateTime,
+	})
+	if err != nil {
+		t.Fatalf("NewFormat() unexpected error: %v", err)
+	}
+
+	for _, line := range []string{"no brackets", "[not a time] msg"} {
+		if _, perr := f.Decode([]byte(line)); !errors.Is(perr, ErrInvalidFormat) {
+			t.Errorf("Decode(%q) error = %v, want %v", line, perr, ErrInvalidFormat)
+		}
+	}
+}
+
+func TestNewFormat_InvalidRegex(t *testing.T) {
+	for _, pattern := range []string{`(?P<ts>`, `^(?P<msg>.*)$`} {
+		_, err := NewFormat(FormatConfig{Type: FormatRegex, Pattern: pattern})
+		if !errors.Is(err, ErrInvalidRegex) {
+			t.Errorf("NewFormat(%q) error = %v, want %v", pattern, err, ErrInvalidRegex)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Epoch layouts, usable in TimeConfig.Layouts next to Go time layouts.
+const (
+	LayoutUnix      = "unix"
+	LayoutUnixMilli = "unix_ms"
+	LayoutUnixMicro = "unix_us"
+	LayoutUnixNano  = "unix_ns"
+)
+
+// TimeConfig controls how a format reads timestamps.
+type TimeConfig struct {
+	// Layouts are tried in order before the default layouts.
+	Layouts []string
+	// Timezone is the IANA zone of timestamps without an offset; empty
+	// means UTC.
+	Timezone string
+	// ReadTimeFallback stamps lines with the time they are parsed at
+	// instead of dropping them when their timestamp can't be parsed, and
+	// passes through lines that can't be parsed at all.
+	ReadTimeFallback bool
+}
+
+type timeParser struct {
+	layouts  []string
+	loc      *time.Location
+	fallback bool
+	now      func() time.Time
+}
+
+var defaultTimeParser = &timeParser{loc: time.UTC, now: time.Now}
+
+func newTimeParser(cfg TimeConfig) (*timeParser, error) {
+	loc := time.UTC
+	if cfg.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("load timezone %q: %w", cfg.Timezone, err)
+		}
+	}
+	return &timeParser{
+		layouts:  cfg.Layouts,
+		loc:      loc,
+		fallback: cfg.ReadTimeFallback,
+		now:      time.Now,
+	}, nil
+}
+
+// parse tries the configured layouts, then the default ones. With the
+// fallback on, a value that fits none gets the current time.
+func (p *timeParser) parse(value string) (time.Time, error) {
+	for _, layout := range p.layouts {
+		if ts, err := parseLayout(layout, value, p.loc); err == nil {
+			return ts, nil
+		}
+	}
+	if ts, err := parseTimestampIn(value, p.loc); err == nil {
+		return ts, nil
+	}
+	return p.unparsed()
+}
+
+// unparsed returns the time for a line whose timestamp is missing or fits
+// no layout.
+func (p *timeParser) unparsed() (time.Time, error) {
+	if p.fallback {
+		return p.now(), nil
+	}
+	return time.Time{}, ErrInvalidTimestamp
+}
+
+func parseLayout(layout, value string, loc *time.Location) (time.Time, error) {
+	switch layout {
+	case LayoutUnix:
+		return parseEpoch(value, time.Second)
+	case LayoutUnixMilli:
+		return parseEpoch(value, time.Millisecond)
+	case LayoutUnixMicro:
+		return parseEpoch(value, time.Microsecond)
+	case LayoutUnixNano:
+		return parseEpoch(value, time.Nanosecond)
+	default:
+		return time.ParseInLocation(layout, value, loc)
+	}
+}
+
+// parseEpoch parses a possibly fractional count of units since 1970.
+func parseEpoch(value string, unit time.Duration) (time.Time, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+			return time.Time{}, ErrInvalidFormat
+		}
+		return time.Unix(0, n*int64(unit)).UTC(), nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.Abs(f) > float64(math.MaxInt64/int64(unit)) {
+		return time.Time{}, ErrInvalidFormat
+	}
+	whole, frac := math.Modf(f)
+	return time.Unix(0, int64(whole)*int64(unit)+int64(frac*float64(unit))).UTC(), nil
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	return parseTimestampIn(value, time.UTC)
+}
+
+// parseTimestampIn tries the default layouts, reading timestamps without an
+// offset in loc.
+func parseTimestampIn(value string, loc *time.Location) (time.Time, error) {
+	switch len(value) {
+	case 10:
+		return time.ParseInLocation("2006-01-02", value, loc)
+	case 19:
+		return time.ParseInLocation("2006-01-02 15:04:05", value, loc)
+	case 20, 24, 25:
+		if ts, err := time.Parse(time.RFC3339, value); err == nil {
+			return ts, nil
+		}
+	}
+
+	for _, format := range timestampFormats {
+		if ts, err := time.ParseInLocation(format, value, loc); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, ErrInvalidFormat
+}
@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeParser_Layouts(t *testing.T) {
+	tp, err := newTimeParser(TimeConfig{
+		Layouts:  []string{"02/01/2006 15:04:05", LayoutUnixMilli},
+		Timezone: "Europe/Moscow",
+	})
+	if err != nil {
+		t.Fatalf("newTimeParser() unexpected error: %v", err)
+	}
+	msk := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"15/01/2024 13:30:00", time.Date(2024, 1, 15, 13, 30, 0, 0, msk)},
+		{"1705314600250", time.Date(2024, 1, 15, 10, 30, 0, 25e7, time.UTC)},
+		// Default layouts still apply, zone-less ones in the configured zone
+		{"2024-01-15 13:30:00", time.Date(2024, 1, 15, 13, 30, 0, 0, msk)},
+		{"2024-01-15T10:30:00Z", time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := tp.parse(tt.value)
+		if err != nil {
+			t.Errorf("parse(%q) unexpected error: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parse(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	if _, err := tp.parse("yesterday"); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("parse() error = %v, want %v", err, ErrInvalidFormat)
+	}
+}
+
+func TestParseEpoch(t *testing.T) {
+	tests := []struct {
+		value string
+		unit  time.Duration
+		want  time.Time
+	}{
+		{"1705314600", time.Second, time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)},
+		{"1705314600.5", time.Second, time.Date(2024, 1, 15, 10, 30, 0, 5e8, time.UTC)},
+		{"1705314600123", time.Millisecond, time.Date(2024, 1, 15, 10, 30, 0, 123e6, time.UTC)},
+		{"1705314600000001", time.Microsecond, time.Date(2024, 1, 15, 10, 30, 0, 1e3, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseEpoch(tt.value, tt.unit)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseEpoch(%q, %v) = %v, %v, want %v", tt.value, tt.unit, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "NaN", "99999999999999999999"} {
+		if _, err := parseEpoch(value, time.Second); err == nil {
+			t.Errorf("parseEpoch(%q) expected error", value)
+		}
+	}
+}
+
+func TestNewFormat_InvalidTimezone(t *testing.T) {
+	if _, err := NewFormat(FormatConfig{Time: TimeConfig{Timezone: "Mars/Olympus"}}); err == nil {
+		t.Error("NewFormat() expected error for unknown timezone")
+	}
+}
+
+func TestFormat_ReadTimeFallback(t *testing.T) {
+	readAt := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		cfg       FormatConfig
+		line      string
+		wantLevel LogLevel
+		wantMsg   string
+	}{
+		{
+			name:      "default format, odd timestamp",
+			line:      "15.01.2024-10:30 [ERROR] disk full",
+			wantLevel: LevelError,
+			wantMsg:   "disk full",
+		},
+		{
+			name:      "default format, no structure",
+			line:      "Segmentation fault (core dumped)",
+			wantLevel: LevelUnknown,
+			wantMsg:   "Segmentation fault (core dumped)",
+		},
+		{
+			name:      "json without time",
+			cfg:       FormatConfig{Type: FormatJSON},
+			line:      `{"level":"warn","msg":"no time"}`,
+			wantLevel: LevelWarn,
+			wantMsg:   "no time",
+		},
+		{
+			name:      "logfmt without time",
+			cfg:       FormatConfig{Type: FormatLogfmt},
+			line:      `level=error msg="no time"`,
+			wantLevel: LevelError,
+			wantMsg:   "no time",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Time.ReadTimeFallback = true
+			f, err := NewFormat(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewFormat() unexpected error: %v", err)
+			}
+			setNow(f, func() time.Time { return readAt })
+
+			got, perr := f.Decode([]byte(tt.line))
+			if perr != nil {
+				t.Fatalf("Decode() unexpected error: %v", perr)
+			}
+			if !got.Timestamp.Equal(readAt) {
+				t.Errorf("Decode() timestamp = %v, want read time %v", got.Timestamp, readAt)
+			}
+			if got.Level != tt.wantLevel || string(got.Message) != tt.wantMsg {
+				t.Errorf("Decode() = %s %q, want %s %q", got.Level, got.Message, tt.wantLevel, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestDefaultFormat_LayoutWithSpace(t *testing.T) {
+	f, err := NewFormat(FormatConfig{
+		Type: FormatDefault,
+		Time: TimeConfig{Layouts: []string{"2006-01-02 15:04:05.000"}},
+	})
+	if err != nil {
+		t.Fatalf("NewFormat() unexpected error: %v", err)
+	}
+
+	got, perr := f.Decode([]byte("2024-01-02 10:11:12.345 [ERROR] boom [retry 2]"))
+	if perr != nil {
+		t.Fatalf("Decode() unexpected error: %v", perr)
+	}
+	if want := time.Date(2024, 1, 2, 10, 11, 12, 345e6, time.UTC); !got.Timestamp.Equal(want) {
+		t.Errorf("Decode() timestamp = %v, want %v", got.Timestamp, want)
+	}
+	if got.Level != LevelError || string(got.Message) != "boom [retry 2]" {
+		t.Errorf("Decode() = %s %q, want ERROR %q", got.Level, got.Message, "boom [retry 2]")
+	}
+}
+
+func TestJSONFormat_EpochMillis(t *testing.T) {
+	f, err := NewFormat(FormatConfig{
+		Type: FormatJSON,
+		Time: TimeConfig{Layouts: []string{LayoutUnixMilli}},
+	})
+	if err != nil {
+		t.Fatalf("NewFormat() unexpected error: %v", err)
+	}
+
+	got, perr := f.Decode([]byte(`{"time":1705314600250,"msg":"pino"}`))
+	if perr != nil {
+		t.Fatalf("Decode() unexpected error: %v", perr)
+	}
+	if want := time.Date(2024, 1, 15, 10, 30, 0, 25e7, time.UTC); !got.Timestamp.Equal(want) {
+		t.Errorf("Decode() timestamp = %v, want %v", got.Timestamp, want)
+	}
+}
+
+// setNow replaces the clock of a format built by NewFormat.
+func setNow(f Format, now func() time.Time) {
+	if fb, ok := f.(passthroughFormat); ok {
+		fb.tp.now = now
+		return
+	}
+	switch f := f.(type) {
+	case defaultFormat:
+		f.tp.now = now
+	case *jsonFormat:
+		f.tp.now = now
+	case logfmtFormat:
+		f.tp.now = now
+	case *regexFormat:
+		f.tp.now = now
+	}
+}
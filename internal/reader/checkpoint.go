@@ -0,0 +1,136 @@
+package reader
+
+import (
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// headSize is how many bytes from the start of a file are hashed to tell
+	// whether it is still the same file after a restart.
+	headSize = 1024
+
+	checkpointInterval = time.Second
+)
+
+// Checkpoint is the durable position of a tailed file.
+type Checkpoint struct {
+	Path     string
+	Device   uint64
+	Inode    uint64
+	Offset   int64
+	HeadLen  int64
+	HeadHash string
+}
+
+// CheckpointStore persists checkpoints between restarts.
+type CheckpointStore interface {
+	LoadCheckpoint(path string) (Checkpoint, bool, error)
+	SaveCheckpoint(cp Checkpoint) error
+}
+
+// startOffset decides where to start reading the file: at the stored
+// checkpoint if the file is still the same one, at its beginning if it was
+// replaced, and at its end if there is nothing to resume from.
+func (t *fileTailer) startOffset() int64 {
+	stat, err := os.Stat(t.path)
+	if err != nil {
+		return 0
+	}
+	if t.store == nil {
+		return stat.Size()
+	}
+
+	cp, found, err := t.store.LoadCheckpoint(t.path)
+	if err != nil {
+		log.Printf("load checkpoint for %s: %v", t.path, err)
+		return stat.Size()
+	}
+	if !found {
+		return stat.Size()
+	}
+	t.saved = cp
+
+	if t.sameFile(cp, stat) {
+		return cp.Offset
+	}
+
+	log.Printf("file %s was replaced since the last checkpoint, reading from start", t.path)
+	return 0
+}
+
+func (t *fileTailer) sameFile(cp Checkpoint, stat os.FileInfo) bool {
+	dev, ino := fileIdentity(stat)
+	if dev != cp.Device || ino != cp.Inode {
+		return false
+	}
+	if stat.Size() < cp.Offset {
+		return false
+	}
+
+	file, err := os.Open(t.path)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("close file: %v", err)
+		}
+	}()
+
+	headLen, hash := hashHead(file, cp.HeadLen)
+	return headLen == cp.HeadLen && hash == cp.HeadHash
+}
+
+// trackFile records the identity and head hash of the file being read.
+func (t *fileTailer) trackFile(file io.ReaderAt, stat os.FileInfo) {
+	dev, ino := fileIdentity(stat)
+	if dev == t.dev && ino == t.ino && t.headLen >= min(stat.Size(), headSize) {
+		return
+	}
+	t.dev, t.ino = dev, ino
+	t.headLen, t.headHash = hashHead(file, min(stat.Size(), headSize))
+}
+
+func (t *fileTailer) saveCheckpoint() {
+	if t.store == nil {
+		return
+	}
+
+	cp := Checkpoint{
+		Path:     t.path,
+		Device:   t.dev,
+		Inode:    t.ino,
+		Offset:   t.offset - int64(len(t.partial)),
+		HeadLen:  t.headLen,
+		HeadHash: t.headHash,
+	}
+	if cp == t.saved {
+		return
+	}
+
+	if err := t.store.SaveCheckpoint(cp); err != nil {
+		log.Printf("save checkpoint for %s: %v", t.path, err)
+		return
+	}
+	t.saved = cp
+}
+
+// hashHead hashes the first n bytes of r. It returns how many bytes were
+// actually hashed, which is less than n when the file is shorter.
+func hashHead(r io.ReaderAt, n int64) (int64, string) {
+	head := make([]byte, n)
+	read, err := r.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("read file head: %v", err)
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(head[:read])
+	return int64(read), hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,172 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{checkpoints: make(map[string]Checkpoint)}
+}
+
+func (s *memoryStore) LoadCheckpoint(path string) (Checkpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.checkpoints[path]
+	return cp, ok, nil
+}
+
+func (s *memoryStore) SaveCheckpoint(cp Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[cp.Path] = cp
+	return nil
+}
+
+// runUntilStopped tails path until the returned stop func is called, which
+// waits for the final checkpoint to be saved.
+func runUntilStopped(
+	t *testing.T,
+	path string,
+	store CheckpointStore,
+) (<-chan []byte, func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	ch := ReadFileTail(ctx, path, WithCheckpointStore(store))
+	time.Sleep(waitInterval)
+
+	return ch, func() {
+		cancel()
+		for range ch {
+		}
+	}
+}
+
+func TestReadFileTail_ResumeFromCheckpoint(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "resume.log")
+	_ = os.WriteFile(tmpPath, []byte("before start\n"), 0o600)
+
+	store := newMemoryStore()
+
+	ch, stop := runUntilStopped(t, tmpPath, store)
+	appendString(t, tmpPath, "seen\npart")
+	lines := collectWithTimeout(ch, 1, time.Second)
+	if len(lines) != 1 || lines[0] != "seen" {
+		t.Fatalf("expected [seen], got %v", lines)
+	}
+	stop()
+
+	// Written while the reader is down
+	appendString(t, tmpPath, "ial\nmissed\n")
+
+	ch, stop = runUntilStopped(t, tmpPath, store)
+	defer stop()
+
+	lines = collectWithTimeout(ch, 2, time.Second)
+	if len(lines) != 2 || lines[0] != "partial" || lines[1] != "missed" {
+		t.Fatalf("expected [partial missed], got %v", lines)
+	}
+}
+
+func TestReadFileTail_CheckpointFileReplaced(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "replaced.log")
+	_ = os.WriteFile(tmpPath, []byte("old file line\n"), 0o600)
+
+	store := newMemoryStore()
+
+	_, stop := runUntilStopped(t, tmpPath, store)
+	stop()
+
+	// Replace the file with a new one that is longer than the old offset
+	_ = os.Remove(tmpPath)
+	_ = os.WriteFile(tmpPath, []byte("new file line 1\nnew file line 2\n"), 0o600)
+
+	ch, stop := runUntilStopped(t, tmpPath, store)
+	defer stop()
+
+	lines := collectWithTimeout(ch, 2, time.Second)
+	if len(lines) != 2 || lines[0] != "new file line 1" || lines[1] != "new file line 2" {
+		t.Fatalf("expected the whole new file, got %v", lines)
+	}
+}
+
+func TestReadFileTail_NoCheckpointSkipsExisting(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "fresh.log")
+	_ = os.WriteFile(tmpPath, []byte("existing\n"), 0o600)
+
+	ch, stop := runUntilStopped(t, tmpPath, newMemoryStore())
+	defer stop()
+
+	appendString(t, tmpPath, "appended\n")
+
+	lines := collectWithTimeout(ch, 1, time.Second)
+	if len(lines) != 1 || lines[0] != "appended" {
+		t.Fatalf("expected [appended], got %v", lines)
+	}
+}
+
+func TestReadSources_CheckpointSkipsOnlyEmittedLines(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "backlog.log")
+	_ = os.WriteFile(tmpPath, nil, 0o600)
+
+	store := newMemoryStore()
+	sources := []Source{{Name: "app", Glob: tmpPath}}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	lines := ReadSources(ctx, sources, WithCheckpointStore(store))
+	time.Sleep(waitInterval)
+
+	// More lines than the output holds, so the reader is still emitting
+	// when it is stopped
+	var data strings.Builder
+	for i := range 1000 {
+		fmt.Fprintf(&data, "line %04d\n", i)
+	}
+	appendString(t, tmpPath, data.String())
+
+	var got []string
+	for line := range lines {
+		got = append(got, string(line.Data))
+		if len(got) == 10 {
+			cancel()
+			time.Sleep(waitInterval)
+		}
+	}
+	if len(got) == 1000 {
+		t.Fatal("expected the reader to stop before emitting every line")
+	}
+
+	ctx, cancel = context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+	lines = ReadSources(ctx, sources, WithCheckpointStore(store))
+	for line := range lines {
+		got = append(got, string(line.Data))
+		if len(got) == 1000 {
+			cancel()
+		}
+	}
+	if len(got) != 1000 {
+		t.Fatalf("expected 1000 lines over both runs, got %d", len(got))
+	}
+	for i, line := range got {
+		if want := fmt.Sprintf("line %04d", i); line != want {
+			t.Fatalf("line %d after restart: got %q, want %q", i, line, want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package reader
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode numbers of the file, which stay
+// the same while a file is renamed and change when it is replaced.
+func fileIdentity(info os.FileInfo) (dev, ino uint64) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return uint64(st.Dev), st.Ino //nolint:unconvert,gosec // Dev is signed on some platforms
+}
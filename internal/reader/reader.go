@@ -1,180 +1,258 @@
 package reader
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"time"
 
-	"github.com/fsnotify/fsnotify"
+	"github.com/kxrxh/logram/internal/input"
 )
 
-const signatureSize = 3
+const (
+	readChunkSize = 64 * 1024
+
+	// Lines longer than this are emitted in pieces instead of growing the
+	// partial-line buffer without bound.
+	maxLineSize = 1024 * 1024
+)
+
+// ReadFileTail tails a single file and returns the lines appended to it.
+func ReadFileTail(ctx context.Context, path string, opts ...Option) <-chan []byte {
+	lines := ReadSources(ctx, []Source{{Glob: path}}, opts...)
 
-func ReadFileTail(ctx context.Context, path string) <-chan []byte {
 	out := make(chan []byte, 200)
-	t := &fileTailer{
-		path:    path,
-		out:     out,
-		sigSize: signatureSize,
-	}
-	go t.run(ctx)
+	go func() {
+		defer close(out)
+		for line := range lines {
+			select {
+			case out <- line.Data:
+			case <-ctx.Done():
+			}
+		}
+	}()
 	return out
 }
 
 type fileTailer struct {
-	path      string
-	out       chan<- []byte
-	signature []string
-	sigSize   int
-	lastSize  int64
-}
+	path   string
+	source *Source
+	out    chan<- input.Line
 
-func (t *fileTailer) run(ctx context.Context) {
-	defer close(t.out)
+	// offset is the position up to which the file has been read. Bytes of an
+	// unterminated trailing line are counted in offset and kept in partial
+	// until their newline arrives. Lines that couldn't be emitted because the
+	// tailer is stopping are not counted, so they are read again on restart.
+	offset  int64
+	partial []byte
+	buf     []byte
+	file    *os.File
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return
-	}
-	defer func() {
-		if err := watcher.Close(); err != nil {
-			log.Printf("close watcher: %v", err)
-		}
-	}()
+	// Identity of the file being read, kept for checkpoints.
+	dev      uint64
+	ino      uint64
+	headLen  int64
+	headHash string
 
-	// Watch the directory, not the file, to handle missing files
-	dir := filepath.Dir(t.path)
-	if err := watcher.Add(dir); err != nil {
-		return
-	}
+	store CheckpointStore
+	saved Checkpoint
 
-	// Initial check if file exists
-	if stat, err := os.Stat(t.path); err == nil {
-		t.lastSize = stat.Size()
-		lines := t.readAllLines()
-		t.updateSignature(lines)
-	}
+	// goneSince is when the path was removed or renamed away.
+	goneSince time.Time
+}
 
-	for {
-		select {
-		case <-ctx.Done():
+func (t *fileTailer) processUpdate(ctx context.Context) {
+	if t.file == nil {
+		if !t.open() {
 			return
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			// Only process events related to our specific file
-			if filepath.Clean(event.Name) == filepath.Clean(t.path) {
-				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
-					t.processUpdate(ctx)
-				}
-			}
-		case <-watcher.Errors:
+		}
+	} else if t.rotated() {
+		// Finish the rotated file before switching to the new one
+		t.drain(ctx)
+		t.closeFile()
+		t.offset = 0
+		if !t.open() {
 			return
 		}
 	}
-}
 
-func (t *fileTailer) processUpdate(ctx context.Context) {
-	stat, err := os.Stat(t.path)
+	stat, err := t.file.Stat()
 	if err != nil {
 		return
 	}
 
-	// File was truncated or rotated
-	if stat.Size() < t.lastSize {
-		t.signature = nil
-		t.lastSize = stat.Size()
-		lines := t.readAllLines()
-		if len(lines) > 0 {
-			t.emit(ctx, []byte(lines[len(lines)-1]))
-			t.updateSignature(lines)
-		}
-		return
+	if t.truncated(stat) {
+		t.offset = 0
+		t.partial = t.partial[:0]
+		t.headLen = 0
 	}
-
-	lines := t.readAllLines()
-	if len(lines) == 0 {
+	t.trackFile(t.file, stat)
+	if stat.Size() == t.offset {
 		return
 	}
 
-	t.lastSize = stat.Size()
+	t.readRest(ctx)
+}
 
-	if len(t.signature) == 0 {
-		t.emit(ctx, []byte(lines[len(lines)-1]))
-		t.updateSignature(lines)
+func (t *fileTailer) open() bool {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return false
+	}
+	t.file = file
+	t.partial = t.partial[:0]
+	t.headLen = 0
+	return true
+}
+
+func (t *fileTailer) closeFile() {
+	if t.file == nil {
 		return
 	}
+	if err := t.file.Close(); err != nil {
+		log.Printf("close file: %v", err)
+	}
+	t.file = nil
+}
 
-	idx := t.findLastReadIndex(lines)
-	if idx != -1 {
-		for i := idx + 1; i < len(lines); i++ {
-			if !t.emit(ctx, []byte(lines[i])) {
-				return
-			}
-		}
-	} else {
-		t.emit(ctx, []byte(lines[len(lines)-1]))
+// rotated reports whether the path now points to a different file than the
+// open one. A missing path is not a rotation yet: the old file keeps being
+// read until the new one is created.
+func (t *fileTailer) rotated() bool {
+	pathStat, err := os.Stat(t.path)
+	if err != nil {
+		return false
 	}
-	t.updateSignature(lines)
+	fileStat, err := t.file.Stat()
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(pathStat, fileStat)
 }
 
-func (t *fileTailer) emit(ctx context.Context, data []byte) bool {
-	select {
-	case t.out <- data:
+// truncated reports whether the open file was truncated since the last read,
+// even if it has already grown past the old offset again (copytruncate).
+func (t *fileTailer) truncated(stat os.FileInfo) bool {
+	if stat.Size() < t.offset {
 		return true
-	case <-ctx.Done():
+	}
+	if t.headLen == 0 {
 		return false
 	}
+	headLen, hash := hashHead(t.file, t.headLen)
+	return headLen != t.headLen || hash != t.headHash
 }
 
-func (t *fileTailer) readAllLines() []string {
-	file, err := os.Open(t.path)
-	if err != nil {
-		return nil
-	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			log.Printf("close file: %v", err)
+// drain reads the rest of a rotated file. Its last line will not get a
+// newline anymore, so it is emitted as is.
+func (t *fileTailer) drain(ctx context.Context) {
+	t.readRest(ctx)
+	if len(t.partial) > 0 {
+		line := t.partial
+		t.partial = nil
+		if !t.emit(ctx, line) {
+			t.offset -= int64(len(line))
 		}
-	}()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
 	}
-	return lines
 }
 
-func (t *fileTailer) updateSignature(lines []string) {
-	if len(lines) == 0 {
-		t.signature = nil
+// readRest reads the open file from the current offset to its end.
+func (t *fileTailer) readRest(ctx context.Context) {
+	if _, err := t.file.Seek(t.offset, io.SeekStart); err != nil {
+		log.Printf("seek %s: %v", t.path, err)
 		return
 	}
-	start := max(len(lines)-t.sigSize, 0)
-	t.signature = append([]string(nil), lines[start:]...)
+	t.readAppended(ctx, t.file)
 }
 
-func (t *fileTailer) findLastReadIndex(lines []string) int {
-	if len(t.signature) == 0 {
-		return -1
+// readAppended reads r until EOF and emits every complete line in it.
+func (t *fileTailer) readAppended(ctx context.Context, r io.Reader) {
+	for {
+		n, err := r.Read(t.buf)
+		if n > 0 {
+			t.offset += int64(n)
+			if !t.consume(ctx, t.buf[:n]) {
+				return
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("read %s: %v", t.path, err)
+			}
+			return
+		}
 	}
-	for i := len(lines) - len(t.signature); i >= 0; i-- {
-		match := true
-		for j := range t.signature {
-			if lines[i+j] != t.signature[j] {
-				match = false
-				break
+}
+
+// consume splits data into lines, joining the first one with the pending
+// partial line and keeping the unterminated tail for the next read.
+func (t *fileTailer) consume(ctx context.Context, data []byte) bool {
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx == -1 {
+			break
+		}
+
+		if len(t.partial)+idx > maxLineSize {
+			t.partial = append(t.partial, data[:idx]...)
+			data = data[idx:]
+			if !t.cutPartial(ctx) {
+				t.offset -= int64(len(data))
+				return false
 			}
+			if len(t.partial) == 0 {
+				data = data[1:]
+			}
+			continue
 		}
-		if match {
-			return i + len(t.signature) - 1
+
+		var line []byte
+		if len(t.partial) > 0 {
+			line = append(t.partial, data[:idx]...)
+			t.partial = nil
+		} else {
+			line = append([]byte(nil), data[:idx]...)
 		}
+		data = data[idx+1:]
+
+		if !t.emit(ctx, bytes.TrimSuffix(line, []byte("\r"))) {
+			t.offset -= int64(len(line) + 1 + len(data))
+			return false
+		}
+	}
+
+	t.partial = append(t.partial, data...)
+	return t.cutPartial(ctx)
+}
+
+// cutPartial emits the pending partial line in maxLineSize pieces until
+// less than maxLineSize bytes remain.
+func (t *fileTailer) cutPartial(ctx context.Context) bool {
+	for len(t.partial) >= maxLineSize {
+		line := t.partial[:maxLineSize:maxLineSize]
+		t.partial = append([]byte(nil), t.partial[maxLineSize:]...)
+		if !t.emit(ctx, line) {
+			t.offset -= int64(len(line))
+			return false
+		}
+	}
+	return true
+}
+
+func (t *fileTailer) emit(ctx context.Context, data []byte) bool {
+	line := input.Line{
+		Source: t.source.Name,
+		File:   t.path,
+		Labels: t.source.Labels,
+		Data:   data,
+	}
+	select {
+	case t.out <- line:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-	return -1
 }
@@ -1,110 +1,82 @@
 package reader
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
-)
 
-func BenchmarkReadAllLines_100Lines(b *testing.B) {
-	tmpDir := b.TempDir()
-	tmpPath := filepath.Join(tmpDir, "bench.log")
-
-	lines := make([]string, 100)
-	for i := range 100 {
-		lines[i] = fmt.Sprintf("log line %d with some data", i)
-	}
-	content := []byte(joinLines(lines))
-	_ = os.WriteFile(tmpPath, content, 0o600)
+	"github.com/kxrxh/logram/internal/input"
+)
 
-	t := &fileTailer{
-		path:    tmpPath,
-		sigSize: signatureSize,
-	}
+const appendedLines = 10
 
-	b.ResetTimer()
-	for b.Loop() {
-		_ = t.readAllLines()
-	}
+func BenchmarkProcessUpdate_100Lines(b *testing.B) {
+	benchmarkProcessUpdate(b, 100)
 }
 
-func BenchmarkReadAllLines_10000Lines(b *testing.B) {
-	tmpDir := b.TempDir()
-	tmpPath := filepath.Join(tmpDir, "bench.log")
-
-	lines := make([]string, 10000)
-	for i := range 10000 {
-		lines[i] = fmt.Sprintf("log line %d with some data", i)
-	}
-	content := []byte(joinLines(lines))
-	_ = os.WriteFile(tmpPath, content, 0o600)
-
-	t := &fileTailer{
-		path:    tmpPath,
-		sigSize: signatureSize,
-	}
+func BenchmarkProcessUpdate_10000Lines(b *testing.B) {
+	benchmarkProcessUpdate(b, 10000)
+}
 
-	b.ResetTimer()
-	for b.Loop() {
-		_ = t.readAllLines()
-	}
+func BenchmarkProcessUpdate_100000Lines(b *testing.B) {
+	benchmarkProcessUpdate(b, 100000)
 }
 
-func BenchmarkReadAllLines_100000Lines(b *testing.B) {
+// benchmarkProcessUpdate measures reading the last appendedLines lines of a
+// file with numLines lines. The cost should not depend on numLines.
+func benchmarkProcessUpdate(b *testing.B, numLines int) {
 	tmpDir := b.TempDir()
 	tmpPath := filepath.Join(tmpDir, "bench.log")
 
-	lines := make([]string, 100000)
-	for i := range 100000 {
+	lines := make([]string, numLines)
+	for i := range numLines {
 		lines[i] = fmt.Sprintf("log line %d with some data", i)
 	}
-	content := []byte(joinLines(lines))
-	_ = os.WriteFile(tmpPath, content, 0o600)
+	content := joinLines(lines) + "\n"
+	_ = os.WriteFile(tmpPath, []byte(content), 0o600)
 
-	t := &fileTailer{
-		path:    tmpPath,
-		sigSize: signatureSize,
-	}
-
-	b.ResetTimer()
-	for b.Loop() {
-		_ = t.readAllLines()
-	}
-}
-
-func BenchmarkFindLastReadIndex_100Lines(b *testing.B) {
-	lines := make([]string, 100)
-	for i := range 100 {
-		lines[i] = fmt.Sprintf("log line %d with some data", i)
-	}
+	tail := joinLines(lines[numLines-appendedLines:]) + "\n"
+	start := int64(len(content) - len(tail))
 
+	out := make(chan input.Line, appendedLines)
 	t := &fileTailer{
-		signature: lines[97:],
-		sigSize:   signatureSize,
+		path:   tmpPath,
+		source: &Source{Name: "bench"},
+		out:    out,
+		buf:    make([]byte, readChunkSize),
 	}
+	ctx := context.Background()
 
 	b.ResetTimer()
 	for b.Loop() {
-		_ = t.findLastReadIndex(lines)
+		t.offset = start
+		t.processUpdate(ctx)
+		for range appendedLines {
+			<-out
+		}
 	}
 }
 
-func BenchmarkFindLastReadIndex_10000Lines(b *testing.B) {
-	lines := make([]string, 10000)
-	for i := range 10000 {
+func BenchmarkConsume(b *testing.B) {
+	lines := make([]string, 1000)
+	for i := range 1000 {
 		lines[i] = fmt.Sprintf("log line %d with some data", i)
 	}
+	data := []byte(joinLines(lines) + "\n")
 
-	t := &fileTailer{
-		signature: lines[9997:],
-		sigSize:   signatureSize,
-	}
+	out := make(chan input.Line, len(lines))
+	t := &fileTailer{source: &Source{Name: "bench"}, out: out}
+	ctx := context.Background()
 
 	b.ResetTimer()
 	for b.Loop() {
-		_ = t.findLastReadIndex(lines)
+		t.consume(ctx, data)
+		for range len(lines) {
+			<-out
+		}
 	}
 }
 
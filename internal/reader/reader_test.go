@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -59,19 +60,17 @@ func TestReadFileTail_BasicAppend(t *testing.T) {
 	}
 }
 
-func TestReadFileTail_MainGoOverwriteFile(t *testing.T) {
+func TestReadFileTail_Truncate(t *testing.T) {
 	tmpDir := t.TempDir()
 	tmpPath := filepath.Join(tmpDir, "test.log")
 
-	initial := "line1\nline2\nline3\n"
-	_ = os.WriteFile(tmpPath, []byte(initial), 0o600)
+	_ = os.WriteFile(tmpPath, []byte("line1\nline2\nline3\n"), 0o600)
 
 	ctx := t.Context()
 	ch := ReadFileTail(ctx, tmpPath)
 	time.Sleep(waitInterval)
 
-	updated := "line2\nline3\nline4\n"
-	_ = os.WriteFile(tmpPath, []byte(updated), 0o600)
+	_ = os.WriteFile(tmpPath, []byte("line4\n"), 0o600)
 
 	lines := collectWithTimeout(ch, 1, 1*time.Second)
 
@@ -83,6 +82,54 @@ func TestReadFileTail_MainGoOverwriteFile(t *testing.T) {
 	}
 }
 
+func TestReadFileTail_PartialLine(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "partial.log")
+
+	_ = os.WriteFile(tmpPath, []byte("old\n"), 0o600)
+
+	ctx := t.Context()
+	ch := ReadFileTail(ctx, tmpPath)
+	time.Sleep(waitInterval)
+
+	appendString(t, tmpPath, "first ha")
+	time.Sleep(waitInterval)
+	appendString(t, tmpPath, "lf\r\nsecond\n")
+
+	lines := collectWithTimeout(ch, 2, 1*time.Second)
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %v", lines)
+	}
+	if lines[0] != "first half" || lines[1] != "second" {
+		t.Errorf("unexpected lines: %q", lines)
+	}
+}
+
+func TestReadFileTail_RepeatedLines(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "repeat.log")
+
+	_ = os.WriteFile(tmpPath, []byte("A\nB\nC\n"), 0o600)
+
+	ctx := t.Context()
+	ch := ReadFileTail(ctx, tmpPath)
+	time.Sleep(waitInterval)
+
+	appendString(t, tmpPath, "A\nB\nC\nA\nB\nC\n")
+
+	lines := collectWithTimeout(ch, 6, 1*time.Second)
+
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 lines, got %v", lines)
+	}
+	for i, want := range []string{"A", "B", "C", "A", "B", "C"} {
+		if lines[i] != want {
+			t.Errorf("line %d: expected %q, got %q", i, want, lines[i])
+		}
+	}
+}
+
 func TestReadFileTail_NonExistentFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	tmpPath := filepath.Join(tmpDir, "ghost.log")
@@ -98,6 +145,35 @@ func TestReadFileTail_NonExistentFile(t *testing.T) {
 	t.Logf("Found: %v", lines)
 }
 
+func TestReadFileTail_LongLineCut(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "long.log")
+	_ = os.WriteFile(tmpPath, nil, 0o600)
+
+	ctx := t.Context()
+	ch := ReadFileTail(ctx, tmpPath)
+	time.Sleep(waitInterval)
+
+	long := strings.Repeat("a", 2*maxLineSize+maxLineSize/2)
+	exact := strings.Repeat("b", 2*maxLineSize)
+	appendString(t, tmpPath, long+"\n"+exact+"\nnext\n")
+
+	lines := collectWithTimeout(ch, 6, 1*time.Second)
+
+	want := []string{
+		long[:maxLineSize], long[:maxLineSize], long[:maxLineSize/2],
+		exact[:maxLineSize], exact[:maxLineSize], "next",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d", len(want), len(lines))
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: expected %d bytes, got %d", i, len(want[i]), len(lines[i]))
+		}
+	}
+}
+
 func TestReadFileTail_ContextCancel(t *testing.T) {
 	tmpDir := t.TempDir()
 	tmpPath := filepath.Join(tmpDir, "cancel.log")
@@ -119,23 +195,18 @@ func TestReadFileTail_ContextCancel(t *testing.T) {
 	}
 }
 
-func TestReadFileTail_SignatureRecovery(t *testing.T) {
-	tmpDir := t.TempDir()
-	tmpPath := filepath.Join(tmpDir, "recovery.log")
-
-	_ = os.WriteFile(tmpPath, []byte("A\nB\nC\n"), 0o600)
-
-	ctx := t.Context()
-	ch := ReadFileTail(ctx, tmpPath)
-	time.Sleep(waitInterval)
-
-	_ = os.WriteFile(tmpPath, []byte("X\nY\nZ\n"), 0o600)
+func appendString(t *testing.T, path, data string) {
+	t.Helper()
 
-	lines := collectWithTimeout(ch, 1, 1*time.Second)
-
-	if len(lines) == 0 {
-		t.Error("expected to recover and read at least the latest line")
-	} else if lines[len(lines)-1] != "Z" {
-		t.Errorf("expected latest line 'Z', got %q", lines[len(lines)-1])
+	// #nosec G304 // test-only file created via t.TempDir()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
 	}
 }
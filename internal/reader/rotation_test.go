@@ -0,0 +1,102 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadFileTail_RenameRotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "app.log")
+	rotatedPath := filepath.Join(tmpDir, "app.log.1")
+
+	_ = os.WriteFile(tmpPath, []byte("old\n"), 0o600)
+
+	ctx := t.Context()
+	ch := ReadFileTail(ctx, tmpPath)
+	time.Sleep(waitInterval)
+
+	appendString(t, tmpPath, "before rotation\n")
+	time.Sleep(waitInterval)
+
+	// logrotate renames the file, the app keeps writing to its open handle
+	// until it reopens the log, which creates a new file.
+	if err := os.Rename(tmpPath, rotatedPath); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+	appendString(t, rotatedPath, "late write 1\nlate write 2")
+	time.Sleep(waitInterval)
+	_ = os.WriteFile(tmpPath, []byte("new file\n"), 0o600)
+
+	lines := collectWithTimeout(ch, 4, time.Second)
+
+	want := []string{"before rotation", "late write 1", "late write 2", "new file"}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %v, got %v", want, lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: expected %q, got %q", i, want[i], lines[i])
+		}
+	}
+}
+
+func TestReadFileTail_CopyTruncate(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "app.log")
+	rotatedPath := filepath.Join(tmpDir, "app.log.1")
+
+	_ = os.WriteFile(tmpPath, []byte("old\n"), 0o600)
+
+	ctx := t.Context()
+	ch := ReadFileTail(ctx, tmpPath)
+	time.Sleep(waitInterval)
+
+	appendString(t, tmpPath, "a\nb\n")
+	lines := collectWithTimeout(ch, 2, time.Second)
+	if len(lines) != 2 || lines[0] != "a" || lines[1] != "b" {
+		t.Fatalf("expected [a b], got %v", lines)
+	}
+
+	// logrotate copies the file aside and truncates it in place. The app
+	// writes more than the old size before the reader notices.
+	// #nosec G304 // test-only file created via t.TempDir()
+	content, err := os.ReadFile(tmpPath)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	_ = os.WriteFile(rotatedPath, content, 0o600)
+	if err := os.Truncate(tmpPath, 0); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	appendString(t, tmpPath, "after truncate 1\nafter truncate 2\n")
+
+	lines = collectWithTimeout(ch, 2, time.Second)
+	if len(lines) != 2 || lines[0] != "after truncate 1" || lines[1] != "after truncate 2" {
+		t.Fatalf("expected both lines written after truncation, got %v", lines)
+	}
+}
+
+func TestReadFileTail_CopyTruncateShorter(t *testing.T) {
+	tmpDir := t.TempDir()
+	tmpPath := filepath.Join(tmpDir, "app.log")
+
+	_ = os.WriteFile(tmpPath, []byte("a fairly long line that was there before\n"), 0o600)
+
+	ctx := t.Context()
+	ch := ReadFileTail(ctx, tmpPath)
+	time.Sleep(waitInterval)
+
+	if err := os.Truncate(tmpPath, 0); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	time.Sleep(waitInterval)
+	appendString(t, tmpPath, "x\ny\n")
+
+	lines := collectWithTimeout(ch, 2, time.Second)
+	if len(lines) != 2 || lines[0] != "x" || lines[1] != "y" {
+		t.Fatalf("expected [x y], got %v", lines)
+	}
+}
@@ -0,0 +1,317 @@
+package reader
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kxrxh/logram/internal/input"
+)
+
+// goneGrace is how long a removed or renamed file keeps being read, for
+// writers that still have it open, before its tailer is closed.
+const goneGrace = 30 * time.Second
+
+// Source is a set of files matched by a glob and tailed under one name.
+// Files created later are picked up, also in directories created later that
+// a wildcard in the directory part matches.
+type Source struct {
+	Name   string
+	Glob   string
+	Labels map[string]string
+}
+
+type Option func(*sourceTailer)
+
+// WithCheckpointStore makes the tailer resume from the stored position on
+// start and save its position while running.
+func WithCheckpointStore(store CheckpointStore) Option {
+	return func(s *sourceTailer) { s.store = store }
+}
+
+// ReadSources tails every file matching the sources' globs, including files
+// created after the start, and returns their new lines.
+func ReadSources(ctx context.Context, sources []Source, opts ...Option) <-chan input.Line {
+	out := make(chan input.Line, 200)
+	s := &sourceTailer{
+		sources:   append([]Source(nil), sources...),
+		out:       out,
+		tailers:   make(map[string]*fileTailer),
+		buf:       make([]byte, readChunkSize),
+		goneGrace: goneGrace,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	go s.run(ctx)
+	return out
+}
+
+type sourceTailer struct {
+	sources []Source
+	out     chan<- input.Line
+	tailers map[string]*fileTailer
+	store   CheckpointStore
+	watcher *fsnotify.Watcher
+
+	// buf is shared by all file tailers, which run on one goroutine.
+	buf []byte
+
+	goneGrace time.Duration
+	now       func() time.Time
+}
+
+func (s *sourceTailer) run(ctx context.Context) {
+	defer close(s.out)
+	defer s.saveCheckpoints()
+	defer s.closeFiles()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("create watcher: %v", err)
+		return
+	}
+	defer func() {
+		if err := watcher.Close(); err != nil {
+			log.Printf("close watcher: %v", err)
+		}
+	}()
+	s.watcher = watcher
+
+	// Watch directories, not files, to handle missing and new files
+	for _, src := range s.sources {
+		for _, dir := range watchDirs(src.Glob) {
+			if err := watcher.Add(dir); err != nil {
+				log.Printf("watch %s (source %q): %v", dir, src.Name, err)
+			}
+		}
+	}
+
+	// Catch up on lines written since the last checkpoint, if any
+	for i := range s.sources {
+		matches, err := filepath.Glob(s.sources[i].Glob)
+		if err != nil {
+			log.Printf("match %s (source %q): %v", s.sources[i].Glob, s.sources[i].Name, err)
+			continue
+		}
+		for _, path := range matches {
+			s.add(ctx, &s.sources[i], path, true)
+		}
+	}
+
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reapGone(ctx)
+			s.saveCheckpoints()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			s.handleEvent(ctx, event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("watcher error: %v", err)
+		}
+	}
+}
+
+func (s *sourceTailer) handleEvent(ctx context.Context, event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+	if t, exists := s.tailers[path]; exists {
+		t.processUpdate(ctx)
+		if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+			s.markGone(t)
+		}
+		return
+	}
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			s.watchNewDirs(ctx)
+			return
+		}
+	}
+	src := s.match(path)
+	if src == nil {
+		return
+	}
+	// A file renamed to a name the glob also matches, such as app.log.1, is
+	// not new: its tailer follows it instead of reading it again.
+	if t := s.renamedTo(path); t != nil {
+		delete(s.tailers, t.path)
+		t.path = path
+		t.source = src
+		t.goneSince = time.Time{}
+		s.tailers[path] = t
+		t.processUpdate(ctx)
+		return
+	}
+	s.add(ctx, src, path, false)
+}
+
+// markGone starts the grace period of a tailer whose path was removed or
+// renamed, unless it already points to a new file.
+func (s *sourceTailer) markGone(t *fileTailer) {
+	if _, err := os.Stat(t.path); err == nil {
+		return
+	}
+	if t.goneSince.IsZero() {
+		t.goneSince = s.now()
+	}
+}
+
+// renamedTo returns the gone tailer whose open file is now at path.
+func (s *sourceTailer) renamedTo(path string) *fileTailer {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	for _, t := range s.tailers {
+		if t.goneSince.IsZero() || t.file == nil {
+			continue
+		}
+		if fileStat, err := t.file.Stat(); err == nil && os.SameFile(stat, fileStat) {
+			return t
+		}
+	}
+	return nil
+}
+
+// reapGone closes the tailers whose path has been gone for the grace period
+// without being created again, after reading what is left in their file.
+func (s *sourceTailer) reapGone(ctx context.Context) {
+	now := s.now()
+	for path, t := range s.tailers {
+		if t.goneSince.IsZero() || now.Sub(t.goneSince) < s.goneGrace {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			t.goneSince = time.Time{}
+			t.processUpdate(ctx)
+			continue
+		}
+		if t.file != nil {
+			t.drain(ctx)
+		}
+		t.closeFile()
+		delete(s.tailers, path)
+	}
+}
+
+// add starts tailing a file. Files present at startup continue from their
+// checkpoint, or from their end; files created later are read from the start.
+func (s *sourceTailer) add(ctx context.Context, src *Source, path string, atStartup bool) {
+	path = filepath.Clean(path)
+	if _, exists := s.tailers[path]; exists {
+		return
+	}
+
+	t := &fileTailer{
+		path:   path,
+		source: src,
+		out:    s.out,
+		buf:    s.buf,
+		store:  s.store,
+	}
+	if atStartup {
+		t.offset = t.startOffset()
+	}
+	s.tailers[path] = t
+	t.processUpdate(ctx)
+}
+
+// watchNewDirs watches the directories matching the sources' globs that were
+// created since the start, and tails the files already in them.
+func (s *sourceTailer) watchNewDirs(ctx context.Context) {
+	if s.watcher == nil {
+		return
+	}
+	watched := make(map[string]bool)
+	for _, dir := range s.watcher.WatchList() {
+		watched[dir] = true
+	}
+
+	for i := range s.sources {
+		src := &s.sources[i]
+		added := false
+		for _, dir := range watchDirs(src.Glob) {
+			if watched[dir] {
+				continue
+			}
+			if err := s.watcher.Add(dir); err != nil {
+				log.Printf("watch %s (source %q): %v", dir, src.Name, err)
+				continue
+			}
+			watched[dir] = true
+			added = true
+		}
+		if !added {
+			continue
+		}
+
+		// Files may have been written before the directory was watched
+		matches, err := filepath.Glob(src.Glob)
+		if err != nil {
+			log.Printf("match %s (source %q): %v", src.Glob, src.Name, err)
+			continue
+		}
+		for _, path := range matches {
+			s.add(ctx, src, path, false)
+		}
+	}
+}
+
+func (s *sourceTailer) match(path string) *Source {
+	for i := range s.sources {
+		if ok, _ := filepath.Match(filepath.Clean(s.sources[i].Glob), path); ok {
+			return &s.sources[i]
+		}
+	}
+	return nil
+}
+
+func (s *sourceTailer) saveCheckpoints() {
+	for _, t := range s.tailers {
+		t.saveCheckpoint()
+	}
+}
+
+func (s *sourceTailer) closeFiles() {
+	for _, t := range s.tailers {
+		t.closeFile()
+	}
+}
+
+// watchDirs returns the directories a glob can match files in and, for
+// wildcards in its directory part, the directories where matching ones can
+// be created.
+func watchDirs(glob string) []string {
+	var dirs []string
+	for dir := filepath.Dir(glob); ; dir = filepath.Dir(dir) {
+		if !strings.ContainsAny(dir, "*?[") {
+			return append(dirs, dir)
+		}
+
+		matches, err := filepath.Glob(dir)
+		if err != nil {
+			log.Printf("match directories %s: %v", dir, err)
+			return dirs
+		}
+		dirs = append(dirs, matches...)
+	}
+}
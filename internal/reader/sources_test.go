@@ -0,0 +1,229 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/kxrxh/logram/internal/input"
+)
+
+func collectLinesWithTimeout(ch <-chan input.Line, count int) []input.Line {
+	var lines []input.Line
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for len(lines) < count {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				return lines
+			}
+			lines = append(lines, line)
+		case <-ctx.Done():
+			return lines
+		}
+	}
+	return lines
+}
+
+func TestReadSources_GlobAndLabels(t *testing.T) {
+	tmpDir := t.TempDir()
+	apiPath := filepath.Join(tmpDir, "api.log")
+	workerPath := filepath.Join(tmpDir, "worker.log")
+	_ = os.WriteFile(apiPath, []byte("api old\n"), 0o600)
+	_ = os.WriteFile(filepath.Join(tmpDir, "ignored.txt"), []byte("old\n"), 0o600)
+
+	ctx := t.Context()
+	ch := ReadSources(ctx, []Source{{
+		Name:   "app",
+		Glob:   filepath.Join(tmpDir, "*.log"),
+		Labels: map[string]string{"env": "prod"},
+	}})
+	time.Sleep(waitInterval)
+
+	appendString(t, apiPath, "api new\n")
+	appendString(t, filepath.Join(tmpDir, "ignored.txt"), "ignored\n")
+	// Created after the start, read from its beginning
+	_ = os.WriteFile(workerPath, []byte("worker first\n"), 0o600)
+
+	lines := collectLinesWithTimeout(ch, 2)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", lines)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].File < lines[j].File })
+
+	if lines[0].File != apiPath || string(lines[0].Data) != "api new" {
+		t.Errorf("unexpected api line: %+v", lines[0])
+	}
+	if lines[1].File != workerPath || string(lines[1].Data) != "worker first" {
+		t.Errorf("unexpected worker line: %+v", lines[1])
+	}
+	for _, line := range lines {
+		if line.Source != "app" || line.Labels["env"] != "prod" {
+			t.Errorf("expected source app with env=prod, got %+v", line)
+		}
+	}
+}
+
+func TestReadSources_MultipleSources(t *testing.T) {
+	tmpDir := t.TempDir()
+	nginxPath := filepath.Join(tmpDir, "nginx.log")
+	dbPath := filepath.Join(tmpDir, "db.log")
+	_ = os.WriteFile(nginxPath, nil, 0o600)
+	_ = os.WriteFile(dbPath, nil, 0o600)
+
+	ctx := t.Context()
+	ch := ReadSources(ctx, []Source{
+		{Name: "nginx", Glob: nginxPath},
+		{Name: "db", Glob: dbPath},
+	})
+	time.Sleep(waitInterval)
+
+	appendString(t, nginxPath, "GET /\n")
+	appendString(t, dbPath, "checkpoint\n")
+
+	lines := collectLinesWithTimeout(ch, 2)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", lines)
+	}
+	for _, line := range lines {
+		switch line.Source {
+		case "nginx":
+			if string(line.Data) != "GET /" {
+				t.Errorf("unexpected nginx line: %q", line.Data)
+			}
+		case "db":
+			if string(line.Data) != "checkpoint" {
+				t.Errorf("unexpected db line: %q", line.Data)
+			}
+		default:
+			t.Errorf("unexpected source: %+v", line)
+		}
+	}
+}
+
+func TestReadSources_DirectoryCreatedLater(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldPath := filepath.Join(tmpDir, "svc1", "app", "app.log")
+	newPath := filepath.Join(tmpDir, "svc2", "app", "app.log")
+	if err := os.MkdirAll(filepath.Dir(oldPath), 0o750); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	_ = os.WriteFile(oldPath, []byte("old\n"), 0o600)
+
+	ctx := t.Context()
+	ch := ReadSources(ctx, []Source{{
+		Name: "app",
+		Glob: filepath.Join(tmpDir, "*", "app", "*.log"),
+	}})
+	time.Sleep(waitInterval)
+
+	appendString(t, oldPath, "svc1 new\n")
+	// The directory matching the wildcard appears after the start
+	if err := os.MkdirAll(filepath.Dir(newPath), 0o750); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	_ = os.WriteFile(newPath, []byte("svc2 first\n"), 0o600)
+	time.Sleep(waitInterval)
+	appendString(t, newPath, "svc2 second\n")
+
+	lines := collectLinesWithTimeout(ch, 3)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %+v", lines)
+	}
+	var got []string
+	for _, line := range lines {
+		got = append(got, string(line.Data))
+	}
+	sort.Strings(got)
+	if got[0] != "svc1 new" || got[1] != "svc2 first" || got[2] != "svc2 second" {
+		t.Errorf("unexpected lines: %q", got)
+	}
+}
+
+func newTestSourceTailer(sources []Source) (*sourceTailer, chan input.Line) {
+	out := make(chan input.Line, 100)
+	return &sourceTailer{
+		sources:   sources,
+		out:       out,
+		tailers:   make(map[string]*fileTailer),
+		buf:       make([]byte, readChunkSize),
+		goneGrace: time.Minute,
+		now:       time.Now,
+	}, out
+}
+
+func TestSourceTailer_ClosesRemovedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "2026-01-02.log")
+	_ = os.WriteFile(path, nil, 0o600)
+
+	ctx := t.Context()
+	s, out := newTestSourceTailer([]Source{{Name: "app", Glob: filepath.Join(tmpDir, "*.log")}})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Create})
+	tailer := s.tailers[path]
+	if tailer == nil || tailer.file == nil {
+		t.Fatalf("expected an open tailer for %s", path)
+	}
+	file := tailer.file
+
+	appendString(t, path, "last words")
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	s.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+	s.reapGone(ctx)
+	if len(s.tailers) != 1 {
+		t.Fatalf("expected the tailer to wait out the grace period, got %d", len(s.tailers))
+	}
+
+	now = now.Add(time.Minute)
+	s.reapGone(ctx)
+	if len(s.tailers) != 0 {
+		t.Fatalf("expected no tailers, got %d", len(s.tailers))
+	}
+	if _, err := file.Stat(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected the file to be closed, got %v", err)
+	}
+	if lines := collectLinesWithTimeout(out, 1); len(lines) != 1 ||
+		string(lines[0].Data) != "last words" {
+		t.Errorf("expected the unterminated last line, got %+v", lines)
+	}
+}
+
+func TestSourceTailer_FollowsRenameToMatchingName(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "app.log")
+	rotatedPath := filepath.Join(tmpDir, "app.log.1")
+	_ = os.WriteFile(path, nil, 0o600)
+
+	ctx := t.Context()
+	s, out := newTestSourceTailer([]Source{{Name: "app", Glob: filepath.Join(tmpDir, "app.log*")}})
+	s.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Create})
+	appendString(t, path, "before\n")
+	s.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Write})
+
+	if err := os.Rename(path, rotatedPath); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+	s.handleEvent(ctx, fsnotify.Event{Name: path, Op: fsnotify.Rename})
+	appendString(t, rotatedPath, "after\n")
+	s.handleEvent(ctx, fsnotify.Event{Name: rotatedPath, Op: fsnotify.Create})
+
+	if len(s.tailers) != 1 || s.tailers[rotatedPath] == nil {
+		t.Fatalf("expected the tailer to follow the file, got %v", s.tailers)
+	}
+	lines := collectLinesWithTimeout(out, 3)
+	if len(lines) != 2 || string(lines[0].Data) != "before" || string(lines[1].Data) != "after" {
+		t.Errorf("expected each line once, got %+v", lines)
+	}
+}
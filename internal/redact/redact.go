@@ -0,0 +1,231 @@
+// Package redact masks secrets and personal data in log entries before they
+// are sent anywhere.
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+// Built-in detectors, enabled by name in Config.Builtin.
+const (
+	DetectorBearer   = "bearer"
+	DetectorPassword = "password"
+	DetectorEmail    = "email"
+	DetectorCard     = "card"
+)
+
+const defaultReplacement = "[REDACTED]"
+
+var (
+	ErrUnknownDetector = errors.New("unknown redaction detector")
+	ErrInvalidPattern  = errors.New("invalid redaction pattern")
+)
+
+// Config selects what to redact.
+type Config struct {
+	Builtin []string
+	Rules   []RuleConfig
+}
+
+// RuleConfig replaces matches of Pattern with Replacement, which may refer to
+// groups as in regexp.Expand. An empty replacement is "[REDACTED]".
+type RuleConfig struct {
+	Pattern     string
+	Replacement string
+}
+
+type rule struct {
+	re          *regexp.Regexp
+	replacement []byte
+	// valid, when set, filters matches, e.g. by checksum; the replacement is
+	// then taken literally.
+	valid func([]byte) bool
+}
+
+var builtins = map[string][]rule{
+	DetectorBearer: {{
+		re:          regexp.MustCompile(`(?i)(\bbearer\s+)[a-z0-9\-._~+/]+=*`),
+		replacement: []byte("${1}" + defaultReplacement),
+	}},
+	DetectorPassword: {
+		{
+			// user:password@ in URLs and DSNs
+			re:          regexp.MustCompile(`(?i)(\b[a-z][a-z0-9+.\-]*://[^:/\s@]*:)[^@\s]+@`),
+			replacement: []byte("${1}" + defaultReplacement + "@"),
+		},
+		{
+			re: regexp.MustCompile(
+				`(?i)(\b(?:password|passwd|pwd|secret|api_?key|access_?token)"?\s*[=:]\s*)` +
+					`("[^"]*"|[^\s&,;"]+)`),
+			replacement: []byte("${1}" + defaultReplacement),
+		},
+	},
+	DetectorEmail: {{
+		re:          regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`),
+		replacement: []byte("[EMAIL]"),
+	}},
+	DetectorCard: {{
+		re:          regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		replacement: []byte("[CARD]"),
+		valid:       luhn,
+	}},
+}
+
+// builtinOrder keeps passwords ahead of emails, so "user:pass@host.com" is
+// not taken for an address.
+var builtinOrder = []string{DetectorBearer, DetectorPassword, DetectorEmail, DetectorCard}
+
+// Redactor masks entries with the rules of their source, or the default ones.
+// It is safe for concurrent use.
+type Redactor struct {
+	mu           sync.RWMutex
+	defaultRules []rule
+	sources      map[string][]rule
+}
+
+// New builds a redactor; sources overrides the default config per source
+// name.
+func New(defaultCfg Config, sources map[string]Config) (*Redactor, error) {
+	r := &Redactor{}
+	if err := r.Update(defaultCfg, sources); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Update replaces the redactor's configs, keeping the old ones on error.
+func (r *Redactor) Update(defaultCfg Config, sources map[string]Config) error {
+	defaultRules, err := compile(defaultCfg)
+	if err != nil {
+		return err
+	}
+	bySource := make(map[string][]rule, len(sources))
+	for name, cfg := range sources {
+		if bySource[name], err = compile(cfg); err != nil {
+			return fmt.Errorf("source %q: %w", name, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultRules = defaultRules
+	r.sources = bySource
+	return nil
+}
+
+func compile(cfg Config) ([]rule, error) {
+	enabled := make(map[string]bool, len(cfg.Builtin))
+	for _, name := range cfg.Builtin {
+		if _, ok := builtins[name]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownDetector, name)
+		}
+		enabled[name] = true
+	}
+
+	var rules []rule
+	for _, name := range builtinOrder {
+		if enabled[name] {
+			rules = append(rules, builtins[name]...)
+		}
+	}
+	for _, rc := range cfg.Rules {
+		re, err := regexp.Compile(rc.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPattern, err)
+		}
+		replacement := rc.Replacement
+		if replacement == "" {
+			replacement = defaultReplacement
+		}
+		rules = append(rules, rule{re: re, replacement: []byte(replacement)})
+	}
+	return rules, nil
+}
+
+func (r *Redactor) rulesFor(source string) []rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rules, ok := r.sources[source]; ok {
+		return rules
+	}
+	return r.defaultRules
+}
+
+// Entry returns the entry with its message, raw line and field values
+// redacted. A nil Redactor returns the entry as is.
+func (r *Redactor) Entry(entry parser.LogEntry) parser.LogEntry {
+	if r == nil {
+		return entry
+	}
+	rules := r.rulesFor(entry.Source)
+	if len(rules) == 0 {
+		return entry
+	}
+
+	entry.Message = apply(rules, entry.Message)
+	entry.Raw = apply(rules, entry.Raw)
+	if len(entry.Fields) > 0 {
+		fields := make(map[string]string, len(entry.Fields))
+		for k, v := range entry.Fields {
+			fields[k] = string(apply(rules, []byte(v)))
+		}
+		entry.Fields = fields
+	}
+	return entry
+}
+
+// Line redacts a raw line from source.
+func (r *Redactor) Line(source string, line []byte) []byte {
+	if r == nil {
+		return line
+	}
+	return apply(r.rulesFor(source), line)
+}
+
+func apply(rules []rule, b []byte) []byte {
+	for _, rl := range rules {
+		if !rl.re.Match(b) {
+			continue
+		}
+		if rl.valid == nil {
+			b = rl.re.ReplaceAll(b, rl.replacement)
+			continue
+		}
+		b = rl.re.ReplaceAllFunc(b, func(m []byte) []byte {
+			if rl.valid(m) {
+				return rl.replacement
+			}
+			return m
+		})
+	}
+	return b
+}
+
+// luhn checks a card number's check digit, ignoring separators.
+func luhn(b []byte) bool {
+	digits := bytes.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, b)
+
+	sum := 0
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,56 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	// 2026-01-02 is a Friday.
+	from := time.Date(2026, 1, 2, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"hourly", time.Date(2026, 1, 2, 11, 0, 0, 0, time.UTC)},
+		{"daily", time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"weekly", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 2, 10, 30, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2026, 1, 5, 9, 30, 0, 0, time.UTC)},
+		{"0 9,18 * * *", time.Date(2026, 1, 2, 18, 0, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)},
+		// both day fields set: either one matches
+		{"0 0 15 * 1", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.spec, err)
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestSchedule_NextIsAfter(t *testing.T) {
+	s, err := Parse("0 * * * *")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	at := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	if got, want := s.Next(at), at.Add(time.Hour); !got.Equal(want) {
+		t.Fatalf("Next(%v) = %v, want %v", at, got, want)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "often", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := Parse(spec); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalid", spec, err)
+		}
+	}
+}
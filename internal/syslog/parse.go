@@ -0,0 +1,251 @@
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+var (
+	ErrInvalidPriority = errors.New("invalid syslog priority")
+	ErrInvalidHeader   = errors.New("invalid syslog header")
+)
+
+// Message is a parsed syslog message, RFC 3164 or RFC 5424.
+type Message struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+	// StructuredData holds RFC 5424 SD params keyed as "<sd-id>.<param>".
+	StructuredData map[string]string
+	Message        []byte
+}
+
+// Level maps the syslog severity onto a log level.
+func (m Message) Level() parser.LogLevel {
+	return parser.SyslogLevel(m.Severity)
+}
+
+// Fields returns the header values worth keeping on a log entry.
+func (m Message) Fields() map[string]string {
+	fields := make(map[string]string, 5+len(m.StructuredData))
+	setField(fields, "hostname", m.Hostname)
+	setField(fields, "app_name", m.AppName)
+	setField(fields, "procid", m.ProcID)
+	setField(fields, "msgid", m.MsgID)
+	fields["facility"] = strconv.Itoa(m.Facility)
+	for k, v := range m.StructuredData {
+		fields[k] = v
+	}
+	return fields
+}
+
+func setField(fields map[string]string, key, value string) {
+	if value != "" {
+		fields[key] = value
+	}
+}
+
+// Parse parses a syslog message. The format is picked by the version field
+// that only RFC 5424 messages have after the priority.
+func Parse(data []byte, now time.Time) (Message, error) {
+	data = bytes.TrimRight(data, "\r\n\x00")
+
+	pri, rest, err := parsePriority(data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{
+		Facility: pri / 8,
+		Severity: pri % 8,
+	}
+
+	if len(rest) >= 2 && rest[0] == '1' && rest[1] == ' ' {
+		err = parse5424(&msg, rest[2:], now)
+	} else {
+		parse3164(&msg, rest, now)
+	}
+	return msg, err
+}
+
+func parsePriority(data []byte) (int, []byte, error) {
+	if len(data) < 3 || data[0] != '<' {
+		return 0, nil, ErrInvalidPriority
+	}
+	end := bytes.IndexByte(data[:min(len(data), 5)], '>')
+	if end < 2 {
+		return 0, nil, ErrInvalidPriority
+	}
+	pri, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || pri > 191 {
+		return 0, nil, ErrInvalidPriority
+	}
+	return pri, data[end+1:], nil
+}
+
+// parse5424 parses "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]".
+func parse5424(msg *Message, data []byte, now time.Time) error {
+	var fields [5]string
+	for i := range fields {
+		field, rest, found := bytes.Cut(data, []byte(" "))
+		if !found && i < len(fields)-1 {
+			return ErrInvalidHeader
+		}
+		fields[i] = nilValue(field)
+		data = rest
+	}
+
+	msg.Timestamp = now
+	if fields[0] != "" {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return ErrInvalidHeader
+		}
+		msg.Timestamp = ts
+	}
+	msg.Hostname = fields[1]
+	msg.AppName = fields[2]
+	msg.ProcID = fields[3]
+	msg.MsgID = fields[4]
+
+	sd, rest, err := parseStructuredData(data)
+	if err != nil {
+		return err
+	}
+	msg.StructuredData = sd
+
+	rest = bytes.TrimPrefix(rest, []byte(" "))
+	msg.Message = bytes.TrimPrefix(rest, []byte("\xef\xbb\xbf"))
+	return nil
+}
+
+func nilValue(field []byte) string {
+	if string(field) == "-" {
+		return ""
+	}
+	return string(field)
+}
+
+// parseStructuredData parses "-" or a sequence of `[id key="value" ...]`.
+func parseStructuredData(data []byte) (map[string]string, []byte, error) {
+	if len(data) == 0 {
+		return nil, data, nil
+	}
+	if data[0] == '-' {
+		return nil, data[1:], nil
+	}
+
+	sd := make(map[string]string)
+	for len(data) > 0 && data[0] == '[' {
+		data = data[1:]
+
+		end := bytes.IndexAny(data, " ]")
+		if end == -1 {
+			return nil, nil, ErrInvalidHeader
+		}
+		id := string(data[:end])
+		data = data[end:]
+
+		for len(data) > 0 && data[0] == ' ' {
+			data = data[1:]
+
+			eq := bytes.IndexByte(data, '=')
+			if eq == -1 || len(data) < eq+2 || data[eq+1] != '"' {
+				return nil, nil, ErrInvalidHeader
+			}
+			name := string(data[:eq])
+
+			value, rest, ok := parseParamValue(data[eq+2:])
+			if !ok {
+				return nil, nil, ErrInvalidHeader
+			}
+			sd[id+"."+name] = value
+			data = rest
+		}
+
+		if len(data) == 0 || data[0] != ']' {
+			return nil, nil, ErrInvalidHeader
+		}
+		data = data[1:]
+	}
+	return sd, data, nil
+}
+
+// parseParamValue reads a quoted SD param value, unescaping `\"`, `\\`
+// and `\]`, and returns the data after the closing quote.
+func parseParamValue(data []byte) (string, []byte, bool) {
+	var value []byte
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			if i+1 < len(data) && bytes.IndexByte([]byte(`"\]`), data[i+1]) != -1 {
+				i++
+			}
+			value = append(value, data[i])
+		case '"':
+			return string(value), data[i+1:], true
+		default:
+			value = append(value, data[i])
+		}
+	}
+	return "", nil, false
+}
+
+// parse3164 parses "Mmm dd hh:mm:ss HOSTNAME TAG: MSG". BSD syslog is loosely
+// specified, so parts that don't fit are left in the message.
+func parse3164(msg *Message, data []byte, now time.Time) {
+	msg.Timestamp = now
+	if len(data) >= len(time.Stamp)+1 && data[len(time.Stamp)] == ' ' {
+		ts, err := time.ParseInLocation(time.Stamp, string(data[:len(time.Stamp)]), now.Location())
+		if err == nil {
+			msg.Timestamp = withYear(ts, now)
+			data = data[len(time.Stamp)+1:]
+
+			if host, rest, found := bytes.Cut(data, []byte(" ")); found {
+				msg.Hostname = string(host)
+				data = rest
+			}
+		}
+	}
+
+	msg.Message = data
+	tagEnd := bytes.IndexAny(data, ":[ ")
+	if tagEnd <= 0 || data[tagEnd] == ' ' {
+		return
+	}
+
+	msg.AppName = string(data[:tagEnd])
+	rest := data[tagEnd:]
+	if rest[0] == '[' {
+		pidEnd := bytes.IndexByte(rest, ']')
+		if pidEnd == -1 {
+			msg.AppName = ""
+			return
+		}
+		msg.ProcID = string(rest[1:pidEnd])
+		rest = rest[pidEnd+1:]
+	}
+	if len(rest) == 0 || rest[0] != ':' {
+		msg.AppName, msg.ProcID = "", ""
+		return
+	}
+	msg.Message = bytes.TrimPrefix(rest[1:], []byte(" "))
+}
+
+// withYear puts a year-less RFC 3164 timestamp into the current year, or the
+// previous one around new year.
+func withYear(ts, now time.Time) time.Time {
+	ts = ts.AddDate(now.Year(), 0, 0)
+	if ts.After(now.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	return ts
+}
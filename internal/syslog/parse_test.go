@@ -0,0 +1,122 @@
+package syslog
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+var now = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+func TestParse_RFC3164(t *testing.T) {
+	msg, err := Parse([]byte("<34>Jan 15 10:30:00 web01 sshd[1234]: Failed password for root\n"), now)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if msg.Facility != 4 || msg.Severity != 2 {
+		t.Errorf("expected facility 4 severity 2, got %d %d", msg.Facility, msg.Severity)
+	}
+	if want := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC); !msg.Timestamp.Equal(want) {
+		t.Errorf("expected timestamp %v, got %v", want, msg.Timestamp)
+	}
+	if msg.Hostname != "web01" || msg.AppName != "sshd" || msg.ProcID != "1234" {
+		t.Errorf("unexpected header: %+v", msg)
+	}
+	if string(msg.Message) != "Failed password for root" {
+		t.Errorf("unexpected message: %q", msg.Message)
+	}
+	if msg.Level() != parser.LevelCritical {
+		t.Errorf("expected CRITICAL level, got %s", msg.Level())
+	}
+}
+
+func TestParse_RFC3164PreviousYear(t *testing.T) {
+	msg, err := Parse([]byte("<13>Dec 31 23:59:59 host app: bye"), now)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if msg.Timestamp.Year() != 2023 {
+		t.Errorf("expected a December message in January to be from 2023, got %v", msg.Timestamp)
+	}
+}
+
+func TestParse_RFC3164WithoutTag(t *testing.T) {
+	msg, err := Parse([]byte("<13>just some text"), now)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !msg.Timestamp.Equal(now) {
+		t.Errorf("expected receive time, got %v", msg.Timestamp)
+	}
+	if msg.AppName != "" || string(msg.Message) != "just some text" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+}
+
+func TestParse_RFC5424(t *testing.T) {
+	data := `<165>1 2024-01-15T10:30:00.003Z mymachine evntslog 42 ID47 ` +
+		`[exampleSDID@32473 iut="3" eventSource="App\"lication"][meta seq="7"] ` +
+		"\xef\xbb\xbfAn application event"
+	msg, err := Parse([]byte(data), now)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if msg.Facility != 20 || msg.Severity != 5 {
+		t.Errorf("expected facility 20 severity 5, got %d %d", msg.Facility, msg.Severity)
+	}
+	if want := time.Date(2024, 1, 15, 10, 30, 0, 3e6, time.UTC); !msg.Timestamp.Equal(want) {
+		t.Errorf("expected timestamp %v, got %v", want, msg.Timestamp)
+	}
+	if msg.Hostname != "mymachine" || msg.AppName != "evntslog" || msg.ProcID != "42" ||
+		msg.MsgID != "ID47" {
+		t.Errorf("unexpected header: %+v", msg)
+	}
+	if string(msg.Message) != "An application event" {
+		t.Errorf("unexpected message: %q", msg.Message)
+	}
+
+	fields := msg.Fields()
+	if fields["exampleSDID@32473.eventSource"] != `App"lication` || fields["meta.seq"] != "7" {
+		t.Errorf("unexpected structured data: %v", fields)
+	}
+	if fields["hostname"] != "mymachine" || fields["facility"] != "20" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+}
+
+func TestParse_RFC5424NilValues(t *testing.T) {
+	msg, err := Parse([]byte("<15>1 - - - - - -"), now)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !msg.Timestamp.Equal(now) || msg.Hostname != "" || len(msg.Message) != 0 {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if msg.Level() != parser.LevelDebug {
+		t.Errorf("expected DEBUG level, got %s", msg.Level())
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		data string
+		want error
+	}{
+		{"no priority", ErrInvalidPriority},
+		{"<>x", ErrInvalidPriority},
+		{"<192>x", ErrInvalidPriority},
+		{"<13>1 2024-01-15T10:30:00Z host", ErrInvalidHeader},
+		{"<13>1 yesterday host app - - - msg", ErrInvalidHeader},
+		{`<13>1 - host app - - [id key="unterminated] msg`, ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.data), now); !errors.Is(err, tt.want) {
+			t.Errorf("Parse(%q): expected %v, got %v", tt.data, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kxrxh/logram/internal/input"
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+const (
+	defaultSourceName = "syslog"
+
+	// Syslog messages fit in one UDP datagram; TCP frames are capped at the
+	// same size.
+	maxMessageSize = 64 * 1024
+
+	// Malformed messages are logged at most once per malformedLogInterval,
+	// with a sample of up to malformedSampleSize bytes.
+	malformedLogInterval = 10 * time.Second
+	malformedSampleSize  = 200
+)
+
+// ErrMessageTooLong is returned for a newline-terminated message over
+// maxMessageSize.
+var ErrMessageTooLong = errors.New("syslog message too long")
+
+type Config struct {
+	// Name is the source name put on received lines.
+	Name    string
+	UDPAddr string
+	TCPAddr string
+	// DeadLetters, if set, records malformed messages along with the lines
+	// the parser rejects.
+	DeadLetters *parser.DeadLetters
+}
+
+// Server receives syslog messages over UDP and TCP.
+type Server struct {
+	name string
+	udp  net.PacketConn
+	tcp  net.Listener
+
+	out chan input.Line
+	wg  sync.WaitGroup
+
+	deadLetters *parser.DeadLetters
+	malformed   logLimiter
+}
+
+// logLimiter lets a log line through at most once per interval and counts
+// the ones it held back.
+type logLimiter struct {
+	mu         sync.Mutex
+	every      time.Duration
+	last       time.Time
+	suppressed int
+}
+
+// allow reports whether to log now and how many were held back since the
+// last time.
+func (l *logLimiter) allow(now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() && now.Sub(l.last) < l.every {
+		l.suppressed++
+		return false, 0
+	}
+	suppressed := l.suppressed
+	l.last = now
+	l.suppressed = 0
+	return true, suppressed
+}
+
+// Listen binds the configured addresses. Empty addresses are not listened on.
+func Listen(cfg Config) (*Server, error) {
+	s := &Server{
+		name:        cfg.Name,
+		out:         make(chan input.Line, 200),
+		deadLetters: cfg.DeadLetters,
+		malformed:   logLimiter{every: malformedLogInterval},
+	}
+	if s.name == "" {
+		s.name = defaultSourceName
+	}
+
+	var lc net.ListenConfig
+	if cfg.UDPAddr != "" {
+		conn, err := lc.ListenPacket(context.Background(), "udp", cfg.UDPAddr)
+		if err != nil {
+			return nil, fmt.Errorf("listen syslog udp %s: %w", cfg.UDPAddr, err)
+		}
+		s.udp = conn
+	}
+	if cfg.TCPAddr != "" {
+		ln, err := lc.Listen(context.Background(), "tcp", cfg.TCPAddr)
+		if err != nil {
+			s.closeListeners()
+			return nil, fmt.Errorf("listen syslog tcp %s: %w", cfg.TCPAddr, err)
+		}
+		s.tcp = ln
+	}
+
+	return s, nil
+}
+
+func (s *Server) UDPAddr() net.Addr {
+	if s.udp == nil {
+		return nil
+	}
+	return s.udp.LocalAddr()
+}
+
+func (s *Server) TCPAddr() net.Addr {
+	if s.tcp == nil {
+		return nil
+	}
+	return s.tcp.Addr()
+}
+
+// Start receives messages until ctx is done. The returned channel is closed
+// once every connection has finished.
+func (s *Server) Start(ctx context.Context) <-chan input.Line {
+	if s.udp != nil {
+		s.wg.Go(func() { s.serveUDP(ctx) })
+	}
+	if s.tcp != nil {
+		s.wg.Go(func() { s.serveTCP(ctx) })
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.closeListeners()
+	}()
+	go func() {
+		s.wg.Wait()
+		close(s.out)
+	}()
+
+	return s.out
+}
+
+func (s *Server) closeListeners() {
+	if s.udp != nil {
+		if err := s.udp.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("close syslog udp: %v", err)
+		}
+	}
+	if s.tcp != nil {
+		if err := s.tcp.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("close syslog tcp: %v", err)
+		}
+	}
+}
+
+func (s *Server) serveUDP(ctx context.Context) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("read syslog udp: %v", err)
+			}
+			return
+		}
+		if !s.emit(ctx, append([]byte(nil), buf[:n]...)) {
+			return
+		}
+	}
+}
+
+func (s *Server) serveTCP(ctx context.Context) {
+	var conns sync.WaitGroup
+	defer conns.Wait()
+
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("accept syslog tcp: %v", err)
+			}
+			return
+		}
+
+		conns.Go(func() { s.serveConn(ctx, conn) })
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		frame, err := readFrame(r)
+		if errors.Is(err, ErrMessageTooLong) {
+			s.reject(frame, err)
+			continue
+		}
+		if len(frame) > 0 && !s.emit(ctx, frame) {
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("read syslog tcp from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// readFrame reads one RFC 6587 frame: octet-counted ("LEN MSG") when it
+// starts with a digit, newline-terminated otherwise.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '0' && first[0] <= '9' {
+		lenStr, err := r.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(lenStr[:len(lenStr)-1])
+		if err != nil || size <= 0 || size > maxMessageSize {
+			return nil, fmt.Errorf("%w: frame length %q", ErrInvalidHeader, lenStr)
+		}
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		// Skip the rest of an overlong message, so that it isn't taken for
+		// the next one, and keep its start for the rejected sample.
+		start := append([]byte(nil), line...)
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = r.ReadSlice('\n')
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return start, fmt.Errorf("%w: over %d bytes", ErrMessageTooLong, maxMessageSize)
+	}
+	return append([]byte(nil), bytes.TrimRight(line, "\r\n")...), err
+}
+
+// reject records a message that can't be parsed as a dead letter and logs
+// it.
+func (s *Server) reject(data []byte, err error) {
+	if s.deadLetters != nil {
+		s.deadLetters.Reject(s.name, &parser.ParseError{
+			Line:   string(data),
+			Reason: fmt.Errorf("%w: %w", parser.ErrInvalidFormat, err),
+		})
+	}
+	s.logMalformed(data, err)
+}
+
+// logMalformed logs a sample of a message that can't be parsed, rate limited
+// so that a bad sender can't flood the log.
+func (s *Server) logMalformed(data []byte, err error) {
+	ok, suppressed := s.malformed.allow(time.Now())
+	if !ok {
+		return
+	}
+	sample := data
+	if len(sample) > malformedSampleSize {
+		sample = sample[:malformedSampleSize]
+	}
+	if suppressed > 0 {
+		log.Printf("skipped %d malformed syslog messages", suppressed)
+	}
+	log.Printf("parse syslog message %q (%d bytes): %v", sample, len(data), err)
+}
+
+func (s *Server) emit(ctx context.Context, data []byte) bool {
+	msg, err := Parse(data, time.Now())
+	if err != nil {
+		s.reject(data, err)
+		return true
+	}
+
+	line := input.Line{
+		Source: s.name,
+		Data:   data,
+		Record: &input.Record{
+			Timestamp: msg.Timestamp,
+			Level:     string(msg.Level()),
+			Message:   msg.Message,
+			Fields:    msg.Fields(),
+		},
+	}
+	select {
+	case s.out <- line:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,184 @@
+package syslog
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/input"
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+func collect(t *testing.T, ch <-chan input.Line, count int) []input.Line {
+	t.Helper()
+
+	var got []input.Line
+	timeout := time.After(2 * time.Second)
+	for len(got) < count {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, line)
+		case <-timeout:
+			return got
+		}
+	}
+	return got
+}
+
+func TestServer_UDP(t *testing.T) {
+	s, err := Listen(Config{UDPAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	out := s.Start(t.Context())
+
+	conn, err := net.Dial("udp", s.UDPAddr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.Write([]byte("<11>Jan 15 10:30:00 web01 app[7]: disk full")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	got := collect(t, out, 1)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 line, got %d", len(got))
+	}
+	line := got[0]
+	if line.Source != defaultSourceName || line.Record == nil {
+		t.Fatalf("unexpected line: %+v", line)
+	}
+	if string(line.Record.Message) != "disk full" || line.Record.Level != "ERROR" {
+		t.Errorf("unexpected record: %+v", line.Record)
+	}
+	if line.Record.Fields["app_name"] != "app" || line.Record.Fields["procid"] != "7" {
+		t.Errorf("unexpected fields: %v", line.Record.Fields)
+	}
+}
+
+func TestServer_TCPFraming(t *testing.T) {
+	s, err := Listen(Config{Name: "net", TCPAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	out := s.Start(t.Context())
+
+	conn, err := net.Dial("tcp", s.TCPAddr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	octetCounted := "<14>1 - host app - - - counted\nwith newline"
+	stream := "<14>Jan 15 10:30:00 host app: first\n" +
+		"<14>Jan 15 10:30:01 host app: second\r\n" +
+		strconv.Itoa(len(octetCounted)) + " " + octetCounted
+	if _, err := conn.Write([]byte(stream)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	_ = conn.Close()
+
+	got := collect(t, out, 3)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(got))
+	}
+
+	want := []string{"first", "second", "counted\nwith newline"}
+	for i, line := range got {
+		if line.Source != "net" {
+			t.Errorf("line %d: expected source net, got %q", i, line.Source)
+		}
+		if string(line.Record.Message) != want[i] {
+			t.Errorf("line %d: expected %q, got %q", i, want[i], line.Record.Message)
+		}
+	}
+}
+
+func TestServer_RejectsMalformedMessages(t *testing.T) {
+	deadLetters := parser.NewDeadLetters(10)
+	s, err := Listen(Config{Name: "net", TCPAddr: "127.0.0.1:0", DeadLetters: deadLetters})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	out := s.Start(t.Context())
+
+	conn, err := net.Dial("tcp", s.TCPAddr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	stream := "<14>" + strings.Repeat("x", maxMessageSize+1000) + "\n" +
+		"no priority\n" +
+		"<14>Jan 15 10:30:00 host app: after\n"
+	if _, err := conn.Write([]byte(stream)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	_ = conn.Close()
+
+	// Messages on a connection are handled in order, so both rejects are
+	// recorded once the valid message arrives
+	got := collect(t, out, 1)
+	if len(got) != 1 || string(got[0].Record.Message) != "after" {
+		t.Fatalf("expected the valid message after the overlong one, got %d lines", len(got))
+	}
+
+	stats := deadLetters.Stats()
+	if stats.Rejected != 2 || stats.ByReason[parser.ReasonInvalidFormat] != 2 {
+		t.Fatalf("expected 2 rejected messages, got %+v", stats)
+	}
+	if s := stats.Samples[0]; s.Source != "net" || !strings.HasPrefix(s.Line, "<14>xxx") {
+		t.Errorf("unexpected sample of the overlong message: %+v", s)
+	}
+}
+
+func TestServer_StopsOnCancel(t *testing.T) {
+	s, err := Listen(Config{UDPAddr: "127.0.0.1:0", TCPAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	out := s.Start(ctx)
+
+	conn, err := net.Dial("tcp", s.TCPAddr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	cancel()
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Fatal("expected no lines")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("output channel was not closed after cancel")
+	}
+}
+
+func TestLogLimiter(t *testing.T) {
+	l := logLimiter{every: 10 * time.Second}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if ok, _ := l.allow(now); !ok {
+		t.Fatal("expected the first line to be logged")
+	}
+	for range 3 {
+		now = now.Add(time.Second)
+		if ok, _ := l.allow(now); ok {
+			t.Fatal("expected lines within the interval to be held back")
+		}
+	}
+	now = now.Add(10 * time.Second)
+	if ok, suppressed := l.allow(now); !ok || suppressed != 3 {
+		t.Fatalf("expected a line reporting 3 held back, got %t and %d", ok, suppressed)
+	}
+}
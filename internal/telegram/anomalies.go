@@ -0,0 +1,15 @@
+package telegram
+
+import (
+	"log"
+
+	"github.com/kxrxh/logram/internal/anomaly"
+)
+
+// NotifyAnomaly tells subscribers that a rule matches far more or less often
+// than usual.
+func (b *Bot) NotifyAnomaly(alert anomaly.Alert) {
+	if err := b.Broadcast(b.formatter.FormatAnomalyAlert(alert)); err != nil {
+		log.Printf("send anomaly alert: %v", err)
+	}
+}
@@ -2,13 +2,19 @@ package telegram
 
 import (
 	"context"
+	"errors"
+	"html"
 	"log"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/kxrxh/logram/internal/database"
+	"github.com/kxrxh/logram/internal/fingerprint"
+	"github.com/kxrxh/logram/internal/heartbeat"
 	"github.com/kxrxh/logram/internal/parser"
+	"github.com/kxrxh/logram/internal/redact"
+	"github.com/kxrxh/logram/internal/schedule"
 	"github.com/mymmrac/telego"
 	th "github.com/mymmrac/telego/telegohandler"
 	tu "github.com/mymmrac/telego/telegoutil"
@@ -20,6 +26,18 @@ type Bot struct {
 	regexManager    *RegexManager
 	db              *database.DB
 	batchManager    *BatchManager
+	deadLetters     *parser.DeadLetters
+	redactor        *redact.Redactor
+	dedup           *DedupManager
+	thresholds      *ThresholdManager
+	digests         *DigestManager
+	incidents       *IncidentManager
+	escalations     *EscalationManager
+	fingerprints    *fingerprint.Tracker
+	heartbeats      *heartbeat.Monitor
+
+	newOnlyMu sync.RWMutex
+	newOnly   map[int64]bool
 
 	addRegexMu sync.Mutex
 	addRegex   map[int64]*addRegexWizardState
@@ -54,6 +72,10 @@ func NewBot(token string, db *database.DB, regexManager *RegexManager) (*Bot, er
 	ctx, cancel := context.WithCancel(context.Background())
 
 	var initialBatchEnabled map[int64]bool
+	dedupWindows := make(map[int64]time.Duration)
+	newOnly := make(map[int64]bool)
+	digestSchedules := make(map[int64]string)
+	escalationPolicies := make(map[int64][]EscalationTier)
 	if db != nil {
 		initialBatchEnabled = make(map[int64]bool)
 		chats, err := db.GetAllChats()
@@ -64,6 +86,21 @@ func NewBot(token string, db *database.DB, regexManager *RegexManager) (*Bot, er
 				if chat.BatchEnabled {
 					initialBatchEnabled[chat.ChatID] = true
 				}
+				if chat.DedupWindow != 0 {
+					dedupWindows[chat.ChatID] = chat.DedupWindow
+				}
+				if chat.NewOnly {
+					newOnly[chat.ChatID] = true
+				}
+				if chat.DeliveryMode == DeliveryDigest {
+					digestSchedules[chat.ChatID] = chat.DigestSchedule
+				}
+				tiers, ok, err := loadEscalationPolicy(chat.EscalationPolicy)
+				if err != nil {
+					log.Printf("load escalation policy (chat_id=%d): %v", chat.ChatID, err)
+				} else if ok {
+					escalationPolicies[chat.ChatID] = tiers
+				}
 			}
 		}
 	} else {
@@ -79,7 +116,7 @@ func NewBot(token string, db *database.DB, regexManager *RegexManager) (*Bot, er
 		initialBatchEnabled,
 	)
 
-	return &Bot{
+	b := &Bot{
 		client:          client,
 		subscriptionMgr: NewSubscriptionManager(db),
 		regexManager:    regexManager,
@@ -88,9 +125,25 @@ func NewBot(token string, db *database.DB, regexManager *RegexManager) (*Bot, er
 		addRegex:        make(map[int64]*addRegexWizardState),
 		commandRegistry: NewCommandRegistry(),
 		formatter:       NewMessageFormatter(),
+		newOnly:         newOnly,
 		ctx:             ctx,
 		cancel:          cancel,
-	}, nil
+	}
+	b.dedup = NewDedupManager(0, dedupWindows, b.sendRepeatSummary)
+	b.thresholds = NewThresholdManager(b.sendThresholdAlert)
+	if db != nil {
+		if b.incidents, err = NewIncidentManager(db); err != nil {
+			log.Printf("load incidents: %v", err)
+		}
+	}
+	b.escalations = NewEscalationManager(b.incidents, escalationPolicies, b.sendEscalation)
+	b.digests = NewDigestManager(b.sendDigest)
+	for chatID, spec := range digestSchedules {
+		if err := b.digests.Set(chatID, spec); err != nil {
+			log.Printf("load digest schedule (chat_id=%d): %v", chatID, err)
+		}
+	}
+	return b, nil
 }
 
 func (b *Bot) RegisterCommand(
@@ -107,8 +160,24 @@ func (b *Bot) setupCommands() {
 	b.RegisterCommand("help", "Показать доступные команды", b.handleHelpCommand)
 	b.RegisterCommand(
 		"batch",
-		"Вкл/выкл группировку логов (сообщения батчами)",
+		"Режим доставки: сразу, батчами или сводкой по расписанию (/batch digest daily)",
 		b.handleBatchCommand,
+		"delivery",
+	)
+	b.RegisterCommand(
+		"dedup",
+		"Окно подавления повторов для этого чата (/dedup 5m, /dedup off, /dedup default)",
+		b.handleDedupCommand,
+	)
+	b.RegisterCommand(
+		"newonly",
+		"Вкл/выкл режим \"только новые ошибки\" для этого чата",
+		b.handleNewOnlyCommand,
+	)
+	b.RegisterCommand(
+		"level",
+		"Минимальный уровень логов для этого чата (/level WARN, /level all)",
+		b.handleLevelCommand,
 	)
 	b.RegisterCommand(
 		"regexes",
@@ -120,6 +189,16 @@ func (b *Bot) setupCommands() {
 		"Добавить regex-фильтр для этого чата (бот должен быть отключен)",
 		b.handleAddRegexCommand,
 	)
+	b.RegisterCommand(
+		"threshold",
+		"Оповещать, когда правило сработало N раз за окно (/threshold имя 50 1m)",
+		b.handleThresholdCommand,
+	)
+	b.RegisterCommand(
+		"escalate",
+		"Кого звать, если инцидент не приняли (/escalate 10m -100123 30m 111)",
+		b.handleEscalateCommand,
+	)
 	b.RegisterCommand(
 		"resetregex",
 		"Сбросить все regex-фильтры для этого чата к значениям по умолчанию",
@@ -130,6 +209,12 @@ func (b *Bot) setupCommands() {
 		"Удалить одно regex-правило для этого чата",
 		b.handleRemoveRegexCommand,
 	)
+	b.RegisterCommand(
+		"rejected",
+		"Показать строки логов, которые не удалось разобрать",
+		b.handleRejectedCommand,
+		"deadletters",
+	)
 	b.RegisterCommand(
 		"status",
 		"Показать текущий статус подписки",
@@ -176,13 +261,28 @@ func (b *Bot) Start() error {
 
 	botHandler.HandleMessage(b.handleAnyMessage)
 
-	botHandler.HandleCallbackQuery(
-		func(ctx *th.Context, query telego.CallbackQuery) error {
-			return b.handleRemoveRegexCallbackQuery(ctx, query)
-		},
-		th.AnyCallbackQueryWithMessage(),
-		th.CallbackDataPrefix(callbackRemoveRegexPrefix),
-	)
+	// Callback data starts with the prefix of the handler it is meant for.
+	callbacks := []struct {
+		prefix  string
+		handler th.CallbackQueryHandler
+	}{
+		{callbackRemoveRegexPrefix, b.handleRemoveRegexCallbackQuery},
+		{callbackIncidentPrefix, b.handleIncidentCallbackQuery},
+	}
+	for _, cb := range callbacks {
+		botHandler.HandleCallbackQuery(
+			cb.handler,
+			th.AnyCallbackQueryWithMessage(),
+			th.CallbackDataPrefix(cb.prefix),
+		)
+	}
+
+	go b.thresholds.Run(b.ctx, thresholdCheckInterval)
+	go b.digests.Run(b.ctx, digestCheckInterval)
+	if b.incidents != nil {
+		go b.escalations.Run(b.ctx, escalationCheckInterval)
+		go b.incidents.Run(b.ctx, incidentCheckInterval, b.updateIncidentMessage)
+	}
 
 	go func() {
 		if err := botHandler.Start(); err != nil {
@@ -267,6 +367,14 @@ func (b *Bot) handleStatusCommand(ctx *th.Context, update telego.Update) error {
 	isSubscribed := b.subscriptionMgr.IsSubscribed(chatID)
 
 	statusMessage := b.formatter.FormatSubscriptionStatus(isSubscribed)
+	if b.deadLetters != nil {
+		statusMessage += "\n\n" + b.formatter.FormatDeadLetterSummary(b.deadLetters.Stats())
+	}
+	if b.heartbeats != nil {
+		if statuses := b.heartbeats.Status(); len(statuses) > 0 {
+			statusMessage += "\n\n" + b.formatter.FormatHeartbeatStatus(statuses, time.Now())
+		}
+	}
 	return b.client.SendMessageHTMLWithReplyMarkup(chatID, statusMessage, b.mainKeyboard())
 }
 
@@ -347,38 +455,101 @@ func (b *Bot) handleBatchCommand(_ *th.Context, update telego.Update) error {
 	}
 
 	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.Text)
 
-	current := b.batchManager.IsEnabled(chatID)
-	next := !current
-
-	if b.db != nil {
-		if err := b.db.SetChatBatchEnabled(chatID, next); err != nil {
-			b.sendErrorResponse(chatID, "batch toggle", err)
-			return nil
+	// Without arguments /batch toggles between realtime and batch.
+	mode := DeliveryBatch
+	if b.deliveryMode(chatID) != DeliveryRealtime {
+		mode = DeliveryRealtime
+	}
+	var spec string
+	if len(args) > 1 {
+		mode = strings.ToLower(args[1])
+		spec = strings.Join(args[2:], " ")
+		if spec == "" {
+			spec = defaultDigestSchedule
 		}
 	}
 
-	b.batchManager.SetEnabled(chatID, next)
+	switch mode {
+	case DeliveryRealtime, DeliveryBatch, DeliveryDigest:
+	default:
+		msg := "Режимы доставки: <code>/batch realtime</code>, <code>/batch batch</code>, " +
+			"<code>/batch digest daily</code> (hourly, daily, weekly или cron, " +
+			"например <code>/batch digest 0 9 * * 1-5</code>)."
+		return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
+	}
 
-	if next {
-		msg := "<b>Батчинг включен</b>\n\nТеперь бот будет объединять несколько логов в одно сообщение."
-		if !b.subscriptionMgr.IsSubscribed(chatID) {
-			msg += "\n\n<i>Вы сейчас не подписаны на логи. Используйте /start чтобы включить уведомления.</i>"
+	if err := b.setDelivery(chatID, mode, spec); err != nil {
+		if errors.Is(err, schedule.ErrInvalid) {
+			msg := "Неверное расписание. Примеры: <code>hourly</code>, <code>daily</code>, " +
+				"<code>0 9 * * 1-5</code>."
+			return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
 		}
-		if err := b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard()); err != nil {
-			log.Printf("Failed to send batch status to chat %d: %v", chatID, err)
+		b.sendErrorResponse(chatID, "delivery change", err)
+		return nil
+	}
+
+	var msg string
+	switch mode {
+	case DeliveryBatch:
+		msg = "<b>Батчинг включен</b>\n\nТеперь бот будет объединять несколько логов в одно сообщение."
+	case DeliveryDigest:
+		msg = "<b>Сводка включена</b>\n\nВместо отдельных логов бот будет присылать сводку " +
+			"по расписанию <code>" + html.EscapeString(spec) + "</code>."
+	default:
+		msg = "<b>Батчинг выключен</b>\n\nТеперь бот будет присылать каждый лог отдельным сообщением."
+	}
+	if !b.subscriptionMgr.IsSubscribed(chatID) {
+		msg += "\n\n<i>Вы сейчас не подписаны на логи. Используйте /start чтобы включить уведомления.</i>"
+	}
+	if err := b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard()); err != nil {
+		log.Printf("Failed to send batch status to chat %d: %v", chatID, err)
+	}
+	return nil
+}
+
+func (b *Bot) handleLevelCommand(_ *th.Context, update telego.Update) error {
+	if update.Message == nil {
+		return nil
+	}
+
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.Text)
+
+	if len(args) < 2 {
+		current := "все уровни"
+		if level := b.regexManager.ChatMinLevel(chatID); level != "" {
+			current = getLevelText(level) + " и выше"
 		}
-	} else {
-		msg := "<b>Батчинг выключен</b>\n\nТеперь бот будет присылать каждый лог отдельным сообщением."
-		if !b.subscriptionMgr.IsSubscribed(chatID) {
-			msg += "\n\n<i>Вы сейчас не подписаны на логи. Используйте /start чтобы включить уведомления.</i>"
+		msg := "<b>Минимальный уровень:</b> " + current +
+			"\n\nИзменить: <code>/level WARN</code>, сбросить: <code>/level all</code>."
+		return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
+	}
+
+	var level parser.LogLevel
+	if !strings.EqualFold(args[1], "all") {
+		var ok bool
+		if level, ok = parser.LookupLevel(args[1]); !ok {
+			msg := "Неизвестный уровень. Доступны: TRACE, DEBUG, INFO, WARN, ERROR, CRITICAL, FATAL."
+			return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
 		}
-		if err := b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard()); err != nil {
-			log.Printf("Failed to send batch status to chat %d: %v", chatID, err)
+	}
+
+	if b.db != nil {
+		if err := b.db.SetChatMinLevel(chatID, string(level)); err != nil {
+			b.sendErrorResponse(chatID, "level change", err)
+			return nil
 		}
 	}
+	b.regexManager.SetChatMinLevel(chatID, level)
 
-	return nil
+	msg := "<b>Уровень сброшен</b>\n\nБот будет присылать логи всех уровней."
+	if level != "" {
+		msg = "<b>Уровень изменен</b>\n\nБот будет присылать логи уровня " +
+			getLevelText(level) + " и выше."
+	}
+	return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
 }
 
 func (b *Bot) SendMessageHTML(chatID int64, text string) error {
@@ -386,6 +557,7 @@ func (b *Bot) SendMessageHTML(chatID int64, text string) error {
 }
 
 func (b *Bot) Stop() {
+	b.dedup.Stop()
 	b.cancel()
 	log.Println("Telegram bot stopped")
 }
@@ -398,27 +570,105 @@ func (b *Bot) IsChatSubscribed(chatID int64) bool {
 	return b.subscriptionMgr.IsSubscribed(chatID)
 }
 
+// SetRedactor masks entries and rejected lines before they are sent.
+func (b *Bot) SetRedactor(r *redact.Redactor) {
+	b.redactor = r
+}
+
 func (b *Bot) SendLog(entry parser.LogEntry) error {
-	msg := b.formatter.FormatLogEntry(entry)
+	// Chats match rules against the original line, but only the redacted
+	// entry is formatted and fingerprinted.
+	matches := b.routes(entry)
+	redacted := b.redactor.Entry(entry)
+	isNew := true
+	if b.fingerprints != nil &&
+		(len(matches) > 0 || redacted.Level.AtLeast(fingerprint.MinUnroutedLevel)) {
+		_, isNew = b.fingerprints.Observe(redacted)
+	}
+	var hash, template string
+	fingerprintOf := func() (string, string) {
+		if hash == "" {
+			hash, template = fingerprint.Of(redacted)
+		}
+		return hash, template
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+
+	msg := b.formatter.FormatLogEntry(redacted)
 
-	subscribers := b.subscriptionMgr.GetAllSubscribers()
 	var lastErr error
-	for _, chatID := range subscribers {
-		if b.regexManager != nil && !b.regexManager.ShouldSend(chatID, entry.Raw) {
+	for _, m := range matches {
+		chatID := m.ChatID
+		_, digest := b.digests.Schedule(chatID)
+		if digest {
+			hash, template := fingerprintOf()
+			b.digests.Add(chatID, m.Rule, redacted, hash, template, isNew && b.fingerprints != nil)
+		}
+		if m.Threshold.Enabled() {
+			b.thresholds.Hit(chatID, m.Rule, m.Threshold, redacted)
 			continue
 		}
-		if b.batchManager != nil {
-			if err := b.batchManager.Enqueue(chatID, msg); err != nil {
-				lastErr = err
-				log.Printf("Failed to enqueue/send batch for chat %d: %v", chatID, err)
+		if digest {
+			continue
+		}
+		if !isNew && b.isNewOnly(chatID) {
+			continue
+		}
+
+		var incident string
+		if b.incidents != nil && opensIncident(redacted) {
+			hash, _ := fingerprintOf()
+			incident = entryIncidentKey(hash)
+			if b.incidents.Muted(chatID, incident) {
+				continue
 			}
+		}
+		if !b.dedup.Allow(chatID, redacted) {
 			continue
 		}
 
-		if err := b.client.SendMessageHTML(chatID, msg); err != nil {
+		var err error
+		if incident != "" {
+			err = b.sendIncident(chatID, incident, msg)
+		} else {
+			err = b.deliver(chatID, msg)
+		}
+		if err != nil {
 			lastErr = err
-			log.Printf("Failed to send message to chat %d: %v", chatID, err)
 		}
 	}
 	return lastErr
 }
+
+// routes returns the subscribed chats the entry matches, with their rules.
+func (b *Bot) routes(entry parser.LogEntry) []RuleMatch {
+	subscribers := b.subscriptionMgr.GetAllSubscribers()
+	if b.regexManager != nil {
+		return b.regexManager.Matches(subscribers, entry)
+	}
+	matches := make([]RuleMatch, len(subscribers))
+	for i, chatID := range subscribers {
+		matches[i] = RuleMatch{ChatID: chatID}
+	}
+	return matches
+}
+
+// deliver sends a message to the chat, through its batch when it has one.
+func (b *Bot) deliver(chatID int64, msg string) error {
+	if b.batchManager != nil {
+		if err := b.batchManager.Enqueue(chatID, msg); err != nil {
+			log.Printf("Failed to enqueue/send batch for chat %d: %v", chatID, err)
+			return err
+		}
+		return nil
+	}
+
+	if err := b.client.SendMessageHTML(chatID, msg); err != nil {
+		log.Printf("Failed to send message to chat %d: %v", chatID, err)
+		return err
+	}
+	return nil
+}
@@ -67,6 +67,53 @@ func (c *Client) SendMessageHTMLWithReplyMarkup(
 	return err
 }
 
+// SendMessageHTMLWithInlineKeyboard sends a message with buttons and returns
+// its ID, for editing it later.
+func (c *Client) SendMessageHTMLWithInlineKeyboard(
+	chatID int64,
+	text string,
+	keyboard *telego.InlineKeyboardMarkup,
+) (int, error) {
+	msg, err := c.client.SendMessage(c.ctx, tu.Message(
+		tu.ID(chatID),
+		text,
+	).WithParseMode("HTML").WithReplyMarkup(keyboard))
+	if err != nil {
+		return 0, err
+	}
+	return msg.MessageID, nil
+}
+
+// EditMessageHTML replaces the text and buttons of a sent message; a nil
+// keyboard removes the buttons.
+func (c *Client) EditMessageHTML(
+	chatID int64,
+	messageID int,
+	text string,
+	keyboard *telego.InlineKeyboardMarkup,
+) error {
+	params := tu.EditMessageText(tu.ID(chatID), messageID, text).WithParseMode("HTML")
+	if keyboard != nil {
+		params = params.WithReplyMarkup(keyboard)
+	}
+	_, err := c.client.EditMessageText(c.ctx, params)
+	return err
+}
+
+// EditMessageKeyboard replaces the buttons of a sent message; a nil keyboard
+// removes them.
+func (c *Client) EditMessageKeyboard(
+	chatID int64,
+	messageID int,
+	keyboard *telego.InlineKeyboardMarkup,
+) error {
+	_, err := c.client.EditMessageReplyMarkup(
+		c.ctx,
+		tu.EditMessageReplyMarkup(tu.ID(chatID), messageID, keyboard),
+	)
+	return err
+}
+
 func (c *Client) Updates() (<-chan telego.Update, error) {
 	return c.client.UpdatesViaLongPolling(c.ctx, nil)
 }
@@ -0,0 +1,107 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+)
+
+// DeadLetterAlert fires when the share of rejected lines over Window reaches
+// Ratio, once at least MinLines lines were parsed in it.
+type DeadLetterAlert struct {
+	Ratio    float64
+	Window   time.Duration
+	MinLines uint64
+}
+
+// SetDeadLetters lets /rejected and /status report the parser's rejected
+// lines.
+func (b *Bot) SetDeadLetters(d *parser.DeadLetters) {
+	b.deadLetters = d
+}
+
+func (b *Bot) handleRejectedCommand(_ *th.Context, update telego.Update) error {
+	if update.Message == nil {
+		return nil
+	}
+
+	chatID := update.Message.Chat.ID
+	msg := "Статистика отброшенных строк недоступна."
+	if b.deadLetters != nil {
+		msg = b.formatter.FormatDeadLetters(b.deadLetters.Stats())
+	}
+	if err := b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard()); err != nil {
+		log.Printf("Failed to send rejected lines to chat %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// WatchDeadLetters checks the rejected lines every alert window and notifies
+// subscribers when their ratio crosses the threshold. It returns when ctx is
+// done.
+func (b *Bot) WatchDeadLetters(ctx context.Context, d *parser.DeadLetters, alert DeadLetterAlert) {
+	if alert.Ratio <= 0 || alert.Window <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(alert.Window)
+	defer ticker.Stop()
+
+	m := rejectionMonitor{alert: alert, prev: d.Stats()}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			window, fire := m.check(d.Stats())
+			if !fire {
+				continue
+			}
+			msg := b.formatter.FormatDeadLetterAlert(window, alert.Window)
+			if err := b.Broadcast(msg); err != nil {
+				log.Printf("send dead-letter alert: %v", err)
+			}
+		}
+	}
+}
+
+// Broadcast sends a message to every subscriber, bypassing their filters.
+func (b *Bot) Broadcast(text string) error {
+	var lastErr error
+	for _, chatID := range b.subscriptionMgr.GetAllSubscribers() {
+		if err := b.client.SendMessageHTML(chatID, text); err != nil {
+			lastErr = err
+			log.Printf("Failed to send message to chat %d: %v", chatID, err)
+		}
+	}
+	return lastErr
+}
+
+// rejectionMonitor tracks the rejected ratio between checks. It fires once
+// when the ratio crosses the threshold and again only after it drops below.
+type rejectionMonitor struct {
+	alert  DeadLetterAlert
+	prev   parser.DeadLetterStats
+	firing bool
+}
+
+// check returns the counts since the previous check and whether to alert.
+func (m *rejectionMonitor) check(cur parser.DeadLetterStats) (parser.DeadLetterStats, bool) {
+	window := parser.DeadLetterStats{
+		Accepted: cur.Accepted - m.prev.Accepted,
+		Rejected: cur.Rejected - m.prev.Rejected,
+	}
+	m.prev = cur
+
+	if window.Accepted+window.Rejected < m.alert.MinLines {
+		return window, false
+	}
+	over := window.RejectedRatio() >= m.alert.Ratio
+	fire := over && !m.firing
+	m.firing = over
+	return window, fire
+}
@@ -0,0 +1,30 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+func TestRejectionMonitor_FiresOncePerCrossing(t *testing.T) {
+	m := rejectionMonitor{alert: DeadLetterAlert{Ratio: 0.5, Window: time.Minute, MinLines: 10}}
+
+	steps := []struct {
+		accepted, rejected uint64
+		want               bool
+	}{
+		{accepted: 2, rejected: 3, want: false},   // too few lines
+		{accepted: 12, rejected: 13, want: true},  // 10 of 20 rejected
+		{accepted: 22, rejected: 23, want: false}, // still over, already alerted
+		{accepted: 42, rejected: 23, want: false}, // back under
+		{accepted: 42, rejected: 43, want: true},
+	}
+
+	for i, step := range steps {
+		_, fire := m.check(parser.DeadLetterStats{Accepted: step.accepted, Rejected: step.rejected})
+		if fire != step.want {
+			t.Errorf("step %d: expected fire=%v, got %v", i, step.want, fire)
+		}
+	}
+}
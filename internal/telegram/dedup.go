@@ -0,0 +1,222 @@
+package telegram
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kxrxh/logram/internal/fingerprint"
+	"github.com/kxrxh/logram/internal/parser"
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+)
+
+// DedupManager suppresses repeats of an entry in a chat for a window after
+// it was sent, and reports how many there were once the window closes.
+type DedupManager struct {
+	mu            sync.Mutex
+	defaultWindow time.Duration
+	// chatWindows overrides the default window; negative turns dedup off.
+	chatWindows map[int64]time.Duration
+	groups      map[dedupKey]*repeatGroup
+
+	flush func(chatID int64, summary repeatSummary)
+}
+
+type dedupKey struct {
+	chatID int64
+	key    string
+}
+
+type repeatGroup struct {
+	summary repeatSummary
+	timer   *time.Timer
+}
+
+// repeatSummary describes the repeats of an entry within a window.
+type repeatSummary struct {
+	entry       parser.LogEntry
+	count       int
+	first, last time.Time
+	window      time.Duration
+}
+
+func NewDedupManager(
+	defaultWindow time.Duration,
+	chatWindows map[int64]time.Duration,
+	flush func(chatID int64, summary repeatSummary),
+) *DedupManager {
+	if chatWindows == nil {
+		chatWindows = make(map[int64]time.Duration)
+	}
+	return &DedupManager{
+		defaultWindow: defaultWindow,
+		chatWindows:   chatWindows,
+		groups:        make(map[dedupKey]*repeatGroup),
+		flush:         flush,
+	}
+}
+
+func (d *DedupManager) SetDefaultWindow(window time.Duration) {
+	d.mu.Lock()
+	d.defaultWindow = window
+	d.mu.Unlock()
+}
+
+// SetChatWindow sets the chat's window: zero uses the default, a negative
+// window turns dedup off for the chat.
+func (d *DedupManager) SetChatWindow(chatID int64, window time.Duration) {
+	d.mu.Lock()
+	if window == 0 {
+		delete(d.chatWindows, chatID)
+	} else {
+		d.chatWindows[chatID] = window
+	}
+	d.mu.Unlock()
+}
+
+// ChatWindow returns the chat's window, zero when off, and whether the chat
+// overrides the default.
+func (d *DedupManager) ChatWindow(chatID int64) (time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.windowLocked(chatID)
+}
+
+func (d *DedupManager) windowLocked(chatID int64) (time.Duration, bool) {
+	window, ok := d.chatWindows[chatID]
+	if !ok {
+		window = d.defaultWindow
+	}
+	return max(window, 0), ok
+}
+
+// Allow reports whether to send the entry to the chat, counting it as a
+// repeat instead when an identical one was sent within the window.
+func (d *DedupManager) Allow(chatID int64, entry parser.LogEntry) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	window, _ := d.windowLocked(chatID)
+	if window <= 0 {
+		return true
+	}
+
+	hash, _ := fingerprint.Of(entry)
+	k := dedupKey{chatID: chatID, key: hash}
+	if g, ok := d.groups[k]; ok {
+		g.summary.count++
+		g.summary.last = entry.Timestamp
+		return false
+	}
+
+	g := &repeatGroup{summary: repeatSummary{
+		entry:  entry,
+		count:  1,
+		first:  entry.Timestamp,
+		last:   entry.Timestamp,
+		window: window,
+	}}
+	g.timer = time.AfterFunc(window, func() { d.close(k, g) })
+	d.groups[k] = g
+	return true
+}
+
+func (d *DedupManager) close(k dedupKey, g *repeatGroup) {
+	d.mu.Lock()
+	if d.groups[k] != g {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.groups, k)
+	d.mu.Unlock()
+
+	if g.summary.count > 1 {
+		d.flush(k.chatID, g.summary)
+	}
+}
+
+// Stop closes all windows early, reporting their repeats.
+func (d *DedupManager) Stop() {
+	d.mu.Lock()
+	groups := d.groups
+	d.groups = make(map[dedupKey]*repeatGroup)
+	d.mu.Unlock()
+
+	for k, g := range groups {
+		if g.timer.Stop() && g.summary.count > 1 {
+			d.flush(k.chatID, g.summary)
+		}
+	}
+}
+
+// SetDedupWindow sets the dedup window of chats without their own.
+func (b *Bot) SetDedupWindow(window time.Duration) {
+	b.dedup.SetDefaultWindow(window)
+}
+
+func (b *Bot) sendRepeatSummary(chatID int64, summary repeatSummary) {
+	_ = b.deliver(chatID, b.formatter.FormatRepeatSummary(summary))
+}
+
+func (b *Bot) handleDedupCommand(_ *th.Context, update telego.Update) error {
+	if update.Message == nil {
+		return nil
+	}
+
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.Text)
+
+	if len(args) < 2 {
+		window, custom := b.dedup.ChatWindow(chatID)
+		current := "выключено"
+		if window > 0 {
+			current = formatDuration(window)
+		}
+		if !custom {
+			current += " (по умолчанию)"
+		}
+		msg := "<b>Подавление повторов:</b> " + current +
+			"\n\nИзменить: <code>/dedup 5m</code>, выключить: <code>/dedup off</code>, " +
+			"по умолчанию: <code>/dedup default</code>."
+		return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
+	}
+
+	var window time.Duration
+	switch strings.ToLower(args[1]) {
+	case "off":
+		window = -1
+	case "default":
+	default:
+		var err error
+		if window, err = time.ParseDuration(args[1]); err != nil || window <= 0 {
+			msg := "Неверное окно. Примеры: <code>/dedup 30s</code>, <code>/dedup 5m</code>, " +
+				"<code>/dedup 1h</code>."
+			return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
+		}
+	}
+
+	if b.db != nil {
+		if err := b.db.SetChatDedupWindow(chatID, window); err != nil {
+			b.sendErrorResponse(chatID, "dedup change", err)
+			return nil
+		}
+	}
+	b.dedup.SetChatWindow(chatID, window)
+
+	var msg string
+	switch {
+	case window < 0:
+		msg = "<b>Подавление повторов выключено</b>\n\nБот будет присылать каждую запись."
+	case window == 0:
+		msg = "<b>Окно сброшено</b>\n\nИспользуется окно по умолчанию."
+	default:
+		msg = "<b>Окно изменено</b>\n\nПовторы одной записи в течение " + formatDuration(window) +
+			" будут приходить одним сообщением со счетчиком."
+	}
+	if err := b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard()); err != nil {
+		log.Printf("Failed to send dedup status to chat %d: %v", chatID, err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package telegram
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func dedupEntry(msg string, at time.Time) parser.LogEntry {
+	return parser.LogEntry{
+		Timestamp: at,
+		Level:     parser.LevelError,
+		Message:   []byte(msg),
+		Source:    "api",
+	}
+}
+
+func TestDedupManager_CountsRepeats(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		summaries = make(map[int64]repeatSummary)
+	)
+	d := NewDedupManager(50*time.Millisecond, nil, func(chatID int64, s repeatSummary) {
+		mu.Lock()
+		summaries[chatID] = s
+		mu.Unlock()
+	})
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.True(t, d.Allow(1, dedupEntry("db timeout after 30s (conn 17)", start)))
+	require.True(t, d.Allow(2, dedupEntry("db timeout after 30s (conn 17)", start)))
+	for i := 1; i <= 3; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		require.False(t, d.Allow(1, dedupEntry("db timeout after 31s (conn 4)", at)))
+	}
+	require.True(t, d.Allow(1, dedupEntry("disk full", start)))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(summaries) > 0
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, summaries, 1, "chats without repeats get no summary")
+	s := summaries[1]
+	require.Equal(t, 4, s.count)
+	require.Equal(t, start, s.first)
+	require.Equal(t, start.Add(3*time.Second), s.last)
+
+	// the window closed, so the entry goes out again
+	require.True(t, d.Allow(1, dedupEntry("db timeout after 30s (conn 17)", start)))
+}
+
+func TestDedupManager_KeysByFingerprint(t *testing.T) {
+	d := NewDedupManager(time.Minute, nil, func(int64, repeatSummary) {})
+	defer d.Stop()
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.True(t, d.Allow(1, dedupEntry("request req-8f3a2c1d9e from 10.0.0.7 failed", start)))
+	require.False(t, d.Allow(1, dedupEntry("request req-1b2c3d4e5f from 10.0.0.9 failed", start)),
+		"entries with the same fingerprint are repeats")
+
+	warn := dedupEntry("request req-8f3a2c1d9e from 10.0.0.7 failed", start)
+	warn.Level = parser.LevelWarn
+	require.True(t, d.Allow(1, warn), "another level is another fingerprint")
+}
+
+func TestDedupManager_ChatWindows(t *testing.T) {
+	d := NewDedupManager(time.Minute, map[int64]time.Duration{2: -1}, func(int64, repeatSummary) {})
+	defer d.Stop()
+
+	entry := dedupEntry("boom", time.Now())
+	require.True(t, d.Allow(1, entry))
+	require.False(t, d.Allow(1, entry))
+
+	// dedup is off for chat 2
+	require.True(t, d.Allow(2, entry))
+	require.True(t, d.Allow(2, entry))
+
+	window, custom := d.ChatWindow(2)
+	require.Zero(t, window)
+	require.True(t, custom)
+
+	d.SetChatWindow(2, 0)
+	window, custom = d.ChatWindow(2)
+	require.Equal(t, time.Minute, window)
+	require.False(t, custom)
+}
+
+func TestDedupManager_StopFlushesRepeats(t *testing.T) {
+	var flushed []int64
+	d := NewDedupManager(time.Hour, nil, func(chatID int64, s repeatSummary) {
+		flushed = append(flushed, chatID)
+	})
+
+	entry := dedupEntry("boom", time.Now())
+	d.Allow(1, entry)
+	d.Allow(1, entry)
+	d.Allow(2, entry)
+	d.Stop()
+
+	require.Equal(t, []int64{1}, flushed)
+}
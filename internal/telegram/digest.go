@@ -0,0 +1,281 @@
+package telegram
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+	"github.com/kxrxh/logram/internal/schedule"
+)
+
+const (
+	// digestCheckInterval is how often digests are checked for being due.
+	digestCheckInterval = 30 * time.Second
+	// digestTopN is how many of the most frequent messages a digest lists.
+	digestTopN = 5
+	// digestMaxMessages bounds the distinct messages counted per chat.
+	digestMaxMessages = 1000
+	// digestTopRules is how many of the most matched rules a digest lists.
+	digestTopRules = 10
+	// digestMaxRules bounds the distinct rules counted per chat.
+	digestMaxRules = 100
+	// digestMaxNew bounds the new fingerprints listed per digest.
+	digestMaxNew = 10
+	// digestTemplateLen is how much of a message a digest shows.
+	digestTemplateLen = 200
+)
+
+// Delivery modes of a chat.
+const (
+	DeliveryRealtime = "realtime"
+	DeliveryBatch    = "batch"
+	DeliveryDigest   = "digest"
+)
+
+// defaultDigestSchedule is used by /batch digest without a schedule.
+const defaultDigestSchedule = "daily"
+
+// DigestManager collects the entries of digest chats and sends each chat a
+// report on its schedule. What is collected is kept in memory only, so a
+// restart loses it and starts new reports.
+type DigestManager struct {
+	mu    sync.Mutex
+	chats map[int64]*chatDigest
+	now   func() time.Time
+
+	send func(chatID int64, report digestReport)
+}
+
+type chatDigest struct {
+	spec     string
+	schedule schedule.Schedule
+	next     time.Time
+	report   digestReport
+}
+
+// digestReport sums up the entries of a chat between two digests.
+type digestReport struct {
+	from, to time.Time
+	total    int
+	levels   map[parser.LogLevel]int
+	// rules counts entries by rule, up to digestMaxRules.
+	rules map[string]int
+	// messages counts entries by fingerprint, up to digestMaxMessages.
+	messages map[string]*digestMessage
+	// fresh are the first of the fingerprints seen for the first time;
+	// freshCount counts all of them.
+	fresh      []*digestMessage
+	freshCount int
+}
+
+type digestMessage struct {
+	template string
+	source   string
+	level    parser.LogLevel
+	count    int
+}
+
+func newDigestReport(from time.Time) digestReport {
+	return digestReport{
+		from:     from,
+		levels:   make(map[parser.LogLevel]int),
+		rules:    make(map[string]int),
+		messages: make(map[string]*digestMessage),
+	}
+}
+
+// top returns the n most frequent messages.
+func (r digestReport) top(n int) []*digestMessage {
+	msgs := make([]*digestMessage, 0, len(r.messages))
+	for _, m := range r.messages {
+		msgs = append(msgs, m)
+	}
+	slices.SortFunc(msgs, func(a, b *digestMessage) int {
+		return cmp.Or(cmp.Compare(b.count, a.count), cmp.Compare(a.template, b.template))
+	})
+	return msgs[:min(n, len(msgs))]
+}
+
+// topRules returns the n most matched rules.
+func (r digestReport) topRules(n int) []string {
+	rules := slices.SortedFunc(maps.Keys(r.rules), func(a, b string) int {
+		return cmp.Or(cmp.Compare(r.rules[b], r.rules[a]), cmp.Compare(a, b))
+	})
+	return rules[:min(n, len(rules))]
+}
+
+func NewDigestManager(send func(chatID int64, report digestReport)) *DigestManager {
+	return &DigestManager{
+		chats: make(map[int64]*chatDigest),
+		now:   time.Now,
+		send:  send,
+	}
+}
+
+// Set puts the chat on a digest schedule, keeping what it collected so far.
+func (d *DigestManager) Set(chatID int64, spec string) error {
+	s, err := schedule.Parse(spec)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	c, ok := d.chats[chatID]
+	if !ok {
+		c = &chatDigest{report: newDigestReport(now)}
+		d.chats[chatID] = c
+	}
+	c.spec = spec
+	c.schedule = s
+	c.next = s.Next(now)
+	return nil
+}
+
+// Remove takes the chat off digests, dropping what it collected.
+func (d *DigestManager) Remove(chatID int64) {
+	d.mu.Lock()
+	delete(d.chats, chatID)
+	d.mu.Unlock()
+}
+
+// Schedule returns the chat's digest schedule, if it gets digests.
+func (d *DigestManager) Schedule(chatID int64) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.chats[chatID]
+	if !ok {
+		return "", false
+	}
+	return c.spec, true
+}
+
+// Add counts an entry for the chat's next digest, if it gets digests. rule
+// is the chat's rule that matched, if any; hash and template are the entry's
+// fingerprint, and isNew whether it was just seen for the first time.
+func (d *DigestManager) Add(
+	chatID int64,
+	rule string,
+	entry parser.LogEntry,
+	hash, template string,
+	isNew bool,
+) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.chats[chatID]
+	if !ok {
+		return
+	}
+
+	r := &c.report
+	r.total++
+	r.levels[entry.Level]++
+	if _, ok := r.rules[rule]; rule != "" && (ok || len(r.rules) < digestMaxRules) {
+		r.rules[rule]++
+	}
+
+	m, ok := r.messages[hash]
+	if !ok {
+		m = &digestMessage{template: template, source: entry.Source, level: entry.Level}
+		if len(r.messages) < digestMaxMessages {
+			r.messages[hash] = m
+		}
+	}
+	m.count++
+
+	if isNew {
+		r.freshCount++
+		if len(r.fresh) < digestMaxNew {
+			r.fresh = append(r.fresh, m)
+		}
+	}
+}
+
+// Check sends the digests that are due.
+func (d *DigestManager) Check() {
+	now := d.now()
+
+	type due struct {
+		chatID int64
+		report digestReport
+	}
+	var reports []due
+
+	d.mu.Lock()
+	for chatID, c := range d.chats {
+		if c.next.IsZero() || now.Before(c.next) {
+			continue
+		}
+		c.report.to = now
+		reports = append(reports, due{chatID, c.report})
+		c.report = newDigestReport(now)
+		c.next = c.schedule.Next(now)
+	}
+	d.mu.Unlock()
+
+	for _, r := range reports {
+		d.send(r.chatID, r.report)
+	}
+}
+
+// Run checks for due digests every interval until ctx is done.
+func (d *DigestManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.Check()
+		}
+	}
+}
+
+func (b *Bot) sendDigest(chatID int64, report digestReport) {
+	if err := b.client.SendMessageHTML(chatID, b.formatter.FormatDigest(report)); err != nil {
+		log.Printf("Failed to send digest to chat %d: %v", chatID, err)
+	}
+}
+
+// deliveryMode returns how the chat receives entries.
+func (b *Bot) deliveryMode(chatID int64) string {
+	if _, ok := b.digests.Schedule(chatID); ok {
+		return DeliveryDigest
+	}
+	if b.batchManager.IsEnabled(chatID) {
+		return DeliveryBatch
+	}
+	return DeliveryRealtime
+}
+
+// setDelivery switches the chat to a delivery mode; spec is the digest
+// schedule.
+func (b *Bot) setDelivery(chatID int64, mode, spec string) error {
+	if mode == DeliveryDigest {
+		if _, err := schedule.Parse(spec); err != nil {
+			return err
+		}
+	} else {
+		spec = ""
+	}
+
+	if b.db != nil {
+		if err := b.db.SetChatDelivery(chatID, mode, spec); err != nil {
+			return err
+		}
+	}
+
+	b.batchManager.SetEnabled(chatID, mode == DeliveryBatch)
+	if mode == DeliveryDigest {
+		return b.digests.Set(chatID, spec)
+	}
+	b.digests.Remove(chatID)
+	return nil
+}
@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestManager_CollectsAndSendsOnSchedule(t *testing.T) {
+	reports := make(map[int64]digestReport)
+	d := NewDigestManager(func(chatID int64, r digestReport) { reports[chatID] = r })
+	now := time.Date(2026, 1, 2, 10, 17, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	require.NoError(t, d.Set(1, "hourly"))
+	require.Error(t, d.Set(2, "sometimes"))
+	spec, ok := d.Schedule(1)
+	require.True(t, ok)
+	require.Equal(t, "hourly", spec)
+
+	entry := dedupEntry("db timeout", now)
+	for range 3 {
+		d.Add(1, "db", entry, "h1", "db timeout", false)
+	}
+	warn := parser.LogEntry{Level: parser.LevelWarn, Source: "api", Message: []byte("slow")}
+	d.Add(1, "", warn, "h2", "slow", true)
+	d.Add(2, "db", entry, "h1", "db timeout", false)
+
+	now = now.Add(30 * time.Minute)
+	d.Check()
+	require.Empty(t, reports, "not due before 11:00")
+
+	now = time.Date(2026, 1, 2, 11, 0, 10, 0, time.UTC)
+	d.Check()
+	require.Len(t, reports, 1)
+	r := reports[1]
+	require.Equal(t, 4, r.total)
+	require.Equal(t, map[parser.LogLevel]int{parser.LevelError: 3, parser.LevelWarn: 1}, r.levels)
+	require.Equal(t, map[string]int{"db": 3}, r.rules)
+	top := r.top(digestTopN)
+	require.Len(t, top, 2)
+	require.Equal(t, "db timeout", top[0].template)
+	require.Equal(t, 3, top[0].count)
+	require.Equal(t, 1, r.freshCount)
+	require.Equal(t, "slow", r.fresh[0].template)
+
+	// the next digest starts empty
+	now = time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	d.Check()
+	require.Equal(t, 0, reports[1].total)
+	require.Equal(t, time.Date(2026, 1, 2, 11, 0, 10, 0, time.UTC), reports[1].from)
+
+	d.Remove(1)
+	_, ok = d.Schedule(1)
+	require.False(t, ok)
+}
+
+func TestDigestManager_BoundsRules(t *testing.T) {
+	var report digestReport
+	d := NewDigestManager(func(_ int64, r digestReport) { report = r })
+	require.NoError(t, d.Set(1, "hourly"))
+
+	entry := dedupEntry("db timeout", time.Now())
+	for i := range digestMaxRules + 5 {
+		d.Add(1, fmt.Sprintf("rule %d", i), entry, "h1", "db timeout", false)
+	}
+	d.Add(1, "rule 0", entry, "h1", "db timeout", false)
+
+	d.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	d.Check()
+	require.Len(t, report.rules, digestMaxRules)
+	require.Equal(t, 2, report.rules["rule 0"], "rules counted before the bound keep counting")
+	require.Equal(t, digestMaxRules+6, report.total)
+}
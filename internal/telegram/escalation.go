@@ -0,0 +1,388 @@
+package telegram
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kxrxh/logram/internal/database"
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+)
+
+const (
+	// escalationCheckInterval is how often open incidents are checked for
+	// tiers that are due.
+	escalationCheckInterval = 15 * time.Second
+	// escalationOff is the stored policy of chats that turned escalation off.
+	escalationOff = "off"
+)
+
+var (
+	// ErrInvalidEscalation is returned for escalation policies that can't be
+	// read.
+	ErrInvalidEscalation = errors.New("invalid escalation policy")
+	// ErrEscalationTarget is returned for a chat's own policy that notifies a
+	// chat no configured policy notifies.
+	ErrEscalationTarget = errors.New("escalation target not configured")
+)
+
+// EscalationTier notifies Chats about an incident that is still not
+// acknowledged After it was opened.
+type EscalationTier struct {
+	After time.Duration
+	Chats []int64
+}
+
+// ParseEscalationPolicy reads tiers written as a delay followed by chat IDs,
+// such as "10m -1001234567890 30m 111 222". Delays must grow from tier to
+// tier.
+func ParseEscalationPolicy(spec string) ([]EscalationTier, error) {
+	var tiers []EscalationTier
+	for _, field := range strings.Fields(spec) {
+		if chatID, err := strconv.ParseInt(field, 10, 64); err == nil {
+			if len(tiers) == 0 {
+				return nil, fmt.Errorf("%w: chat %d before a delay", ErrInvalidEscalation, chatID)
+			}
+			last := &tiers[len(tiers)-1]
+			last.Chats = append(last.Chats, chatID)
+			continue
+		}
+
+		after, err := time.ParseDuration(field)
+		if err != nil || after <= 0 {
+			return nil, fmt.Errorf("%w: bad delay or chat %q", ErrInvalidEscalation, field)
+		}
+		tiers = append(tiers, EscalationTier{After: after})
+	}
+	if err := validateEscalationPolicy(tiers); err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+func validateEscalationPolicy(tiers []EscalationTier) error {
+	if len(tiers) == 0 {
+		return fmt.Errorf("%w: no tiers", ErrInvalidEscalation)
+	}
+	for i, t := range tiers {
+		if len(t.Chats) == 0 {
+			return fmt.Errorf("%w: tier %d has no chats", ErrInvalidEscalation, i+1)
+		}
+		if t.After <= 0 || i > 0 && t.After <= tiers[i-1].After {
+			return fmt.Errorf("%w: tier %d must come later than the one before",
+				ErrInvalidEscalation, i+1)
+		}
+	}
+	return nil
+}
+
+// formatEscalationPolicy writes tiers the way ParseEscalationPolicy reads them.
+func formatEscalationPolicy(tiers []EscalationTier) string {
+	var parts []string
+	for _, t := range tiers {
+		parts = append(parts, formatDuration(t.After))
+		for _, chatID := range t.Chats {
+			parts = append(parts, strconv.FormatInt(chatID, 10))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// escalation is a tier of a policy that came due for an incident.
+type escalation struct {
+	incident database.Incident
+	// tier counts from 1.
+	tier int
+	EscalationTier
+}
+
+// Escalate records the tiers of each open incident's policy that are due and
+// returns them, oldest incident first. Acknowledged, resolved and muted
+// incidents aren't escalated. As the tiers reached are saved with the
+// incident, a restart picks up where it left off.
+func (m *IncidentManager) Escalate(policy func(chatID int64) []EscalationTier) []escalation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+
+	var due []escalation
+	for _, inc := range m.byID {
+		if inc.State != database.IncidentOpen || now.Before(inc.MutedUntil) {
+			continue
+		}
+		tiers := policy(inc.ChatID)
+		reached := inc.Escalations
+		for reached < len(tiers) && now.Sub(inc.OpenedAt) >= tiers[reached].After {
+			reached++
+		}
+		if reached == inc.Escalations {
+			continue
+		}
+
+		updated := *inc
+		updated.Escalations = reached
+		if err := m.store.SaveIncident(&updated); err != nil {
+			log.Printf("Failed to save escalation of incident %d: %v", inc.ID, err)
+			continue
+		}
+		for i := inc.Escalations; i < reached; i++ {
+			due = append(due, escalation{incident: updated, tier: i + 1, EscalationTier: tiers[i]})
+		}
+		*inc = updated
+	}
+
+	slices.SortStableFunc(due, func(a, b escalation) int {
+		return cmp.Compare(a.incident.ID, b.incident.ID)
+	})
+	return due
+}
+
+// EscalationManager holds the escalation policies of chats and sends the
+// tiers of open incidents as they come due. Only chats with a policy escalate,
+// and only to the chats the configured policies notify.
+type EscalationManager struct {
+	incidents *IncidentManager
+	send      func(chatID int64, e escalation)
+
+	mu sync.RWMutex
+	// defaults are the configured policies by chat.
+	defaults map[int64][]EscalationTier
+	// targets are the chats the configured policies notify.
+	targets map[int64]bool
+	// own are the policies set in chats; an empty one turns escalation off.
+	own map[int64][]EscalationTier
+}
+
+// NewEscalationManager escalates the incidents of incidents, which may be nil
+// when there are none.
+func NewEscalationManager(
+	incidents *IncidentManager,
+	own map[int64][]EscalationTier,
+	send func(chatID int64, e escalation),
+) *EscalationManager {
+	if own == nil {
+		own = make(map[int64][]EscalationTier)
+	}
+	return &EscalationManager{
+		incidents: incidents,
+		send:      send,
+		defaults:  make(map[int64][]EscalationTier),
+		targets:   make(map[int64]bool),
+		own:       own,
+	}
+}
+
+// SetDefaults sets the configured policies by chat.
+func (m *EscalationManager) SetDefaults(policies map[int64][]EscalationTier) {
+	targets := make(map[int64]bool)
+	for _, tiers := range policies {
+		for _, t := range tiers {
+			for _, chatID := range t.Chats {
+				targets[chatID] = true
+			}
+		}
+	}
+
+	m.mu.Lock()
+	m.defaults = policies
+	m.targets = targets
+	m.mu.Unlock()
+}
+
+// CheckTargets returns ErrEscalationTarget if tiers notify a chat that no
+// configured policy notifies.
+func (m *EscalationManager) CheckTargets(tiers []EscalationTier) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, t := range tiers {
+		for _, chatID := range t.Chats {
+			if !m.targets[chatID] {
+				return fmt.Errorf("%w: chat %d", ErrEscalationTarget, chatID)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *EscalationManager) isTarget(chatID int64) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.targets[chatID]
+}
+
+// SetChatPolicy sets the chat's own policy; empty turns escalation off.
+func (m *EscalationManager) SetChatPolicy(chatID int64, tiers []EscalationTier) {
+	m.mu.Lock()
+	m.own[chatID] = tiers
+	m.mu.Unlock()
+}
+
+// ResetChatPolicy makes the chat use the configured policy.
+func (m *EscalationManager) ResetChatPolicy(chatID int64) {
+	m.mu.Lock()
+	delete(m.own, chatID)
+	m.mu.Unlock()
+}
+
+// Policy returns the tiers that escalate the chat's incidents and whether
+// they are the chat's own. Chats without a policy don't escalate.
+func (m *EscalationManager) Policy(chatID int64) ([]EscalationTier, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if tiers, ok := m.own[chatID]; ok {
+		return tiers, true
+	}
+	return m.defaults[chatID], false
+}
+
+// Check sends the escalations that are due.
+func (m *EscalationManager) Check() {
+	if m.incidents == nil {
+		return
+	}
+	due := m.incidents.Escalate(func(chatID int64) []EscalationTier {
+		tiers, _ := m.Policy(chatID)
+		return tiers
+	})
+	for _, e := range due {
+		for _, chatID := range e.Chats {
+			// A chat's own policy may predate a config that dropped a target
+			if !m.isTarget(chatID) {
+				log.Printf("Skipped escalation of incident %d to unconfigured chat %d",
+					e.incident.ID, chatID)
+				continue
+			}
+			m.send(chatID, e)
+		}
+	}
+}
+
+// Run checks for due escalations every interval until ctx is done.
+func (m *EscalationManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check()
+		}
+	}
+}
+
+// SetEscalationPolicies sets the configured escalation policies by chat.
+// Nothing changes if one is invalid.
+func (b *Bot) SetEscalationPolicies(policies map[int64][]EscalationTier) error {
+	for chatID, tiers := range policies {
+		if chatID == 0 {
+			return fmt.Errorf("%w: a policy must name its chat", ErrInvalidEscalation)
+		}
+		if err := validateEscalationPolicy(tiers); err != nil {
+			return fmt.Errorf("chat %d: %w", chatID, err)
+		}
+	}
+	b.escalations.SetDefaults(policies)
+	return nil
+}
+
+// sendEscalation sends an incident to a chat of its escalation tier, with the
+// incident's buttons.
+func (b *Bot) sendEscalation(chatID int64, e escalation) {
+	_, err := b.client.SendMessageHTMLWithInlineKeyboard(
+		chatID,
+		b.formatter.FormatEscalation(e),
+		incidentKeyboard(e.incident),
+	)
+	if err != nil {
+		log.Printf("Failed to escalate incident %d to chat %d: %v", e.incident.ID, chatID, err)
+	}
+}
+
+func (b *Bot) handleEscalateCommand(_ *th.Context, update telego.Update) error {
+	if update.Message == nil {
+		return nil
+	}
+
+	chatID := update.Message.Chat.ID
+	if b.incidents == nil {
+		return b.client.SendMessageHTML(
+			chatID,
+			"База данных не настроена, инциденты и эскалация недоступны.",
+		)
+	}
+
+	_, spec, _ := strings.Cut(update.Message.Text, " ")
+	spec = strings.TrimSpace(spec)
+
+	if spec == "" {
+		tiers, own := b.escalations.Policy(chatID)
+		msg := b.formatter.FormatEscalationPolicy(tiers, own) +
+			"\n\nИзменить: <code>/escalate 10m -1001234567890 30m 111 222</code> — через 10m " +
+			"без принятия инцидент уйдет в первый чат, через 30m — пользователям 111 и 222. " +
+			"Выключить: <code>/escalate off</code>, по умолчанию: <code>/escalate default</code>."
+		return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
+	}
+
+	var tiers []EscalationTier
+	stored := spec
+	switch strings.ToLower(spec) {
+	case escalationOff:
+		stored = escalationOff
+	case "default":
+		stored = ""
+	default:
+		var err error
+		if tiers, err = ParseEscalationPolicy(spec); err != nil {
+			msg := "Неверная политика: " + html.EscapeString(err.Error()) +
+				"\n\nПример: <code>/escalate 10m -1001234567890 30m 111 222</code>."
+			return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
+		}
+		if err := b.escalations.CheckTargets(tiers); err != nil {
+			msg := "Неверная политика: " + html.EscapeString(err.Error()) +
+				"\n\nЭскалировать можно только в чаты из настроенных политик " +
+				"(<code>escalation.policies</code>)."
+			return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
+		}
+		stored = formatEscalationPolicy(tiers)
+	}
+
+	if err := b.db.SetChatEscalationPolicy(chatID, stored); err != nil {
+		b.sendErrorResponse(chatID, "escalation change", err)
+		return nil
+	}
+	if stored == "" {
+		b.escalations.ResetChatPolicy(chatID)
+	} else {
+		b.escalations.SetChatPolicy(chatID, tiers)
+	}
+
+	tiers, own := b.escalations.Policy(chatID)
+	msg := b.formatter.FormatEscalationPolicy(tiers, own)
+	if err := b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard()); err != nil {
+		log.Printf("Failed to send escalation status to chat %d: %v", chatID, err)
+	}
+	return nil
+}
+
+// loadEscalationPolicy reads a chat's stored policy; ok is false for chats
+// that use the configured one.
+func loadEscalationPolicy(stored string) (tiers []EscalationTier, ok bool, err error) {
+	switch stored {
+	case "":
+		return nil, false, nil
+	case escalationOff:
+		return nil, true, nil
+	}
+	tiers, err = ParseEscalationPolicy(stored)
+	return tiers, err == nil, err
+}
@@ -0,0 +1,169 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEscalationPolicy(t *testing.T) {
+	tiers, err := ParseEscalationPolicy("10m -1001234567890  30m 111 222")
+	require.NoError(t, err)
+	require.Equal(t, []EscalationTier{
+		{After: 10 * time.Minute, Chats: []int64{-1001234567890}},
+		{After: 30 * time.Minute, Chats: []int64{111, 222}},
+	}, tiers)
+	require.Equal(t, "10m -1001234567890 30m 111 222", formatEscalationPolicy(tiers))
+
+	for _, spec := range []string{
+		"",
+		"111 10m",
+		"10m",
+		"10m 111 5m 222",
+		"10m 111 10m 222",
+		"soon 111",
+		"-5m 111",
+	} {
+		_, err := ParseEscalationPolicy(spec)
+		require.ErrorIs(t, err, ErrInvalidEscalation, spec)
+	}
+}
+
+func TestIncidentManager_EscalatesOpenIncidents(t *testing.T) {
+	store := newMemIncidentStore()
+	now := time.Now()
+	m := newTestIncidentManager(t, store, &now)
+	tiers := []EscalationTier{
+		{After: 10 * time.Minute, Chats: []int64{2}},
+		{After: 30 * time.Minute, Chats: []int64{3, 4}},
+	}
+	policy := func(chatID int64) []EscalationTier {
+		require.Equal(t, int64(1), chatID)
+		return tiers
+	}
+
+	first, err := m.Open(1, "fp:a", "a")
+	require.NoError(t, err)
+	second, err := m.Open(1, "fp:b", "b")
+	require.NoError(t, err)
+	require.Empty(t, m.Escalate(policy))
+
+	now = now.Add(10 * time.Minute)
+	due := m.Escalate(policy)
+	require.Len(t, due, 2)
+	require.Equal(t, first.ID, due[0].incident.ID)
+	require.Equal(t, 1, due[0].tier)
+	require.Equal(t, []int64{2}, due[0].Chats)
+	require.Equal(t, 1, store.incidents[first.ID].Escalations)
+	require.Empty(t, m.Escalate(policy), "a tier is sent once")
+
+	_, err = m.Apply(second.ID, incidentActionAck, "@alice")
+	require.NoError(t, err)
+
+	// The second tier comes due while logram is down.
+	now = now.Add(30 * time.Minute)
+	reloaded := newTestIncidentManager(t, store, &now)
+	due = reloaded.Escalate(policy)
+	require.Len(t, due, 1, "acknowledged incidents aren't escalated")
+	require.Equal(t, first.ID, due[0].incident.ID)
+	require.Equal(t, 2, due[0].tier)
+	require.Equal(t, []int64{3, 4}, due[0].Chats)
+	require.Empty(t, reloaded.Escalate(policy), "the policy is used up")
+}
+
+func TestIncidentManager_MutedIncidentsWaitToEscalate(t *testing.T) {
+	store := newMemIncidentStore()
+	now := time.Now()
+	m := newTestIncidentManager(t, store, &now)
+	policy := func(int64) []EscalationTier {
+		return []EscalationTier{{After: time.Minute, Chats: []int64{2}}}
+	}
+
+	inc, err := m.Open(1, "fp:a", "a")
+	require.NoError(t, err)
+	_, err = m.Apply(inc.ID, incidentActionMute, "@alice")
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	require.Empty(t, m.Escalate(policy))
+
+	now = now.Add(incidentMuteFor)
+	require.Len(t, m.Escalate(policy), 1, "escalation resumes after the mute")
+}
+
+func TestEscalationManager_PolicyPrecedence(t *testing.T) {
+	perChat := []EscalationTier{{After: time.Minute, Chats: []int64{20}}}
+	own := []EscalationTier{{After: time.Minute, Chats: []int64{30}}}
+
+	m := NewEscalationManager(nil, nil, nil)
+	m.SetDefaults(map[int64][]EscalationTier{1: perChat})
+
+	tiers, isOwn := m.Policy(1)
+	require.Equal(t, perChat, tiers)
+	require.False(t, isOwn)
+	tiers, _ = m.Policy(2)
+	require.Empty(t, tiers, "chats without a policy don't escalate")
+
+	m.SetChatPolicy(1, own)
+	tiers, isOwn = m.Policy(1)
+	require.Equal(t, own, tiers)
+	require.True(t, isOwn)
+
+	m.SetChatPolicy(1, nil)
+	tiers, isOwn = m.Policy(1)
+	require.Empty(t, tiers, "off")
+	require.True(t, isOwn)
+
+	m.ResetChatPolicy(1)
+	tiers, _ = m.Policy(1)
+	require.Equal(t, perChat, tiers)
+
+	m.Check()
+}
+
+func TestEscalationManager_SendsToEveryChatOfTier(t *testing.T) {
+	store := newMemIncidentStore()
+	now := time.Now()
+	incidents := newTestIncidentManager(t, store, &now)
+
+	sent := make(map[int64]int)
+	m := NewEscalationManager(incidents, nil, func(chatID int64, e escalation) {
+		sent[chatID] = e.tier
+	})
+	m.SetDefaults(map[int64][]EscalationTier{
+		1: {{After: time.Minute, Chats: []int64{2, 3}}},
+	})
+
+	_, err := incidents.Open(1, "fp:a", "a")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	m.Check()
+	require.Equal(t, map[int64]int{2: 1, 3: 1}, sent)
+}
+
+func TestEscalationManager_OnlyConfiguredTargets(t *testing.T) {
+	store := newMemIncidentStore()
+	now := time.Now()
+	incidents := newTestIncidentManager(t, store, &now)
+
+	sent := make(map[int64]int)
+	m := NewEscalationManager(incidents, nil, func(chatID int64, e escalation) {
+		sent[chatID] = e.tier
+	})
+	m.SetDefaults(map[int64][]EscalationTier{
+		1: {{After: time.Minute, Chats: []int64{2, 3}}},
+	})
+
+	require.NoError(t, m.CheckTargets([]EscalationTier{{After: time.Minute, Chats: []int64{3}}}))
+	err := m.CheckTargets([]EscalationTier{{After: time.Minute, Chats: []int64{2, 99}}})
+	require.ErrorIs(t, err, ErrEscalationTarget)
+
+	// A policy stored before the config changed doesn't reach dropped chats
+	m.SetChatPolicy(4, []EscalationTier{{After: time.Minute, Chats: []int64{2, 99}}})
+	_, err = incidents.Open(4, "fp:a", "a")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	m.Check()
+	require.Equal(t, map[int64]int{2: 1}, sent)
+}
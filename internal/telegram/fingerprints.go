@@ -0,0 +1,55 @@
+package telegram
+
+import (
+	"log"
+
+	"github.com/kxrxh/logram/internal/fingerprint"
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+)
+
+// SetFingerprints lets chats limit themselves to entries with new
+// fingerprints, and counts every entry sent through the bot.
+func (b *Bot) SetFingerprints(t *fingerprint.Tracker) {
+	b.fingerprints = t
+}
+
+func (b *Bot) isNewOnly(chatID int64) bool {
+	b.newOnlyMu.RLock()
+	defer b.newOnlyMu.RUnlock()
+	return b.newOnly[chatID]
+}
+
+func (b *Bot) handleNewOnlyCommand(_ *th.Context, update telego.Update) error {
+	if update.Message == nil {
+		return nil
+	}
+
+	chatID := update.Message.Chat.ID
+	next := !b.isNewOnly(chatID)
+
+	if b.db != nil {
+		if err := b.db.SetChatNewOnly(chatID, next); err != nil {
+			b.sendErrorResponse(chatID, "new only toggle", err)
+			return nil
+		}
+	}
+
+	b.newOnlyMu.Lock()
+	if next {
+		b.newOnly[chatID] = true
+	} else {
+		delete(b.newOnly, chatID)
+	}
+	b.newOnlyMu.Unlock()
+
+	msg := "<b>Только новые ошибки</b>\n\nБот будет присылать запись, только если такая " +
+		"(без учета ID, адресов и чисел) встретилась впервые."
+	if !next {
+		msg = "<b>Все записи</b>\n\nБот снова будет присылать и повторяющиеся записи."
+	}
+	if err := b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard()); err != nil {
+		log.Printf("Failed to send new only status to chat %d: %v", chatID, err)
+	}
+	return nil
+}
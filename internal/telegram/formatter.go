@@ -3,8 +3,16 @@ package telegram
 import (
 	"fmt"
 	"html"
+	"maps"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/kxrxh/logram/internal/anomaly"
+	"github.com/kxrxh/logram/internal/database"
+	"github.com/kxrxh/logram/internal/heartbeat"
 	"github.com/kxrxh/logram/internal/parser"
 )
 
@@ -15,13 +23,155 @@ func NewMessageFormatter() *MessageFormatter {
 }
 
 func (f *MessageFormatter) FormatLogEntry(entry parser.LogEntry) string {
+	if entry.Level == parser.LevelUnknown {
+		return formatRawEntry(entry)
+	}
+
 	levelText := getLevelText(entry.Level)
 	// Telegram `parse_mode=HTML` requires escaping any raw `<`/`&` in user/log content.
-	safeMsg := html.EscapeString(string(entry.Message))
-	return fmt.Sprintf("<b>%s</b> | %s\n<code>%s</code>",
+	safeMsg := escapeBody(string(entry.Message))
+	// Multi-line events, such as stack traces, keep their layout in a block.
+	tag := "code"
+	if strings.Contains(safeMsg, "\n") {
+		tag = "pre"
+	}
+	return fmt.Sprintf("<b>%s</b> | %s%s\n<%s>%s</%s>%s",
 		levelText,
 		entry.Timestamp.Format("02.01.2006 15:04:05"),
-		safeMsg)
+		formatOrigin(entry),
+		tag,
+		safeMsg,
+		tag,
+		formatFields(entry.Fields))
+}
+
+// formatRawEntry shows a line that passed through unparsed: no level, just
+// the read time and the line as is.
+func formatRawEntry(entry parser.LogEntry) string {
+	return fmt.Sprintf("<i>%s</i>%s\n<pre>%s</pre>",
+		entry.Timestamp.Format("02.01.2006 15:04:05"),
+		formatOrigin(entry),
+		escapeBody(string(entry.Message)))
+}
+
+// maxBodyLen bounds the escaped message of an entry, so that the entry fits
+// in one Telegram message (4096 characters) along with its header, fields
+// and an incident or escalation header.
+const maxBodyLen = 3000
+
+// maxFieldsLen bounds the escaped fields of an entry, which come on top of
+// its body; maxFieldLen bounds a single key or value.
+const (
+	maxFieldsLen = 600
+	maxFieldLen  = 200
+)
+
+// escapeBody escapes msg for HTML and keeps the lines that fit in
+// maxBodyLen, noting how many were left out. A first line that is too long
+// on its own is cut, never inside an escape.
+func escapeBody(msg string) string {
+	lines := strings.Split(msg, "\n")
+	var sb strings.Builder
+	for i, line := range lines {
+		safe := html.EscapeString(line)
+		if i > 0 {
+			safe = "\n" + safe
+		}
+		if sb.Len()+len(safe) <= maxBodyLen {
+			sb.WriteString(safe)
+			continue
+		}
+
+		left := len(lines) - i
+		if i == 0 {
+			sb.WriteString(cutEscaped(safe, maxBodyLen) + "…")
+			left--
+		}
+		if left > 0 {
+			fmt.Fprintf(&sb, "\n…и еще строк: %d", left)
+		}
+		break
+	}
+	return sb.String()
+}
+
+// cutEscaped cuts escaped HTML text to at most n bytes without splitting an
+// escape or a UTF-8 character.
+func cutEscaped(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	if i := strings.LastIndexByte(s, '&'); i >= 0 && !strings.Contains(s[i:], ";") {
+		s = s[:i]
+	}
+	for len(s) > 0 {
+		r, size := utf8.DecodeLastRuneInString(s)
+		if r != utf8.RuneError || size != 1 {
+			break
+		}
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// formatFields lists structured fields under the message, sorted by key, as
+// many as fit in maxFieldsLen, noting how many were left out.
+func formatFields(fields map[string]string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n")
+	keys := slices.Sorted(maps.Keys(fields))
+	for i, key := range keys {
+		item := "<i>" + escapeField(key) + "</i>=" + escapeField(fields[key])
+		if i > 0 {
+			item = " " + item
+		}
+		if sb.Len()+len(item) > maxFieldsLen {
+			fmt.Fprintf(&sb, " …и еще полей: %d", len(keys)-i)
+			break
+		}
+		sb.WriteString(item)
+	}
+	return sb.String()
+}
+
+// escapeField escapes a field key or value, cut to maxFieldLen.
+func escapeField(s string) string {
+	safe := html.EscapeString(s)
+	if len(safe) > maxFieldLen {
+		return cutEscaped(safe, maxFieldLen) + "…"
+	}
+	return safe
+}
+
+// formatOrigin describes where the entry was read from, for example
+// " | <i>api</i> (api.log) env=prod".
+func formatOrigin(entry parser.LogEntry) string {
+	if entry.Source == "" && entry.File == "" && len(entry.Labels) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(" |")
+	if entry.Source != "" {
+		sb.WriteString(" <i>")
+		sb.WriteString(html.EscapeString(entry.Source))
+		sb.WriteString("</i>")
+	}
+	if entry.File != "" {
+		sb.WriteString(" (")
+		sb.WriteString(html.EscapeString(filepath.Base(entry.File)))
+		sb.WriteString(")")
+	}
+	for _, key := range slices.Sorted(maps.Keys(entry.Labels)) {
+		sb.WriteString(" ")
+		sb.WriteString(html.EscapeString(key + "=" + entry.Labels[key]))
+	}
+	return sb.String()
 }
 
 func (f *MessageFormatter) FormatSubscriptionStatus(isSubscribed bool) string {
@@ -31,6 +181,285 @@ func (f *MessageFormatter) FormatSubscriptionStatus(isSubscribed bool) string {
 	return "<b>Не подписан</b>\n\nВы не получаете уведомления о логах."
 }
 
+// FormatDeadLetterSummary is the one-line count of rejected lines for /status.
+func (f *MessageFormatter) FormatDeadLetterSummary(stats parser.DeadLetterStats) string {
+	return fmt.Sprintf("Отброшено строк: <b>%d</b> из %d (%.1f%%). Подробнее: /rejected",
+		stats.Rejected, stats.Accepted+stats.Rejected, stats.RejectedRatio()*100)
+}
+
+// FormatDeadLetters lists rejected lines by reason with the latest samples.
+func (f *MessageFormatter) FormatDeadLetters(stats parser.DeadLetterStats) string {
+	var b strings.Builder
+	b.WriteString("<b>Отброшенные строки</b>\n\n")
+	b.WriteString(f.FormatDeadLetterSummary(stats))
+	if stats.Rejected == 0 {
+		return b.String()
+	}
+
+	b.WriteString("\n")
+	for _, reason := range slices.Sorted(maps.Keys(stats.ByReason)) {
+		fmt.Fprintf(&b, "\n%s: %d", deadLetterReasonText(reason), stats.ByReason[reason])
+	}
+
+	b.WriteString("\n\n<b>Последние:</b>")
+	for _, letter := range slices.Backward(stats.Samples) {
+		fmt.Fprintf(&b, "\n<i>%s</i> %s, %s\n<pre>%s</pre>",
+			letter.Time.Format("02.01.2006 15:04:05"),
+			html.EscapeString(letter.Source),
+			deadLetterReasonText(letter.Reason),
+			html.EscapeString(letter.Line))
+	}
+	return b.String()
+}
+
+// FormatDeadLetterAlert warns that too many lines were rejected over window.
+func (f *MessageFormatter) FormatDeadLetterAlert(
+	counts parser.DeadLetterStats,
+	window time.Duration,
+) string {
+	return fmt.Sprintf(
+		"<b>⚠️ Много неразобранных строк</b>\n\nЗа последние %s отброшено %d из %d строк (%.1f%%). "+
+			"Возможно, изменился формат логов. Подробнее: /rejected",
+		formatDuration(window), counts.Rejected, counts.Accepted+counts.Rejected, counts.RejectedRatio()*100)
+}
+
+func deadLetterReasonText(reason string) string {
+	switch reason {
+	case parser.ReasonInvalidFormat:
+		return "неверный формат"
+	case parser.ReasonInvalidTimestamp:
+		return "неверное время"
+	case parser.ReasonEmptyLine:
+		return "пустая строка"
+	default:
+		return "другое"
+	}
+}
+
+// FormatRepeatSummary reports how many times an entry repeated in a window,
+// followed by the entry itself.
+func (f *MessageFormatter) FormatRepeatSummary(summary repeatSummary) string {
+	return fmt.Sprintf("🔁 <b>×%d</b> за последние %s, первое в %s, последнее в %s\n",
+		summary.count,
+		formatDuration(summary.window),
+		summary.first.Format("15:04:05"),
+		summary.last.Format("15:04:05"),
+	) + f.FormatLogEntry(summary.entry)
+}
+
+// FormatThresholdAlert announces a rule crossing its threshold, with its
+// latest match, or dropping back below it.
+func (f *MessageFormatter) FormatThresholdAlert(alert thresholdAlert) string {
+	rule := html.EscapeString(alert.rule)
+	limit := fmt.Sprintf("%d за %s", alert.threshold.Count, formatDuration(alert.threshold.Window))
+	if alert.resolved {
+		return fmt.Sprintf("✅ <b>Порог больше не превышен</b>: <code>%s</code> (%s)\n"+
+			"Длилось %s, совпадений: %d.",
+			rule, limit, formatDuration(alert.duration.Round(time.Second)), alert.total)
+	}
+	return fmt.Sprintf("🚨 <b>Порог превышен</b>: <code>%s</code> (%s)\n\n", rule, limit) +
+		f.FormatLogEntry(alert.entry)
+}
+
+// FormatSilenceEvent announces a source or rule going silent or resuming.
+func (f *MessageFormatter) FormatSilenceEvent(event heartbeat.Event) string {
+	target := heartbeatTargetText(event.Kind, event.Name)
+	if !event.Silent {
+		return fmt.Sprintf("✅ <b>Снова есть записи</b>: %s\nТишина длилась %s.",
+			target, formatDuration(event.Silence.Round(time.Second)))
+	}
+	return fmt.Sprintf("🔇 <b>Нет записей</b>: %s\n"+
+		"Ожидается хотя бы одна запись каждые %s, последняя была в %s.",
+		target, formatDuration(event.Every), event.LastSeen.Format("02.01.2006 15:04:05"))
+}
+
+// FormatHeartbeatStatus lists the watched sources and rules with the time
+// since their last entry.
+func (f *MessageFormatter) FormatHeartbeatStatus(statuses []heartbeat.Status, now time.Time) string {
+	var b strings.Builder
+	b.WriteString("<b>Ожидаемая активность:</b>")
+	for _, s := range statuses {
+		mark := "🟢"
+		if s.Silent {
+			mark = "🔇"
+		}
+		fmt.Fprintf(&b, "\n%s %s: последняя запись %s назад (ожидается каждые %s)",
+			mark, heartbeatTargetText(s.Kind, s.Name),
+			formatDuration(now.Sub(s.LastSeen).Round(time.Second)), formatDuration(s.Every))
+	}
+	return b.String()
+}
+
+func heartbeatTargetText(kind heartbeat.Kind, name string) string {
+	if kind == heartbeat.KindRule {
+		return "правило <code>" + html.EscapeString(name) + "</code>"
+	}
+	return "источник <code>" + html.EscapeString(name) + "</code>"
+}
+
+// FormatAnomalyAlert reports a rule's rate far from its usual rate at that
+// hour.
+func (f *MessageFormatter) FormatAnomalyAlert(alert anomaly.Alert) string {
+	title := "📉 <b>Необычно мало совпадений</b>"
+	if alert.Spike() {
+		title = "📈 <b>Необычно много совпадений</b>"
+	}
+	minutes := alert.Bucket.Minutes()
+	return fmt.Sprintf("%s: правило <code>%s</code>\n"+
+		"За %s с %s: %d (%.1f/мин), обычно в это время около %.1f (%.1f/мин).",
+		title, html.EscapeString(alert.Rule),
+		formatDuration(alert.Bucket), alert.Start.Local().Format("15:04"),
+		alert.Observed, float64(alert.Observed)/minutes, alert.Expected, alert.Expected/minutes)
+}
+
+// FormatDigest sums up a chat's entries between two digests: counts per
+// level and rule, the most frequent messages and the new ones.
+func (f *MessageFormatter) FormatDigest(report digestReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 <b>Сводка</b> с %s по %s\n",
+		report.from.Format("02.01 15:04"), report.to.Format("02.01 15:04"))
+	if report.total == 0 {
+		b.WriteString("\nЗаписей не было.")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Записей: <b>%d</b>\n", report.total)
+
+	b.WriteString("\n<b>По уровням:</b>")
+	levels := slices.SortedFunc(maps.Keys(report.levels), func(a, b parser.LogLevel) int {
+		return b.Severity() - a.Severity()
+	})
+	for _, level := range levels {
+		fmt.Fprintf(&b, "\n%s: %d", digestLevelText(level), report.levels[level])
+	}
+
+	if len(report.rules) > 0 {
+		b.WriteString("\n\n<b>По правилам:</b>")
+		top := report.topRules(digestTopRules)
+		for _, rule := range top {
+			fmt.Fprintf(&b, "\n<code>%s</code>: %d", html.EscapeString(rule), report.rules[rule])
+		}
+		if more := len(report.rules) - len(top); more > 0 {
+			fmt.Fprintf(&b, "\n…и еще %d", more)
+		}
+	}
+
+	b.WriteString("\n\n<b>Частые сообщения:</b>")
+	for i, m := range report.top(digestTopN) {
+		fmt.Fprintf(&b, "\n%d. ×%d %s", i+1, m.count, formatDigestMessage(m))
+	}
+
+	if report.freshCount > 0 {
+		fmt.Fprintf(&b, "\n\n<b>Новые сообщения:</b> %d", report.freshCount)
+		for _, m := range report.fresh {
+			b.WriteString("\n• " + formatDigestMessage(m))
+		}
+		if more := report.freshCount - len(report.fresh); more > 0 {
+			fmt.Fprintf(&b, "\n…и еще %d", more)
+		}
+	}
+	return b.String()
+}
+
+func formatDigestMessage(m *digestMessage) string {
+	origin := digestLevelText(m.level)
+	if m.source != "" {
+		origin += " <i>" + html.EscapeString(m.source) + "</i>"
+	}
+	template := m.template
+	if runes := []rune(template); len(runes) > digestTemplateLen {
+		template = string(runes[:digestTemplateLen]) + "…"
+	}
+	return origin + " <code>" + html.EscapeString(template) + "</code>"
+}
+
+func digestLevelText(level parser.LogLevel) string {
+	if level == parser.LevelUnknown {
+		return "без уровня"
+	}
+	return getLevelText(level)
+}
+
+// FormatIncident shows an incident's alert under its state, with who
+// acknowledged, resolved or muted it.
+func (f *MessageFormatter) FormatIncident(inc database.Incident) string {
+	mark := "🚨"
+	switch inc.State {
+	case database.IncidentAcknowledged:
+		mark = "👀"
+	case database.IncidentResolved:
+		mark = "✅"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s <b>Инцидент #%d</b> · %s\n\n%s",
+		mark, inc.ID, incidentStateText(inc.State), inc.Text)
+	if inc.Count > 1 {
+		fmt.Fprintf(&b, "\n\nПовторов: %d", inc.Count)
+	}
+	if inc.AckedBy != "" {
+		fmt.Fprintf(&b, "\n👀 Принял %s в %s",
+			html.EscapeString(inc.AckedBy), inc.AckedAt.Format("15:04"))
+	}
+	switch {
+	case inc.ResolvedBy != "":
+		fmt.Fprintf(&b, "\n✅ Решил %s в %s",
+			html.EscapeString(inc.ResolvedBy), inc.ResolvedAt.Format("15:04"))
+	case inc.State == database.IncidentResolved:
+		fmt.Fprintf(&b, "\n✅ Решен автоматически в %s: без повторов %s",
+			inc.ResolvedAt.Format("15:04"), formatDuration(incidentIdleFor))
+	}
+	if inc.MutedBy != "" {
+		fmt.Fprintf(&b, "\n🔕 Заглушил %s до %s",
+			html.EscapeString(inc.MutedBy), inc.MutedUntil.Format("15:04"))
+	}
+	return b.String()
+}
+
+// FormatEscalation shows an incident sent to a chat of its escalation tier.
+func (f *MessageFormatter) FormatEscalation(e escalation) string {
+	return fmt.Sprintf(
+		"📟 <b>Эскалация, уровень %d</b>: не принят за %s (чат <code>%d</code>)\n\n%s",
+		e.tier, formatDuration(e.After), e.incident.ChatID, f.FormatIncident(e.incident),
+	)
+}
+
+// FormatEscalationPolicy lists the tiers that escalate a chat's incidents;
+// own tells whether the chat set them rather than the config.
+func (f *MessageFormatter) FormatEscalationPolicy(tiers []EscalationTier, own bool) string {
+	var b strings.Builder
+	b.WriteString("<b>Эскалация</b>")
+	if !own {
+		b.WriteString(" (по умолчанию)")
+	}
+	if len(tiers) == 0 {
+		b.WriteString(": выключена")
+		return b.String()
+	}
+	b.WriteString(":")
+	for i, t := range tiers {
+		chats := make([]string, len(t.Chats))
+		for j, chatID := range t.Chats {
+			chats[j] = fmt.Sprintf("<code>%d</code>", chatID)
+		}
+		fmt.Fprintf(&b, "\n%d. через %s → %s",
+			i+1, formatDuration(t.After), strings.Join(chats, ", "))
+	}
+	return b.String()
+}
+
+// formatDuration drops the zero units time.Duration.String leaves, so 5m0s
+// reads 5m.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
 func (f *MessageFormatter) FormatHelp(commands map[string]Command) string {
 	var helpText strings.Builder
 	helpText.WriteString("<b>Доступные команды:</b>\n\n")
@@ -40,14 +469,24 @@ func (f *MessageFormatter) FormatHelp(commands map[string]Command) string {
 	return helpText.String()
 }
 
+// getLevelText returns the level with a marker that tells levels apart at a
+// glance.
 func getLevelText(level parser.LogLevel) string {
 	switch level {
+	case parser.LevelTrace:
+		return "⚪ TRACE"
 	case parser.LevelDebug:
-		return "DEBUG"
+		return "🔵 DEBUG"
 	case parser.LevelInfo:
-		return "INFO"
+		return "🟢 INFO"
+	case parser.LevelWarn:
+		return "🟡 WARN"
 	case parser.LevelError:
-		return "ERROR"
+		return "🔴 ERROR"
+	case parser.LevelCritical:
+		return "🟣 CRITICAL"
+	case parser.LevelFatal:
+		return "⛔ FATAL"
 	default:
 		return string(level)
 	}
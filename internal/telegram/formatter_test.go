@@ -1,10 +1,15 @@
 package telegram
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/kxrxh/logram/internal/anomaly"
+	"github.com/kxrxh/logram/internal/database"
+	"github.com/kxrxh/logram/internal/heartbeat"
 	"github.com/kxrxh/logram/internal/parser"
 )
 
@@ -27,3 +32,389 @@ func TestMessageFormatter_EscapesHTMLInLogMessage(t *testing.T) {
 		t.Fatalf("expected escaped HTML, got: %s", out)
 	}
 }
+
+func TestMessageFormatter_ShowsSourceFileAndLabels(t *testing.T) {
+	f := NewMessageFormatter()
+
+	entry := parser.LogEntry{
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     parser.LevelError,
+		Message:   []byte("boom"),
+		Source:    "api",
+		File:      "/var/log/app/api.log",
+		Labels:    map[string]string{"host": "web-1", "env": "prod"},
+	}
+
+	out := f.FormatLogEntry(entry)
+
+	want := "<b>🔴 ERROR</b> | 02.01.2026 03:04:05 | <i>api</i> (api.log) env=prod host=web-1\n"
+	if !strings.HasPrefix(out, want) {
+		t.Fatalf("expected header %q, got: %s", want, out)
+	}
+}
+
+func TestMessageFormatter_MultilineUsesPre(t *testing.T) {
+	f := NewMessageFormatter()
+
+	entry := parser.LogEntry{
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     parser.LevelError,
+		Message:   []byte("panic: boom\ngoroutine 1 [running]:"),
+	}
+
+	out := f.FormatLogEntry(entry)
+
+	if !strings.HasSuffix(out, "<pre>panic: boom\ngoroutine 1 [running]:</pre>") {
+		t.Fatalf("expected multi-line message in <pre>, got: %s", out)
+	}
+}
+
+func TestMessageFormatter_TruncatesLongTrace(t *testing.T) {
+	f := NewMessageFormatter()
+
+	lines := []string{"panic: <nil> & boom"}
+	for i := range 499 {
+		lines = append(lines, fmt.Sprintf("\tmain.handler%d()\n\t\t/app/main.go:%d +0x1d", i, i))
+	}
+	out := f.FormatLogEntry(parser.LogEntry{
+		Level:   parser.LevelError,
+		Message: []byte(strings.Join(lines, "\n")),
+	})
+
+	if len(out) > 4096 {
+		t.Fatalf("expected the message to fit in 4096 characters, got %d", len(out))
+	}
+	if !strings.Contains(out, "<pre>panic: &lt;nil&gt; &amp; boom\n") ||
+		!strings.HasSuffix(out, "</pre>") {
+		t.Fatalf("expected the trace start in <pre>, got: %s", out)
+	}
+	if !regexp.MustCompile(`\n…и еще строк: \d+</pre>$`).MatchString(out) {
+		t.Fatalf("expected a count of the left out lines, got: %s", out)
+	}
+
+	long := strings.Repeat("&", 2000)
+	out = f.FormatLogEntry(parser.LogEntry{Level: parser.LevelError, Message: []byte(long)})
+	if !strings.HasSuffix(out, "&amp;…</code>") || len(out) > 4096 {
+		t.Fatalf("expected a long line cut between escapes, got: %s", out)
+	}
+}
+
+func TestMessageFormatter_ShowsFields(t *testing.T) {
+	f := NewMessageFormatter()
+
+	entry := parser.LogEntry{
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     parser.LevelError,
+		Message:   []byte("request failed"),
+		Fields:    map[string]string{"user": "42", "path": "/a?b=<c>"},
+	}
+
+	out := f.FormatLogEntry(entry)
+
+	want := "<code>request failed</code>\n<i>path</i>=/a?b=&lt;c&gt; <i>user</i>=42"
+	if !strings.HasSuffix(out, want) {
+		t.Fatalf("expected fields %q, got: %s", want, out)
+	}
+}
+
+func TestMessageFormatter_CutsLargeFields(t *testing.T) {
+	f := NewMessageFormatter()
+
+	fields := map[string]string{"body": strings.Repeat("<x>", 5000)}
+	for i := range 50 {
+		fields[fmt.Sprintf("k%02d", i)] = "value"
+	}
+	out := f.FormatLogEntry(parser.LogEntry{
+		Level:   parser.LevelError,
+		Message: []byte(strings.Repeat("trace line\n", 1000)),
+		Fields:  fields,
+	})
+
+	if len(out) > 4096 {
+		t.Fatalf("expected the message to fit in 4096 characters, got %d", len(out))
+	}
+	if !strings.Contains(out, "<i>body</i>="+strings.Repeat("&lt;x&gt;", 22)+"… ") {
+		t.Fatalf("expected the large value cut between escapes, got: %s", out)
+	}
+	if !regexp.MustCompile(` …и еще полей: \d+$`).MatchString(out) {
+		t.Fatalf("expected a count of the left out fields, got: %s", out)
+	}
+}
+
+func TestMessageFormatter_RawEntryIsNeutral(t *testing.T) {
+	f := NewMessageFormatter()
+
+	entry := parser.LogEntry{
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     parser.LevelUnknown,
+		Message:   []byte("panic: runtime error: <nil>"),
+		Source:    "api",
+	}
+
+	out := f.FormatLogEntry(entry)
+
+	want := "<i>02.01.2026 03:04:05</i> | <i>api</i>\n<pre>panic: runtime error: &lt;nil&gt;</pre>"
+	if out != want {
+		t.Fatalf("expected %q, got: %q", want, out)
+	}
+}
+
+func TestGetLevelText_DistinctMarkers(t *testing.T) {
+	levels := []parser.LogLevel{
+		parser.LevelTrace,
+		parser.LevelDebug,
+		parser.LevelInfo,
+		parser.LevelWarn,
+		parser.LevelError,
+		parser.LevelCritical,
+		parser.LevelFatal,
+	}
+
+	seen := make(map[string]parser.LogLevel, len(levels))
+	for _, level := range levels {
+		text := getLevelText(level)
+		if !strings.HasSuffix(text, " "+string(level)) {
+			t.Errorf("getLevelText(%s) = %q, want the level name", level, text)
+		}
+		marker, _, _ := strings.Cut(text, " ")
+		if other, ok := seen[marker]; ok {
+			t.Errorf("%s and %s share marker %q", level, other, marker)
+		}
+		seen[marker] = level
+	}
+}
+
+func TestMessageFormatter_FormatDeadLetters(t *testing.T) {
+	f := NewMessageFormatter()
+
+	out := f.FormatDeadLetters(parser.DeadLetterStats{
+		Accepted: 3,
+		Rejected: 1,
+		ByReason: map[string]uint64{parser.ReasonInvalidTimestamp: 1},
+		Samples: []parser.DeadLetter{{
+			Time:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			Source: "api",
+			Reason: parser.ReasonInvalidTimestamp,
+			Line:   "yesterday <b>boom</b>",
+		}},
+	})
+
+	for _, want := range []string{
+		"<b>1</b> из 4 (25.0%)",
+		"неверное время: 1",
+		"<i>02.01.2026 03:04:05</i> api, неверное время",
+		"<pre>yesterday &lt;b&gt;boom&lt;/b&gt;</pre>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got: %s", want, out)
+		}
+	}
+}
+
+func TestMessageFormatter_FormatRepeatSummary(t *testing.T) {
+	f := NewMessageFormatter()
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	out := f.FormatRepeatSummary(repeatSummary{
+		entry:  parser.LogEntry{Timestamp: first, Level: parser.LevelError, Message: []byte("boom")},
+		count:  347,
+		first:  first,
+		last:   first.Add(4*time.Minute + 10*time.Second),
+		window: 5 * time.Minute,
+	})
+
+	want := "🔁 <b>×347</b> за последние 5m, первое в 03:04:05, последнее в 03:08:15\n"
+	if !strings.HasPrefix(out, want) || !strings.Contains(out, "boom") {
+		t.Fatalf("unexpected summary: %s", out)
+	}
+}
+
+func TestMessageFormatter_FormatThresholdAlert(t *testing.T) {
+	f := NewMessageFormatter()
+	th := parser.Threshold{Count: 50, Window: time.Minute}
+
+	fired := f.FormatThresholdAlert(thresholdAlert{
+		rule:      "auth <fail>",
+		threshold: th,
+		entry:     parser.LogEntry{Level: parser.LevelError, Message: []byte("boom")},
+	})
+	want := "🚨 <b>Порог превышен</b>: <code>auth &lt;fail&gt;</code> (50 за 1m)\n\n"
+	if !strings.HasPrefix(fired, want) || !strings.Contains(fired, "boom") {
+		t.Fatalf("unexpected alert: %s", fired)
+	}
+
+	resolved := f.FormatThresholdAlert(thresholdAlert{
+		rule:      "auth",
+		threshold: th,
+		resolved:  true,
+		duration:  3*time.Minute + 200*time.Millisecond,
+		total:     180,
+	})
+	want = "✅ <b>Порог больше не превышен</b>: <code>auth</code> (50 за 1m)\n" +
+		"Длилось 3m, совпадений: 180."
+	if resolved != want {
+		t.Fatalf("unexpected resolve notice: %s", resolved)
+	}
+}
+
+func TestMessageFormatter_FormatSilenceEvent(t *testing.T) {
+	f := NewMessageFormatter()
+	last := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	silent := f.FormatSilenceEvent(heartbeat.Event{
+		Kind:     heartbeat.KindSource,
+		Name:     "api",
+		Every:    5 * time.Minute,
+		LastSeen: last,
+		Silent:   true,
+	})
+	want := "🔇 <b>Нет записей</b>: источник <code>api</code>\n" +
+		"Ожидается хотя бы одна запись каждые 5m, последняя была в 02.01.2026 03:04:05."
+	if silent != want {
+		t.Fatalf("unexpected silence alert: %s", silent)
+	}
+
+	resumed := f.FormatSilenceEvent(heartbeat.Event{
+		Kind:    heartbeat.KindRule,
+		Name:    "backups",
+		Silence: 90 * time.Minute,
+	})
+	want = "✅ <b>Снова есть записи</b>: правило <code>backups</code>\nТишина длилась 1h30m."
+	if resumed != want {
+		t.Fatalf("unexpected recovery message: %s", resumed)
+	}
+}
+
+func TestMessageFormatter_FormatAnomalyAlert(t *testing.T) {
+	f := NewMessageFormatter()
+	start := time.Date(2026, 1, 2, 3, 5, 0, 0, time.Local)
+
+	spike := f.FormatAnomalyAlert(anomaly.Alert{
+		Rule:     "errors",
+		Start:    start,
+		Bucket:   5 * time.Minute,
+		Expected: 12.5,
+		Observed: 80,
+	})
+	want := "📈 <b>Необычно много совпадений</b>: правило <code>errors</code>\n" +
+		"За 5m с 03:05: 80 (16.0/мин), обычно в это время около 12.5 (2.5/мин)."
+	if spike != want {
+		t.Fatalf("unexpected spike alert: %s", spike)
+	}
+
+	drop := f.FormatAnomalyAlert(anomaly.Alert{
+		Rule:     "errors",
+		Start:    start,
+		Bucket:   time.Minute,
+		Expected: 40,
+	})
+	if !strings.HasPrefix(drop, "📉 <b>Необычно мало совпадений</b>") {
+		t.Fatalf("unexpected drop alert: %s", drop)
+	}
+}
+
+func TestMessageFormatter_FormatDigest(t *testing.T) {
+	f := NewMessageFormatter()
+	from := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	empty := f.FormatDigest(digestReport{from: from, to: from.Add(24 * time.Hour)})
+	if empty != "📋 <b>Сводка</b> с 02.01 09:00 по 03.01 09:00\n\nЗаписей не было." {
+		t.Fatalf("unexpected empty digest: %s", empty)
+	}
+
+	r := newDigestReport(from)
+	r.to = from.Add(time.Hour)
+	r.total = 5
+	r.levels[parser.LevelWarn] = 1
+	r.levels[parser.LevelError] = 4
+	r.rules["db <errors>"] = 4
+	timeout := &digestMessage{template: "timeout after <num>s", source: "api",
+		level: parser.LevelError, count: 4}
+	r.messages["h1"] = timeout
+	r.messages["h2"] = &digestMessage{template: "slow", level: parser.LevelWarn, count: 1}
+	r.fresh = []*digestMessage{timeout}
+	r.freshCount = 3
+
+	out := f.FormatDigest(r)
+	for _, want := range []string{
+		"Записей: <b>5</b>",
+		"<b>По уровням:</b>\n🔴 ERROR: 4\n🟡 WARN: 1",
+		"<code>db &lt;errors&gt;</code>: 4",
+		"1. ×4 🔴 ERROR <i>api</i> <code>timeout after &lt;num&gt;s</code>\n2. ×1 🟡 WARN <code>slow</code>",
+		"<b>Новые сообщения:</b> 3\n• 🔴 ERROR <i>api</i>",
+		"…и еще 2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("digest lacks %q:\n%s", want, out)
+		}
+	}
+
+	for i := range digestTopRules + 3 {
+		r.rules[fmt.Sprintf("rule %02d", i)] = 1
+	}
+	out = f.FormatDigest(r)
+	if !strings.Contains(out, "<b>По правилам:</b>\n<code>db &lt;errors&gt;</code>: 4\n") {
+		t.Errorf("expected the most matched rule first:\n%s", out)
+	}
+	if !strings.Contains(out, "<code>rule 08</code>: 1\n…и еще 4") ||
+		strings.Contains(out, "rule 09") {
+		t.Errorf("expected the top %d rules and the rest counted:\n%s", digestTopRules, out)
+	}
+}
+
+func TestMessageFormatter_FormatIncident(t *testing.T) {
+	f := NewMessageFormatter()
+	at := time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC)
+
+	open := f.FormatIncident(database.Incident{ID: 3, State: database.IncidentOpen, Text: "boom"})
+	if open != "🚨 <b>Инцидент #3</b> · открыт\n\nboom" {
+		t.Fatalf("unexpected open incident: %s", open)
+	}
+
+	resolved := f.FormatIncident(database.Incident{
+		ID:         3,
+		State:      database.IncidentResolved,
+		Text:       "boom",
+		Count:      4,
+		AckedBy:    "@alice",
+		AckedAt:    at,
+		ResolvedBy: "<bob>",
+		ResolvedAt: at.Add(6 * time.Minute),
+		MutedBy:    "@alice",
+		MutedUntil: at.Add(time.Hour),
+	})
+	want := "✅ <b>Инцидент #3</b> · решен\n\nboom\n\nПовторов: 4\n" +
+		"👀 Принял @alice в 15:04\n✅ Решил &lt;bob&gt; в 15:10\n🔕 Заглушил @alice до 16:04"
+	if resolved != want {
+		t.Fatalf("unexpected resolved incident: %s", resolved)
+	}
+
+	idle := f.FormatIncident(database.Incident{
+		ID:         4,
+		State:      database.IncidentResolved,
+		Text:       "boom",
+		ResolvedAt: at,
+	})
+	want = "✅ <b>Инцидент #4</b> · решен\n\nboom\n✅ Решен автоматически в 15:04: без повторов 24h"
+	if idle != want {
+		t.Fatalf("unexpected idle incident: %s", idle)
+	}
+}
+
+func TestMessageFormatter_FormatEscalationPolicy(t *testing.T) {
+	f := NewMessageFormatter()
+
+	if got := f.FormatEscalationPolicy(nil, false); got != "<b>Эскалация</b> (по умолчанию): выключена" {
+		t.Fatalf("unexpected policy: %s", got)
+	}
+
+	got := f.FormatEscalationPolicy([]EscalationTier{
+		{After: 10 * time.Minute, Chats: []int64{-100}},
+		{After: time.Hour, Chats: []int64{1, 2}},
+	}, true)
+	want := "<b>Эскалация</b>:\n1. через 10m → <code>-100</code>\n" +
+		"2. через 1h → <code>1</code>, <code>2</code>"
+	if got != want {
+		t.Fatalf("unexpected policy: %s", got)
+	}
+}
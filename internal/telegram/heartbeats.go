@@ -0,0 +1,20 @@
+package telegram
+
+import (
+	"log"
+
+	"github.com/kxrxh/logram/internal/heartbeat"
+)
+
+// SetHeartbeats lets /status show which sources and rules are silent.
+func (b *Bot) SetHeartbeats(m *heartbeat.Monitor) {
+	b.heartbeats = m
+}
+
+// NotifySilence tells subscribers that a source or rule went silent or
+// resumed.
+func (b *Bot) NotifySilence(event heartbeat.Event) {
+	if err := b.Broadcast(b.formatter.FormatSilenceEvent(event)); err != nil {
+		log.Printf("send silence alert: %v", err)
+	}
+}
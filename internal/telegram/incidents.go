@@ -0,0 +1,392 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kxrxh/logram/internal/database"
+	"github.com/kxrxh/logram/internal/parser"
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+const (
+	callbackIncidentPrefix = "inc:"
+
+	incidentActionAck     = "ack"
+	incidentActionResolve = "res"
+	incidentActionMute    = "mute"
+
+	// incidentMuteFor is how long Mute silences an incident.
+	incidentMuteFor = time.Hour
+	// incidentIdleFor is how long an incident goes without repeats before it
+	// is resolved on its own.
+	incidentIdleFor = 24 * time.Hour
+	// incidentCheckInterval is how often idle incidents are looked for.
+	incidentCheckInterval = time.Minute
+)
+
+var (
+	ErrIncidentNotFound = errors.New("incident not found")
+	// ErrIncidentState is returned for an action the incident's state
+	// doesn't allow, such as acknowledging a resolved incident.
+	ErrIncidentState = errors.New("action not allowed in incident state")
+)
+
+type incidentStore interface {
+	CreateIncident(inc *database.Incident) error
+	SaveIncident(inc *database.Incident) error
+	GetActiveIncidents(now time.Time) ([]database.Incident, error)
+}
+
+// IncidentManager tracks the incidents opened by alerts, one per chat and
+// key at a time. Repeats of an open incident are counted on it and sent
+// without buttons; a muted incident drops them, even once resolved, until the
+// mute ends. Incidents without repeats for idleFor are resolved on their
+// own. Counts are saved with the next change of the incident.
+type IncidentManager struct {
+	store   incidentStore
+	idleFor time.Duration
+
+	mu    sync.Mutex
+	byKey map[incidentKey]*database.Incident
+	byID  map[uint]*database.Incident
+	now   func() time.Time
+}
+
+type incidentKey struct {
+	chatID int64
+	key    string
+}
+
+// NewIncidentManager loads the active incidents from store.
+func NewIncidentManager(store incidentStore) (*IncidentManager, error) {
+	m := &IncidentManager{
+		store:   store,
+		idleFor: incidentIdleFor,
+		byKey:   make(map[incidentKey]*database.Incident),
+		byID:    make(map[uint]*database.Incident),
+		now:     time.Now,
+	}
+	incidents, err := store.GetActiveIncidents(m.now())
+	if err != nil {
+		return nil, err
+	}
+	for _, inc := range incidents {
+		m.track(&inc)
+	}
+	return m, nil
+}
+
+func (m *IncidentManager) track(inc *database.Incident) {
+	k := incidentKey{chatID: inc.ChatID, key: inc.Key}
+	if old, ok := m.byKey[k]; ok {
+		delete(m.byID, old.ID)
+	}
+	m.byKey[k] = inc
+	m.byID[inc.ID] = inc
+}
+
+// Muted reports whether the chat's incident for key is muted.
+func (m *IncidentManager) Muted(chatID int64, key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := incidentKey{chatID: chatID, key: key}
+	inc, ok := m.byKey[k]
+	if !ok {
+		return false
+	}
+	if m.now().Before(inc.MutedUntil) {
+		return true
+	}
+	if inc.State == database.IncidentResolved {
+		delete(m.byKey, k)
+		delete(m.byID, inc.ID)
+	}
+	return false
+}
+
+// Repeat counts an alert on the chat's open incident for key and reports
+// whether there is one.
+func (m *IncidentManager) Repeat(chatID int64, key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inc, ok := m.byKey[incidentKey{chatID: chatID, key: key}]
+	if !ok || inc.State == database.IncidentResolved {
+		return false
+	}
+	inc.Count++
+	inc.LastSeenAt = m.now()
+	return true
+}
+
+// Open starts an incident for the chat with the alert text.
+func (m *IncidentManager) Open(chatID int64, key, text string) (database.Incident, error) {
+	now := m.now()
+	inc := &database.Incident{
+		ChatID:     chatID,
+		Key:        key,
+		Text:       text,
+		State:      database.IncidentOpen,
+		Count:      1,
+		OpenedAt:   now,
+		LastSeenAt: now,
+	}
+	if err := m.store.CreateIncident(inc); err != nil {
+		return database.Incident{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.track(inc)
+	return *inc, nil
+}
+
+// SetMessage records the message the incident was sent as.
+func (m *IncidentManager) SetMessage(id uint, messageID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inc, ok := m.byID[id]
+	if !ok {
+		return ErrIncidentNotFound
+	}
+	inc.MessageID = messageID
+	return m.store.SaveIncident(inc)
+}
+
+// Apply acknowledges, resolves or mutes an incident on behalf of by and
+// returns its new state.
+func (m *IncidentManager) Apply(id uint, action, by string) (database.Incident, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inc, ok := m.byID[id]
+	if !ok {
+		return database.Incident{}, ErrIncidentNotFound
+	}
+
+	now := m.now()
+	updated := *inc
+	switch {
+	case action == incidentActionAck && inc.State == database.IncidentOpen:
+		updated.State = database.IncidentAcknowledged
+		updated.AckedBy = by
+		updated.AckedAt = now
+	case action == incidentActionResolve && inc.State != database.IncidentResolved:
+		updated.State = database.IncidentResolved
+		updated.ResolvedBy = by
+		updated.ResolvedAt = now
+	case action == incidentActionMute && inc.State != database.IncidentResolved:
+		updated.MutedBy = by
+		updated.MutedUntil = now.Add(incidentMuteFor)
+	default:
+		return *inc, ErrIncidentState
+	}
+
+	if err := m.store.SaveIncident(&updated); err != nil {
+		return *inc, err
+	}
+	*inc = updated
+	if inc.State == database.IncidentResolved && !now.Before(inc.MutedUntil) {
+		delete(m.byKey, incidentKey{chatID: inc.ChatID, key: inc.Key})
+		delete(m.byID, inc.ID)
+	}
+	return updated, nil
+}
+
+// ResolveIdle resolves the incidents that have had no repeats for idleFor
+// and returns them.
+func (m *IncidentManager) ResolveIdle() []database.Incident {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+
+	var resolved []database.Incident
+	for _, inc := range m.byID {
+		lastSeen := inc.LastSeenAt
+		if lastSeen.Before(inc.OpenedAt) {
+			lastSeen = inc.OpenedAt
+		}
+		if inc.State == database.IncidentResolved || now.Sub(lastSeen) < m.idleFor {
+			continue
+		}
+
+		updated := *inc
+		updated.State = database.IncidentResolved
+		updated.ResolvedAt = now
+		if err := m.store.SaveIncident(&updated); err != nil {
+			log.Printf("Failed to resolve idle incident %d: %v", inc.ID, err)
+			continue
+		}
+		*inc = updated
+		if !now.Before(inc.MutedUntil) {
+			delete(m.byKey, incidentKey{chatID: inc.ChatID, key: inc.Key})
+			delete(m.byID, inc.ID)
+		}
+		resolved = append(resolved, updated)
+	}
+	return resolved
+}
+
+// Run resolves idle incidents every interval until ctx is done, passing each
+// to resolved.
+func (m *IncidentManager) Run(
+	ctx context.Context,
+	interval time.Duration,
+	resolved func(database.Incident),
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, inc := range m.ResolveIdle() {
+				resolved(inc)
+			}
+		}
+	}
+}
+
+// entryIncidentKey groups the errors of a chat by fingerprint.
+func entryIncidentKey(hash string) string {
+	return "fp:" + hash
+}
+
+// ruleIncidentKey groups the threshold breaches of a chat's rule.
+func ruleIncidentKey(rule string) string {
+	return "rule:" + rule
+}
+
+// opensIncident reports whether an entry is severe enough to open one.
+func opensIncident(entry parser.LogEntry) bool {
+	return entry.Level.AtLeast(parser.LevelError)
+}
+
+// sendIncident sends msg as an incident for key: repeats of an open one go
+// out as plain messages, otherwise a new incident is opened with its buttons.
+// Callers drop the messages of muted incidents first.
+func (b *Bot) sendIncident(chatID int64, key, msg string) error {
+	if b.incidents.Repeat(chatID, key) {
+		return b.deliver(chatID, msg)
+	}
+
+	inc, err := b.incidents.Open(chatID, key, msg)
+	if err != nil {
+		log.Printf("Failed to open incident for chat %d: %v", chatID, err)
+		return b.deliver(chatID, msg)
+	}
+	messageID, err := b.client.SendMessageHTMLWithInlineKeyboard(
+		chatID,
+		b.formatter.FormatIncident(inc),
+		incidentKeyboard(inc),
+	)
+	if err != nil {
+		log.Printf("Failed to send incident %d to chat %d: %v", inc.ID, chatID, err)
+		return err
+	}
+	if err := b.incidents.SetMessage(inc.ID, messageID); err != nil {
+		log.Printf("Failed to save incident %d message: %v", inc.ID, err)
+	}
+	return nil
+}
+
+// incidentKeyboard returns the buttons the incident's state allows.
+func incidentKeyboard(inc database.Incident) *telego.InlineKeyboardMarkup {
+	if inc.State == database.IncidentResolved {
+		return nil
+	}
+
+	id := strconv.FormatUint(uint64(inc.ID), 10)
+	button := func(text, action string) telego.InlineKeyboardButton {
+		return tu.InlineKeyboardButton(text).
+			WithCallbackData(callbackIncidentPrefix + action + ":" + id)
+	}
+
+	var row []telego.InlineKeyboardButton
+	if inc.State == database.IncidentOpen {
+		row = append(row, button("👀 Принять", incidentActionAck))
+	}
+	row = append(row,
+		button("✅ Решить", incidentActionResolve),
+		button("🔕 Заглушить 1ч", incidentActionMute),
+	)
+	return tu.InlineKeyboard(tu.InlineKeyboardRow(row...))
+}
+
+func (b *Bot) handleIncidentCallbackQuery(ctx *th.Context, query telego.CallbackQuery) error {
+	answer := func(text string) error {
+		return ctx.Bot().AnswerCallbackQuery(ctx, tu.CallbackQuery(query.ID).WithText(text))
+	}
+
+	action, rawID, ok := strings.Cut(strings.TrimPrefix(query.Data, callbackIncidentPrefix), ":")
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if !ok || err != nil || b.incidents == nil {
+		return answer("Некорректные данные")
+	}
+
+	inc, err := b.incidents.Apply(uint(id), action, userName(query.From))
+	switch {
+	case errors.Is(err, ErrIncidentNotFound):
+		return answer("Инцидент уже закрыт")
+	case errors.Is(err, ErrIncidentState):
+		return answer("Уже " + incidentStateText(inc.State))
+	case err != nil:
+		log.Printf("Failed to update incident %d: %v", id, err)
+		return answer("Ошибка")
+	}
+
+	b.updateIncidentMessage(inc)
+	// Buttons pressed on an escalation only update that message's buttons.
+	if msg := query.Message; msg != nil &&
+		(msg.GetChat().ID != inc.ChatID || msg.GetMessageID() != inc.MessageID) {
+		chatID, messageID := msg.GetChat().ID, msg.GetMessageID()
+		if err := b.client.EditMessageKeyboard(chatID, messageID, incidentKeyboard(inc)); err != nil {
+			log.Printf("Failed to edit incident %d escalation: %v", inc.ID, err)
+		}
+	}
+	return answer(fmt.Sprintf("Инцидент #%d: %s", inc.ID, incidentStateText(inc.State)))
+}
+
+// updateIncidentMessage shows the incident's state and buttons on the
+// message it was sent as.
+func (b *Bot) updateIncidentMessage(inc database.Incident) {
+	if inc.MessageID == 0 {
+		return
+	}
+	err := b.client.EditMessageHTML(
+		inc.ChatID,
+		inc.MessageID,
+		b.formatter.FormatIncident(inc),
+		incidentKeyboard(inc),
+	)
+	if err != nil {
+		log.Printf("Failed to edit incident %d message: %v", inc.ID, err)
+	}
+}
+
+// userName is how a user who pressed a button is shown.
+func userName(u telego.User) string {
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+func incidentStateText(state string) string {
+	switch state {
+	case database.IncidentAcknowledged:
+		return "принят"
+	case database.IncidentResolved:
+		return "решен"
+	default:
+		return "открыт"
+	}
+}
@@ -0,0 +1,192 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/database"
+	"github.com/stretchr/testify/require"
+)
+
+// memIncidentStore keeps incidents like the database does.
+type memIncidentStore struct {
+	incidents map[uint]database.Incident
+	nextID    uint
+}
+
+func newMemIncidentStore() *memIncidentStore {
+	return &memIncidentStore{incidents: make(map[uint]database.Incident)}
+}
+
+func (s *memIncidentStore) CreateIncident(inc *database.Incident) error {
+	s.nextID++
+	inc.ID = s.nextID
+	s.incidents[inc.ID] = *inc
+	return nil
+}
+
+func (s *memIncidentStore) SaveIncident(inc *database.Incident) error {
+	s.incidents[inc.ID] = *inc
+	return nil
+}
+
+func (s *memIncidentStore) GetActiveIncidents(now time.Time) ([]database.Incident, error) {
+	var active []database.Incident
+	for id := uint(1); id <= s.nextID; id++ {
+		inc, ok := s.incidents[id]
+		if ok && (inc.State != database.IncidentResolved || now.Before(inc.MutedUntil)) {
+			active = append(active, inc)
+		}
+	}
+	return active, nil
+}
+
+func newTestIncidentManager(
+	t *testing.T,
+	store *memIncidentStore,
+	now *time.Time,
+) *IncidentManager {
+	t.Helper()
+	m, err := NewIncidentManager(store)
+	require.NoError(t, err)
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func TestIncidentManager_Lifecycle(t *testing.T) {
+	store := newMemIncidentStore()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := newTestIncidentManager(t, store, &now)
+
+	require.False(t, m.Repeat(1, "fp:a"), "no incident yet")
+	inc, err := m.Open(1, "fp:a", "boom")
+	require.NoError(t, err)
+	require.Equal(t, database.IncidentOpen, inc.State)
+	require.NoError(t, m.SetMessage(inc.ID, 42))
+
+	require.True(t, m.Repeat(1, "fp:a"))
+	require.False(t, m.Repeat(2, "fp:a"), "incidents are per chat")
+
+	now = now.Add(time.Minute)
+	acked, err := m.Apply(inc.ID, incidentActionAck, "@alice")
+	require.NoError(t, err)
+	require.Equal(t, database.IncidentAcknowledged, acked.State)
+	require.Equal(t, "@alice", acked.AckedBy)
+	require.Equal(t, now, acked.AckedAt)
+	require.Equal(t, 2, acked.Count)
+	require.Equal(t, 42, acked.MessageID)
+	require.Equal(t, acked, store.incidents[inc.ID])
+
+	_, err = m.Apply(inc.ID, incidentActionAck, "@bob")
+	require.ErrorIs(t, err, ErrIncidentState)
+
+	require.True(t, m.Repeat(1, "fp:a"), "acknowledged incidents still count repeats")
+
+	resolved, err := m.Apply(inc.ID, incidentActionResolve, "@bob")
+	require.NoError(t, err)
+	require.Equal(t, database.IncidentResolved, resolved.State)
+	require.Equal(t, "@bob", resolved.ResolvedBy)
+	require.Equal(t, 3, resolved.Count)
+
+	_, err = m.Apply(inc.ID, incidentActionMute, "@bob")
+	require.ErrorIs(t, err, ErrIncidentNotFound)
+	require.False(t, m.Repeat(1, "fp:a"), "the next error opens a new incident")
+}
+
+func TestIncidentManager_MuteOutlivesResolve(t *testing.T) {
+	store := newMemIncidentStore()
+	// Loading the store checks mutes against the real clock.
+	now := time.Now()
+	m := newTestIncidentManager(t, store, &now)
+
+	inc, err := m.Open(1, "rule:auth", "threshold")
+	require.NoError(t, err)
+	muted, err := m.Apply(inc.ID, incidentActionMute, "@alice")
+	require.NoError(t, err)
+	require.Equal(t, database.IncidentOpen, muted.State)
+	require.Equal(t, now.Add(incidentMuteFor), muted.MutedUntil)
+	require.True(t, m.Muted(1, "rule:auth"))
+	require.False(t, m.Muted(1, "rule:other"))
+
+	_, err = m.Apply(inc.ID, incidentActionResolve, "@alice")
+	require.NoError(t, err)
+	require.True(t, m.Muted(1, "rule:auth"), "mute lasts past resolve")
+
+	reloaded := newTestIncidentManager(t, store, &now)
+	require.True(t, reloaded.Muted(1, "rule:auth"), "mute survives a restart")
+
+	now = now.Add(incidentMuteFor)
+	require.False(t, m.Muted(1, "rule:auth"))
+	require.False(t, m.Repeat(1, "rule:auth"))
+}
+
+func TestIncidentManager_LoadsActiveIncidents(t *testing.T) {
+	store := newMemIncidentStore()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := newTestIncidentManager(t, store, &now)
+
+	open, err := m.Open(1, "fp:a", "a")
+	require.NoError(t, err)
+	closed, err := m.Open(1, "fp:b", "b")
+	require.NoError(t, err)
+	_, err = m.Apply(closed.ID, incidentActionResolve, "@alice")
+	require.NoError(t, err)
+
+	reloaded := newTestIncidentManager(t, store, &now)
+	require.True(t, reloaded.Repeat(1, "fp:a"))
+	require.False(t, reloaded.Repeat(1, "fp:b"))
+
+	acked, err := reloaded.Apply(open.ID, incidentActionAck, "@bob")
+	require.NoError(t, err)
+	require.Equal(t, 2, acked.Count)
+}
+
+func TestIncidentManager_ResolvesIdleIncidents(t *testing.T) {
+	store := newMemIncidentStore()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := newTestIncidentManager(t, store, &now)
+
+	idle, err := m.Open(1, "fp:a", "a")
+	require.NoError(t, err)
+	busy, err := m.Open(1, "fp:b", "b")
+	require.NoError(t, err)
+
+	now = now.Add(incidentIdleFor - time.Minute)
+	require.True(t, m.Repeat(1, "fp:b"))
+	require.Empty(t, m.ResolveIdle())
+
+	now = now.Add(time.Minute)
+	resolved := m.ResolveIdle()
+	require.Len(t, resolved, 1)
+	require.Equal(t, idle.ID, resolved[0].ID)
+	require.Equal(t, database.IncidentResolved, resolved[0].State)
+	require.Empty(t, resolved[0].ResolvedBy)
+	require.Equal(t, now, resolved[0].ResolvedAt)
+	require.Equal(t, resolved[0], store.incidents[idle.ID])
+	require.False(t, m.Repeat(1, "fp:a"), "the next error opens a new incident")
+	require.True(t, m.Repeat(1, "fp:b"), "repeats keep an incident open")
+
+	now = now.Add(incidentIdleFor)
+	require.Len(t, m.ResolveIdle(), 1)
+	require.Equal(t, database.IncidentResolved, store.incidents[busy.ID].State)
+
+	active, err := store.GetActiveIncidents(now)
+	require.NoError(t, err)
+	require.Empty(t, active)
+}
+
+func TestIncidentKeyboard_FollowsState(t *testing.T) {
+	inc := database.Incident{ID: 7, State: database.IncidentOpen}
+	kb := incidentKeyboard(inc)
+	require.Len(t, kb.InlineKeyboard, 1)
+	require.Len(t, kb.InlineKeyboard[0], 3)
+	require.Equal(t, "inc:ack:7", kb.InlineKeyboard[0][0].CallbackData)
+
+	inc.State = database.IncidentAcknowledged
+	kb = incidentKeyboard(inc)
+	require.Len(t, kb.InlineKeyboard[0], 2)
+	require.Equal(t, "inc:res:7", kb.InlineKeyboard[0][0].CallbackData)
+
+	inc.State = database.IncidentResolved
+	require.Nil(t, incidentKeyboard(inc))
+}
@@ -11,8 +11,20 @@ import (
 )
 
 type compiledRule struct {
-	name  string
-	regex *regexp.Regexp
+	name      string
+	regex     *regexp.Regexp
+	minLevel  parser.LogLevel
+	threshold parser.Threshold
+	// pattern is the rule's index in the manager's matcher.
+	pattern int
+}
+
+// RuleMatch is the chat's first rule to match an entry. Rule is empty for a
+// chat without rules, which gets every entry.
+type RuleMatch struct {
+	ChatID    int64
+	Rule      string
+	Threshold parser.Threshold
 }
 
 type RegexManager struct {
@@ -20,11 +32,17 @@ type RegexManager struct {
 
 	defaultRules  []compiledRule
 	chatOverrides map[int64][]compiledRule
+	chatMinLevels map[int64]parser.LogLevel
+
+	// matcher runs the patterns of the default and all chat rules, so rules
+	// shared by chats run once per entry.
+	matcher *parser.Matcher
 }
 
 func NewRegexManager(defaultRules []parser.RuleConfig) (*RegexManager, error) {
 	rm := &RegexManager{
 		chatOverrides: make(map[int64][]compiledRule),
+		chatMinLevels: make(map[int64]parser.LogLevel),
 	}
 
 	if err := rm.SetDefaultRules(defaultRules); err != nil {
@@ -42,6 +60,7 @@ func (rm *RegexManager) SetDefaultRules(rules []parser.RuleConfig) error {
 
 	rm.mu.Lock()
 	rm.defaultRules = compiled
+	rm.rebuildMatcherLocked()
 	rm.mu.Unlock()
 	return nil
 }
@@ -58,6 +77,7 @@ func (rm *RegexManager) RefreshChatRules(chatID int64, rules []database.ChatRege
 	} else {
 		rm.chatOverrides[chatID] = compiled
 	}
+	rm.rebuildMatcherLocked()
 	rm.mu.Unlock()
 	return nil
 }
@@ -65,39 +85,120 @@ func (rm *RegexManager) RefreshChatRules(chatID int64, rules []database.ChatRege
 func (rm *RegexManager) ClearChatRules(chatID int64) {
 	rm.mu.Lock()
 	delete(rm.chatOverrides, chatID)
+	rm.rebuildMatcherLocked()
 	rm.mu.Unlock()
 }
 
-func (rm *RegexManager) ShouldSend(chatID int64, raw []byte) bool {
+// rebuildMatcherLocked numbers the rules' patterns and builds their matcher.
+// Rules get fresh slices, as readers may still hold the old ones.
+func (rm *RegexManager) rebuildMatcherLocked() {
+	var patterns []*regexp.Regexp
+	number := func(rules []compiledRule) []compiledRule {
+		out := make([]compiledRule, len(rules))
+		for i, r := range rules {
+			r.pattern = len(patterns)
+			patterns = append(patterns, r.regex)
+			out[i] = r
+		}
+		return out
+	}
+
+	rm.defaultRules = number(rm.defaultRules)
+	for chatID, rules := range rm.chatOverrides {
+		rm.chatOverrides[chatID] = number(rules)
+	}
+	rm.matcher = parser.NewMatcher(patterns)
+}
+
+// chatRulesLocked returns the chat's own rules, or the default ones.
+func (rm *RegexManager) chatRulesLocked(chatID int64) []compiledRule {
+	if override, ok := rm.chatOverrides[chatID]; ok {
+		return override
+	}
+	return rm.defaultRules
+}
+
+// SetChatMinLevel makes the chat receive only entries at least as severe as
+// level. An empty level receives every level.
+func (rm *RegexManager) SetChatMinLevel(chatID int64, level parser.LogLevel) {
+	rm.mu.Lock()
+	if level == "" {
+		delete(rm.chatMinLevels, chatID)
+	} else {
+		rm.chatMinLevels[chatID] = level
+	}
+	rm.mu.Unlock()
+}
+
+func (rm *RegexManager) ChatMinLevel(chatID int64) parser.LogLevel {
 	rm.mu.RLock()
-	override, hasOverride := rm.chatOverrides[chatID]
-	if hasOverride {
-		compiled := override
-		rm.mu.RUnlock()
-		return matchesAny(compiled, raw)
+	defer rm.mu.RUnlock()
+	return rm.chatMinLevels[chatID]
+}
+
+// ShouldSendEntry reports whether the entry passes the chat's minimum level
+// and matches one of its rules, including the rules' own minimum levels.
+func (rm *RegexManager) ShouldSendEntry(chatID int64, entry parser.LogEntry) bool {
+	return len(rm.Recipients([]int64{chatID}, entry)) == 1
+}
+
+// Recipients returns the chats among chatIDs that ShouldSendEntry would send
+// the entry to, running each distinct pattern at most once.
+func (rm *RegexManager) Recipients(chatIDs []int64, entry parser.LogEntry) []int64 {
+	matches := rm.Matches(chatIDs, entry)
+	recipients := make([]int64, len(matches))
+	for i, m := range matches {
+		recipients[i] = m.ChatID
 	}
-	compiled := rm.defaultRules
-	rm.mu.RUnlock()
-	return matchesAny(compiled, raw)
+	return recipients
 }
 
-func (rm *RegexManager) MatchFirstRuleName(chatID int64, raw []byte) (string, bool) {
+// Matches is Recipients with the rule each chat matched.
+func (rm *RegexManager) Matches(chatIDs []int64, entry parser.LogEntry) []RuleMatch {
 	rm.mu.RLock()
-	override, hasOverride := rm.chatOverrides[chatID]
-	var compiled []compiledRule
-	if hasOverride {
-		compiled = override
-	} else {
-		compiled = rm.defaultRules
+	defer rm.mu.RUnlock()
+
+	var matches *parser.Matches
+	out := make([]RuleMatch, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		if !entry.Level.AtLeast(rm.chatMinLevels[chatID]) {
+			continue
+		}
+		compiled := rm.chatRulesLocked(chatID)
+		if len(compiled) == 0 {
+			out = append(out, RuleMatch{ChatID: chatID})
+			continue
+		}
+		if matches == nil {
+			matches = rm.matcher.Scan(entry.Raw)
+		}
+		for _, r := range compiled {
+			if entry.Level.AtLeast(r.minLevel) && matches.Match(r.pattern) {
+				out = append(out, RuleMatch{ChatID: chatID, Rule: r.name, Threshold: r.threshold})
+				break
+			}
+		}
 	}
-	rm.mu.RUnlock()
+	return out
+}
+
+func (rm *RegexManager) ShouldSend(chatID int64, raw []byte) bool {
+	_, ok := rm.MatchFirstRuleName(chatID, raw)
+	return ok
+}
+
+func (rm *RegexManager) MatchFirstRuleName(chatID int64, raw []byte) (string, bool) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
 
+	compiled := rm.chatRulesLocked(chatID)
 	if len(compiled) == 0 {
 		return "", true
 	}
 
+	matches := rm.matcher.Scan(raw)
 	for _, r := range compiled {
-		if r.regex.Match(raw) {
+		if matches.Match(r.pattern) {
 			return r.name, true
 		}
 	}
@@ -125,7 +226,8 @@ func (rm *RegexManager) GetActiveRulesWithSource(chatID int64) ([]parser.RuleCon
 func compileRuleConfigs(rules []parser.RuleConfig) ([]compiledRule, error) {
 	compiled := make([]compiledRule, 0, len(rules))
 	for _, r := range rules {
-		if strings.TrimSpace(r.Name) == "" || strings.TrimSpace(r.Pattern) == "" {
+		if strings.TrimSpace(r.Name) == "" ||
+			(strings.TrimSpace(r.Pattern) == "" && r.MinLevel == "") {
 			log.Printf("skip default regex rule: name=%q pattern empty", r.Name)
 			continue
 		}
@@ -134,9 +236,15 @@ func compileRuleConfigs(rules []parser.RuleConfig) ([]compiledRule, error) {
 		if err != nil {
 			return nil, &parser.RuleError{Rule: r.Name, Reason: err}
 		}
+		minLevel, err := parser.ParseMinLevel(r.MinLevel)
+		if err != nil {
+			return nil, &parser.RuleError{Rule: r.Name, Reason: err}
+		}
 		compiled = append(compiled, compiledRule{
-			name:  r.Name,
-			regex: re,
+			name:      r.Name,
+			regex:     re,
+			minLevel:  minLevel,
+			threshold: r.Threshold,
 		})
 	}
 	return compiled, nil
@@ -158,30 +266,23 @@ func compileChatRules(rules []database.ChatRegexRule) ([]compiledRule, error) {
 		compiled = append(compiled, compiledRule{
 			name:  r.Name,
 			regex: re,
+			threshold: parser.Threshold{
+				Count:  r.ThresholdCount,
+				Window: r.ThresholdWindow,
+			},
 		})
 	}
 	return compiled, nil
 }
 
-func matchesAny(rules []compiledRule, raw []byte) bool {
-	if len(rules) == 0 {
-		return true
-	}
-
-	for _, r := range rules {
-		if r.regex.Match(raw) {
-			return true
-		}
-	}
-	return false
-}
-
 func compiledToRuleConfigs(rules []compiledRule) []parser.RuleConfig {
 	out := make([]parser.RuleConfig, 0, len(rules))
 	for _, r := range rules {
 		out = append(out, parser.RuleConfig{
-			Name:    r.name,
-			Pattern: r.regex.String(),
+			Name:      r.name,
+			Pattern:   r.regex.String(),
+			MinLevel:  string(r.minLevel),
+			Threshold: r.threshold,
 		})
 	}
 	return out
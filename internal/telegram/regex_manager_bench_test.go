@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/kxrxh/logram/internal/database"
+	"github.com/kxrxh/logram/internal/parser"
+)
+
+// benchChatRules gives each of 200 chats five rules out of a pool of 50
+// patterns, as chats copy the same filters from each other.
+func benchChatRules() map[int64][]database.ChatRegexRule {
+	byChat := make(map[int64][]database.ChatRegexRule, 200)
+	for chatID := range int64(200) {
+		for j := range int64(5) {
+			n := (chatID*7 + j*11) % 50
+			byChat[chatID] = append(byChat[chatID], database.ChatRegexRule{
+				ChatID:  chatID,
+				Name:    fmt.Sprintf("rule%d", n),
+				Pattern: fmt.Sprintf(`(?i)service-%d\s+(timeout|refused|failed)`, n),
+			})
+		}
+	}
+	return byChat
+}
+
+func BenchmarkRegexManager_Recipients(b *testing.B) {
+	byChat := benchChatRules()
+	chatIDs := make([]int64, 0, len(byChat))
+	for chatID := range byChat {
+		chatIDs = append(chatIDs, chatID)
+	}
+	entry := parser.LogEntry{
+		Level: parser.LevelError,
+		Raw:   []byte("2024-01-15T10:30:00Z [ERROR] service-42 timeout after 30s"),
+	}
+
+	// naive is every chat running its own regexes, as before the shared
+	// matcher.
+	b.Run("naive", func(b *testing.B) {
+		compiled := make(map[int64][]*regexp.Regexp, len(byChat))
+		for chatID, rules := range byChat {
+			for _, r := range rules {
+				compiled[chatID] = append(compiled[chatID], regexp.MustCompile(r.Pattern))
+			}
+		}
+		for b.Loop() {
+			for _, chatID := range chatIDs {
+				for _, re := range compiled[chatID] {
+					if re.Match(entry.Raw) {
+						break
+					}
+				}
+			}
+		}
+	})
+
+	b.Run("shared", func(b *testing.B) {
+		rm, err := NewRegexManager(nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for chatID, rules := range byChat {
+			if err := rm.RefreshChatRules(chatID, rules); err != nil {
+				b.Fatal(err)
+			}
+		}
+		for b.Loop() {
+			_ = rm.Recipients(chatIDs, entry)
+		}
+	})
+}
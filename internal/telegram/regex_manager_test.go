@@ -132,3 +132,59 @@ func TestRegexManager_SkipsEmptyPatternButKeepsValidOnes(t *testing.T) {
 	require.False(t, rm.ShouldSend(1, []byte("WARN")))
 	require.True(t, rm.ShouldSend(1, []byte("ERROR")))
 }
+
+func TestRegexManager_MinLevels(t *testing.T) {
+	rm, err := NewRegexManager([]parser.RuleConfig{
+		{Name: "warnings", MinLevel: "warning"},
+		{Name: "db", Pattern: "postgres"},
+	})
+	require.NoError(t, err)
+
+	entry := func(level parser.LogLevel, raw string) parser.LogEntry {
+		return parser.LogEntry{Level: level, Raw: []byte(raw)}
+	}
+
+	// warnings rule matches any WARN+ line, db rule any level
+	require.True(t, rm.ShouldSendEntry(1, entry(parser.LevelWarn, "disk 90%")))
+	require.True(t, rm.ShouldSendEntry(1, entry(parser.LevelFatal, "oom")))
+	require.False(t, rm.ShouldSendEntry(1, entry(parser.LevelInfo, "started")))
+	require.True(t, rm.ShouldSendEntry(1, entry(parser.LevelDebug, "postgres query")))
+
+	// chat minimum applies on top of the rules
+	rm.SetChatMinLevel(1, parser.LevelError)
+	require.False(t, rm.ShouldSendEntry(1, entry(parser.LevelWarn, "disk 90%")))
+	require.False(t, rm.ShouldSendEntry(1, entry(parser.LevelDebug, "postgres query")))
+	require.True(t, rm.ShouldSendEntry(1, entry(parser.LevelCritical, "postgres down")))
+	require.True(t, rm.ShouldSendEntry(2, entry(parser.LevelWarn, "disk 90%")))
+
+	rm.SetChatMinLevel(1, "")
+	require.True(t, rm.ShouldSendEntry(1, entry(parser.LevelWarn, "disk 90%")))
+
+	rules := rm.GetActiveRules(1)
+	require.Equal(t, "WARN", rules[0].MinLevel)
+}
+
+func TestRegexManager_UnknownMinLevel(t *testing.T) {
+	_, err := NewRegexManager([]parser.RuleConfig{{Name: "bad", MinLevel: "LOUD"}})
+	require.ErrorIs(t, err, parser.ErrUnknownLevel)
+}
+
+func TestRegexManager_Recipients(t *testing.T) {
+	rm, err := NewRegexManager([]parser.RuleConfig{ruleCfg("errors", "ERROR")})
+	require.NoError(t, err)
+
+	// chats 1 and 2 share a pattern, chat 3 has its own, chat 4 uses defaults
+	for chatID, pattern := range map[int64]string{1: "postgres", 2: "postgres", 3: "redis"} {
+		err = rm.RefreshChatRules(chatID, []database.ChatRegexRule{
+			{ChatID: chatID, Name: "db", Pattern: pattern},
+		})
+		require.NoError(t, err)
+	}
+	rm.SetChatMinLevel(2, parser.LevelFatal)
+
+	entry := parser.LogEntry{Level: parser.LevelError, Raw: []byte("ERROR postgres down")}
+	require.Equal(t, []int64{1, 4}, rm.Recipients([]int64{1, 2, 3, 4}, entry))
+
+	rm.ClearChatRules(3)
+	require.Equal(t, []int64{1, 3, 4}, rm.Recipients([]int64{1, 2, 3, 4}, entry))
+}
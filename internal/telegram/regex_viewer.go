@@ -1,6 +1,7 @@
 package telegram
 
 import (
+	"fmt"
 	"html"
 	"strings"
 
@@ -37,6 +38,15 @@ func (b *Bot) handleRegexesCommand(_ *th.Context, update telego.Update) error {
 			msg.WriteString("</code>: <code>")
 			msg.WriteString(html.EscapeString(r.Pattern))
 			msg.WriteString("</code>")
+			if r.MinLevel != "" {
+				msg.WriteString(" (≥ ")
+				msg.WriteString(r.MinLevel)
+				msg.WriteString(")")
+			}
+			if r.Threshold.Enabled() {
+				fmt.Fprintf(&msg, " (порог: %d за %s)",
+					r.Threshold.Count, formatDuration(r.Threshold.Window))
+			}
 			if fromDefaults {
 				msg.WriteString(" <b>[default]</b>")
 			}
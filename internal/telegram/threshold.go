@@ -0,0 +1,246 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+)
+
+// thresholdCheckInterval is how often alerts are checked for resolving.
+const thresholdCheckInterval = 5 * time.Second
+
+// ThresholdManager tracks the matches of threshold rules per chat and rule.
+// It alerts once when a rule's matches within its window reach the count,
+// and resolves once they drop below it.
+type ThresholdManager struct {
+	mu     sync.Mutex
+	states map[thresholdKey]*thresholdState
+	now    func() time.Time
+
+	notify func(chatID int64, alert thresholdAlert)
+}
+
+type thresholdKey struct {
+	chatID int64
+	rule   string
+}
+
+type thresholdState struct {
+	threshold parser.Threshold
+	// hits are the times of the latest matches, at most threshold.Count.
+	hits []time.Time
+
+	firing  bool
+	firedAt time.Time
+	// total counts the matches since the alert fired.
+	total int
+	last  parser.LogEntry
+}
+
+// thresholdAlert is a rule crossing its threshold, or with resolved set,
+// dropping back below it.
+type thresholdAlert struct {
+	rule      string
+	threshold parser.Threshold
+	resolved  bool
+	// entry is the latest match.
+	entry parser.LogEntry
+	// duration and total are how long the alert lasted and how many matches
+	// it saw, once resolved.
+	duration time.Duration
+	total    int
+}
+
+func NewThresholdManager(notify func(chatID int64, alert thresholdAlert)) *ThresholdManager {
+	return &ThresholdManager{
+		states: make(map[thresholdKey]*thresholdState),
+		now:    time.Now,
+		notify: notify,
+	}
+}
+
+// Hit records a match of the chat's rule.
+func (m *ThresholdManager) Hit(
+	chatID int64,
+	rule string,
+	threshold parser.Threshold,
+	entry parser.LogEntry,
+) {
+	now := m.now()
+	k := thresholdKey{chatID: chatID, rule: rule}
+
+	m.mu.Lock()
+	st, ok := m.states[k]
+	if !ok || st.threshold != threshold {
+		st = &thresholdState{threshold: threshold, hits: make([]time.Time, 0, threshold.Count)}
+		m.states[k] = st
+	}
+	if len(st.hits) == threshold.Count {
+		st.hits = append(st.hits[:0], st.hits[1:]...)
+	}
+	st.hits = append(st.hits, now)
+	st.last = entry
+
+	var alert *thresholdAlert
+	if st.firing {
+		st.total++
+	} else if st.reached(now) {
+		st.firing = true
+		st.firedAt = now
+		st.total = len(st.hits)
+		alert = &thresholdAlert{rule: rule, threshold: threshold, entry: entry}
+	}
+	m.mu.Unlock()
+
+	if alert != nil {
+		m.notify(chatID, *alert)
+	}
+}
+
+// reached reports whether the last Count hits are all within the window.
+func (st *thresholdState) reached(now time.Time) bool {
+	return len(st.hits) == st.threshold.Count &&
+		now.Sub(st.hits[0]) <= st.threshold.Window
+}
+
+// Check resolves the alerts whose rate dropped below the threshold and
+// forgets rules without recent matches.
+func (m *ThresholdManager) Check() {
+	now := m.now()
+
+	type resolved struct {
+		chatID int64
+		alert  thresholdAlert
+	}
+	var done []resolved
+
+	m.mu.Lock()
+	for k, st := range m.states {
+		if st.reached(now) {
+			continue
+		}
+		if st.firing {
+			done = append(done, resolved{k.chatID, thresholdAlert{
+				rule:      k.rule,
+				threshold: st.threshold,
+				resolved:  true,
+				entry:     st.last,
+				duration:  now.Sub(st.firedAt),
+				total:     st.total,
+			}})
+			st.firing = false
+		}
+		if len(st.hits) == 0 || now.Sub(st.hits[len(st.hits)-1]) > st.threshold.Window {
+			delete(m.states, k)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, r := range done {
+		m.notify(r.chatID, r.alert)
+	}
+}
+
+// Run checks for resolved alerts every interval until ctx is done.
+func (m *ThresholdManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check()
+		}
+	}
+}
+
+func (b *Bot) sendThresholdAlert(chatID int64, alert thresholdAlert) {
+	msg := b.formatter.FormatThresholdAlert(alert)
+	if b.incidents != nil && !alert.resolved {
+		key := ruleIncidentKey(alert.rule)
+		if !b.incidents.Muted(chatID, key) {
+			_ = b.sendIncident(chatID, key, msg)
+		}
+		return
+	}
+	if err := b.client.SendMessageHTML(chatID, msg); err != nil {
+		log.Printf("Failed to send threshold alert to chat %d: %v", chatID, err)
+	}
+}
+
+func (b *Bot) handleThresholdCommand(_ *th.Context, update telego.Update) error {
+	if update.Message == nil {
+		return nil
+	}
+
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.Text)
+	usage := "Использование: <code>/threshold имя 50 1m</code> — оповещать, когда правило " +
+		"сработало 50 раз за минуту; <code>/threshold имя off</code> — присылать каждое совпадение."
+
+	var threshold parser.Threshold
+	switch {
+	case len(args) == 3 && strings.EqualFold(args[2], "off"):
+	case len(args) == 4:
+		count, err := strconv.Atoi(args[2])
+		window, werr := time.ParseDuration(args[3])
+		if err != nil || werr != nil || count <= 0 || window <= 0 {
+			return b.client.SendMessageHTMLWithReplyMarkup(chatID, usage, b.mainKeyboard())
+		}
+		threshold = parser.Threshold{Count: count, Window: window}
+	default:
+		return b.client.SendMessageHTMLWithReplyMarkup(chatID, usage, b.mainKeyboard())
+	}
+
+	if b.db == nil {
+		return b.client.SendMessageHTMLWithReplyMarkup(
+			chatID,
+			"База данных не настроена, невозможно сохранить порог.",
+			b.mainKeyboard(),
+		)
+	}
+
+	name := args[1]
+	found, err := b.db.SetChatRegexRuleThreshold(chatID, name, threshold.Count, threshold.Window)
+	if err != nil {
+		b.sendErrorResponse(chatID, "set threshold", err)
+		return nil
+	}
+	if !found {
+		msg := fmt.Sprintf("У этого чата нет правила <code>%s</code>. "+
+			"Добавьте его через /addregex.", html.EscapeString(name))
+		return b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard())
+	}
+
+	chatRules, err := b.db.GetChatRegexRules(chatID)
+	if err != nil {
+		b.sendErrorResponse(chatID, "reload chat regex rules", err)
+		return nil
+	}
+	if err := b.regexManager.RefreshChatRules(chatID, chatRules); err != nil {
+		b.sendErrorResponse(chatID, "compile chat regex rules", err)
+		return nil
+	}
+
+	msg := fmt.Sprintf("<b>Порог снят</b>\n\nПравило <code>%s</code> снова присылает каждое "+
+		"совпадение.", html.EscapeString(name))
+	if threshold.Enabled() {
+		msg = fmt.Sprintf("<b>Порог установлен</b>\n\nПравило <code>%s</code> пришлет оповещение, "+
+			"когда сработает %d раз за %s, и сообщит, когда частота снизится.",
+			html.EscapeString(name), threshold.Count, formatDuration(threshold.Window))
+	}
+	if err := b.client.SendMessageHTMLWithReplyMarkup(chatID, msg, b.mainKeyboard()); err != nil {
+		log.Printf("Failed to send threshold status to chat %d: %v", chatID, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kxrxh/logram/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholdManager_FiresOnceAndResolves(t *testing.T) {
+	var alerts []thresholdAlert
+	m := NewThresholdManager(func(chatID int64, a thresholdAlert) {
+		require.Equal(t, int64(1), chatID)
+		alerts = append(alerts, a)
+	})
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	th := parser.Threshold{Count: 3, Window: time.Minute}
+	entry := dedupEntry("login failed", now)
+
+	m.Hit(1, "auth", th, entry)
+	now = now.Add(40 * time.Second)
+	m.Hit(1, "auth", th, entry)
+	now = now.Add(40 * time.Second)
+	m.Hit(1, "auth", th, entry)
+	require.Empty(t, alerts, "3 matches over 80s are below 3 per minute")
+
+	now = now.Add(10 * time.Second)
+	m.Hit(1, "auth", th, entry)
+	require.Len(t, alerts, 1)
+	require.False(t, alerts[0].resolved)
+	require.Equal(t, "auth", alerts[0].rule)
+	firedAt := now
+
+	for range 5 {
+		now = now.Add(time.Second)
+		m.Hit(1, "auth", th, entry)
+	}
+	m.Check()
+	require.Len(t, alerts, 1, "still above the threshold")
+
+	now = now.Add(time.Minute)
+	m.Check()
+	require.Len(t, alerts, 2)
+	require.True(t, alerts[1].resolved)
+	require.Equal(t, 8, alerts[1].total)
+	require.Equal(t, now.Sub(firedAt), alerts[1].duration)
+
+	m.Check()
+	require.Len(t, alerts, 2, "resolves once")
+}
+
+func TestThresholdManager_StatePerChatAndRule(t *testing.T) {
+	fired := make(map[thresholdKey]int)
+	m := NewThresholdManager(func(chatID int64, a thresholdAlert) {
+		fired[thresholdKey{chatID: chatID, rule: a.rule}]++
+	})
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	th := parser.Threshold{Count: 2, Window: time.Minute}
+	entry := dedupEntry("boom", now)
+	m.Hit(1, "a", th, entry)
+	m.Hit(2, "a", th, entry)
+	m.Hit(1, "b", th, entry)
+	require.Empty(t, fired)
+
+	m.Hit(1, "a", th, entry)
+	require.Equal(t, map[thresholdKey]int{{chatID: 1, rule: "a"}: 1}, fired)
+
+	// idle states are dropped once their window passes
+	now = now.Add(2 * time.Minute)
+	m.Check()
+	require.Empty(t, m.states)
+}